@@ -1,6 +1,9 @@
 package mgo
 
 import (
+	"context"
+	"time"
+
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -8,12 +11,45 @@ import (
 type Query struct {
 	mq   *mgo.Query
 	sess *mgo.Session
+
+	ctx context.Context
 }
 
 func NewQuery(mq *mgo.Query, sess *mgo.Session) *Query {
 	return &Query{mq: mq, sess: sess}
 }
 
+// NewQueryContext creates the Query with context, the query
+// is not executed if the context is done.
+func NewQueryContext(ctx context.Context, mq *mgo.Query, sess *mgo.Session) *Query {
+	return &Query{mq: mq, sess: sess, ctx: ctx}
+}
+
+// SetContext sets the socket timeout of the session to the
+// deadline of the context. The mgo driver has no context
+// support, the cancellation of the context is only checked
+// before the operations.
+func SetContext(ctx context.Context, sess *mgo.Session) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		// The operations are stopped by ctx.Err(), the
+		// timeout of 0 means no timeout for mgo.
+		timeout = time.Millisecond
+	}
+	sess.SetSocketTimeout(timeout)
+}
+
+func (q *Query) err() error {
+	if q.ctx == nil {
+		return nil
+	}
+	return q.ctx.Err()
+}
+
 func (q *Query) Select(fields ...string) *Query {
 	m := make(bson.M, len(fields))
 	for _, field := range fields {
@@ -54,10 +90,22 @@ func (q *Query) Iter() *mgo.Iter {
 
 func (q *Query) MarshalAll(v interface{}) error {
 	defer q.sess.Close()
+	if err := q.err(); err != nil {
+		return err
+	}
 	return q.mq.All(v)
 }
 
 func (q *Query) MarshalOne(v interface{}) error {
 	defer q.sess.Close()
+	if err := q.err(); err != nil {
+		return err
+	}
 	return q.mq.One(v)
 }
+
+// Err returns the error of the context of the query, the
+// iteration should be stopped if it is not nil.
+func (q *Query) Err() error {
+	return q.err()
+}
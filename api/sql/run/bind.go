@@ -0,0 +1,63 @@
+package run
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BindDollar converts the "?" bind variables of the sql to
+// "$1", "$2", ..., which are used by postgres. The "?" in the
+// quoted strings, identifiers and comments are kept, and "??"
+// is converted to a single "?" without numbering, for the
+// operators of postgres such as "jsonb ? 'key'".
+func BindDollar(sql string) string {
+	var sb strings.Builder
+	sb.Grow(len(sql) + 8)
+	var n int
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			end := strings.IndexByte(sql[i+1:], ch)
+			if end < 0 {
+				sb.WriteString(sql[i:])
+				return sb.String()
+			}
+			end += i + 2
+			sb.WriteString(sql[i:end])
+			i = end - 1
+
+		case ch == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				sb.WriteString(sql[i:])
+				return sb.String()
+			}
+			sb.WriteString(sql[i : i+end])
+			i += end - 1
+
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				sb.WriteString(sql[i:])
+				return sb.String()
+			}
+			end += i + 4
+			sb.WriteString(sql[i:end])
+			i = end - 1
+
+		case ch == '?' && strings.HasPrefix(sql[i:], "??"):
+			sb.WriteByte('?')
+			i++
+
+		case ch == '?':
+			n++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
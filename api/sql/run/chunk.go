@@ -0,0 +1,51 @@
+package run
+
+import "database/sql"
+
+// Chunk splits n elements into chunks of at most size
+// elements, and calls fn with the range [start, end) of each
+// chunk in order. fn is called once with the empty range if
+// n is 0, so the statement is executed as the unsplit one.
+// It stops at the first error, the chunks executed before are
+// not rolled back, use WithTx to make them atomic.
+func Chunk(n, size int, fn func(start, end int) error) error {
+	if size <= 0 || n <= size {
+		return fn(0, n)
+	}
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		err := fn(start, end)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Results is the result of the statement executed in
+// chunks, the RowsAffected is the sum of the chunks, the
+// LastInsertId is the one of the first chunk (for MySQL, it
+// is the id of the first row inserted).
+type Results []sql.Result
+
+func (rs Results) LastInsertId() (int64, error) {
+	if len(rs) == 0 {
+		return 0, nil
+	}
+	return rs[0].LastInsertId()
+}
+
+func (rs Results) RowsAffected() (int64, error) {
+	var sum int64
+	for _, r := range rs {
+		n, err := r.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		sum += n
+	}
+	return sum, nil
+}
@@ -0,0 +1,77 @@
+package run
+
+import "strings"
+
+// The functions below build the sql for the generated code.
+// Where and Set complete the "%{where}" and "%{set}" blocks
+// of the dynamic sql, the parts are the sql parts appended
+// by the block, they are modified in place.
+
+// Where adds the "WHERE" keyword to the parts, the leading
+// "AND" or "OR" of the first part is removed. If the parts
+// is empty (all the conditions are false), the keyword is
+// omitted.
+func Where(parts []string) {
+	if len(parts) == 0 {
+		return
+	}
+	parts[0] = "WHERE " + trimWord(parts[0], "AND", "OR")
+}
+
+// Set adds the "SET" keyword to the parts, the trailing
+// comma of the last part is removed. If the parts is empty,
+// the keyword is omitted.
+func Set(parts []string) {
+	if len(parts) == 0 {
+		return
+	}
+	last := len(parts) - 1
+	parts[last] = strings.TrimRight(strings.TrimSpace(parts[last]), ",")
+	parts[0] = "SET " + parts[0]
+}
+
+// trimWord removes the leading word (case insensitive) of
+// the sql.
+func trimWord(sql string, words ...string) string {
+	sql = strings.TrimSpace(sql)
+	for _, word := range words {
+		if len(sql) < len(word) ||
+			!strings.EqualFold(sql[:len(word)], word) {
+			continue
+		}
+		rest := sql[len(word):]
+		if rest == "" {
+			return rest
+		}
+		switch rest[0] {
+		case ' ', '\t', '\n', '(':
+			return strings.TrimSpace(rest)
+		}
+	}
+	return sql
+}
+
+// In returns the IN predicate of the slice with n elements,
+// such as "id IN (?,?,?)", pred is the sql before the list,
+// such as "id IN". Since "IN ()" is invalid, if n is 0, the
+// empty predicate is returned instead, such as "1=0".
+func In(pred string, n int, empty string) string {
+	if n == 0 {
+		return empty
+	}
+	var sb strings.Builder
+	sb.Grow(len(pred) + 2*n + 2)
+	if pred != "" {
+		sb.WriteString(pred)
+		sb.WriteString(" ")
+	}
+	sb.WriteString("(")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("?")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
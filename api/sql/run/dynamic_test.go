@@ -0,0 +1,108 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWhere(t *testing.T) {
+	cases := []struct {
+		parts  []string
+		expect string
+	}{
+		{nil, ""},
+		{[]string{}, ""},
+		{[]string{"id=?"}, "WHERE id=?"},
+		{[]string{"AND id=?", "AND name=?"}, "WHERE id=? AND name=?"},
+		{[]string{" and id=?"}, "WHERE id=?"},
+		{[]string{"OR id=?", "OR name=?"}, "WHERE id=? OR name=?"},
+		{[]string{"OR(id=? AND age>?)"}, "WHERE (id=? AND age>?)"},
+		{[]string{"AND\tid=?"}, "WHERE id=?"},
+		{[]string{"AND\nid=?"}, "WHERE id=?"},
+		{[]string{"ANDROID_ID=?"}, "WHERE ANDROID_ID=?"},
+		{[]string{"ORDER_ID=?"}, "WHERE ORDER_ID=?"},
+		{[]string{"AND ANDROID_ID=?"}, "WHERE ANDROID_ID=?"},
+	}
+	for _, c := range cases {
+		Where(c.parts)
+		got := strings.Join(c.parts, " ")
+		fmt.Printf("%q\n", got)
+		if got != c.expect {
+			t.Fatalf("got %q, expect %q", got, c.expect)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	cases := []struct {
+		parts  []string
+		expect string
+	}{
+		{nil, ""},
+		{[]string{}, ""},
+		{[]string{"name=?,"}, "SET name=?"},
+		{[]string{"name=? , "}, "SET name=? "},
+		{[]string{"name=?,", "age=?,"}, "SET name=?, age=?"},
+		{[]string{"name=?,", "age=?"}, "SET name=?, age=?"},
+		{[]string{"name=CONCAT(?,?),", "age=?,"}, "SET name=CONCAT(?,?), age=?"},
+	}
+	for _, c := range cases {
+		Set(c.parts)
+		got := strings.Join(c.parts, " ")
+		fmt.Printf("%q\n", got)
+		if got != c.expect {
+			t.Fatalf("got %q, expect %q", got, c.expect)
+		}
+	}
+}
+
+func TestTrimWord(t *testing.T) {
+	cases := []struct {
+		sql    string
+		expect string
+	}{
+		{"", ""},
+		{"AND", ""},
+		{"  OR  ", ""},
+		{"AND id=?", "id=?"},
+		{"or id=?", "id=?"},
+		{"OR(id=?)", "(id=?)"},
+		{"ANDROID_ID=?", "ANDROID_ID=?"},
+		{"ORDER_ID=?", "ORDER_ID=?"},
+		{"AN", "AN"},
+		{"id=? AND", "id=? AND"},
+	}
+	for _, c := range cases {
+		got := trimWord(c.sql, "AND", "OR")
+		fmt.Printf("%q -> %q\n", c.sql, got)
+		if got != c.expect {
+			t.Fatalf("trimWord(%q) = %q, expect %q", c.sql, got, c.expect)
+		}
+	}
+}
+
+func TestIn(t *testing.T) {
+	cases := []struct {
+		pred   string
+		n      int
+		empty  string
+		expect string
+	}{
+		{"id IN", 0, "1=0", "1=0"},
+		{"id NOT IN", 0, "NOT (1=0)", "NOT (1=0)"},
+		{"", 0, "(NULL)", "(NULL)"},
+		{"id IN", 1, "1=0", "id IN (?)"},
+		{"id IN", 3, "1=0", "id IN (?,?,?)"},
+		{"`u`.`id` NOT IN", 2, "NOT (1=0)", "`u`.`id` NOT IN (?,?)"},
+		{"", 2, "(NULL)", "(?,?)"},
+	}
+	for _, c := range cases {
+		got := In(c.pred, c.n, c.empty)
+		fmt.Printf("%q\n", got)
+		if got != c.expect {
+			t.Fatalf("In(%q, %d, %q) = %q, expect %q",
+				c.pred, c.n, c.empty, got, c.expect)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is the execution of a sql, it is passed to the hooks.
+type Event struct {
+	Ctx context.Context
+
+	// Name is the stable name of the query, such as
+	// "UserOper.FindById". It is empty if the sql is
+	// executed by the functions without name.
+	Name string
+	Sql  string
+	Args []interface{}
+
+	Start    time.Time
+	Duration time.Duration
+
+	// Rows is the number of rows affected for exec, or the
+	// number of rows scanned for query.
+	Rows int64
+	Err  error
+}
+
+// Hook intercepts the sql executed by the generated code.
+// Before is called before sending the sql to the database,
+// the Duration, Rows and Err of the event are set after
+// executing (and scanning), then After is called. The hooks
+// are called in the order they are added, After is called in
+// the reverse order.
+type Hook interface {
+	Before(e *Event)
+	After(e *Event)
+}
+
+var hooks atomic.Value
+
+// AddHook adds the hooks to the chain. It should be called
+// when initializing, before executing sql.
+func AddHook(hs ...Hook) {
+	old, _ := hooks.Load().([]Hook)
+	chain := make([]Hook, 0, len(old)+len(hs))
+	chain = append(chain, old...)
+	chain = append(chain, hs...)
+	hooks.Store(chain)
+}
+
+// ResetHooks removes all the hooks.
+func ResetHooks() {
+	hooks.Store([]Hook(nil))
+}
+
+// before returns nil if there is no hook.
+func before(ctx context.Context, name, sql string, vs []interface{}) *Event {
+	chain, _ := hooks.Load().([]Hook)
+	if len(chain) == 0 {
+		return nil
+	}
+	e := &Event{
+		Ctx:   ctx,
+		Name:  name,
+		Sql:   sql,
+		Args:  vs,
+		Start: time.Now(),
+	}
+	for _, h := range chain {
+		h.Before(e)
+	}
+	return e
+}
+
+func after(e *Event, err error) {
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+	chain, _ := hooks.Load().([]Hook)
+	for idx := len(chain) - 1; idx >= 0; idx-- {
+		chain[idx].After(e)
+	}
+}
+
+// logLine writes the event in logfmt: "key=value" pairs
+// separated by space.
+func logLine(out io.Writer, mu *sync.Mutex, e *Event, extra ...string) {
+	var sb strings.Builder
+	sb.WriteString(e.Start.Format(time.RFC3339))
+	sb.WriteString(" name=")
+	sb.WriteString(e.Name)
+	fmt.Fprintf(&sb, " duration=%v rows=%d", e.Duration, e.Rows)
+	for _, kv := range extra {
+		sb.WriteString(" ")
+		sb.WriteString(kv)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&sb, " err=%q", e.Err.Error())
+	}
+	fmt.Fprintf(&sb, " sql=%q args=%q\n", e.Sql, fmt.Sprint(e.Args))
+
+	mu.Lock()
+	defer mu.Unlock()
+	io.WriteString(out, sb.String())
+}
+
+// LogHook writes every executed sql to the writer in logfmt,
+// such as:
+//
+//	2006-01-02T15:04:05Z name=UserOper.FindById duration=1.2ms rows=1 sql="..." args="[1]"
+type LogHook struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewLogHook(out io.Writer) *LogHook {
+	return &LogHook{out: out}
+}
+
+func (*LogHook) Before(*Event) {}
+
+func (h *LogHook) After(e *Event) {
+	logLine(h.out, &h.mu, e)
+}
+
+// SlowHook writes the sql whose duration reaches the
+// threshold to the writer, in the same format as LogHook.
+type SlowHook struct {
+	mu  sync.Mutex
+	out io.Writer
+
+	threshold time.Duration
+}
+
+func NewSlowHook(out io.Writer, threshold time.Duration) *SlowHook {
+	return &SlowHook{out: out, threshold: threshold}
+}
+
+func (*SlowHook) Before(*Event) {}
+
+func (h *SlowHook) After(e *Event) {
+	if e.Duration < h.threshold {
+		return
+	}
+	logLine(h.out, &h.mu, e, "slow=true")
+}
+
+// DefaultBuckets are the upper bounds of the latency
+// histogram buckets.
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// HistogramHook records the latency histogram of each query
+// name in process.
+type HistogramHook struct {
+	mu sync.Mutex
+
+	buckets []time.Duration
+	data    map[string]*Histogram
+}
+
+// Histogram is the latency distribution of a query. Counts[i]
+// is the number of executions whose duration is not greater
+// than Buckets[i] (and greater than Buckets[i-1]), the last
+// one counts the executions exceeding all the buckets.
+type Histogram struct {
+	Name    string
+	Buckets []time.Duration
+	Counts  []int64
+
+	Count  int64
+	Errors int64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+// NewHistogramHook creates the hook with the bucket upper
+// bounds, DefaultBuckets is used if no bucket is given.
+func NewHistogramHook(buckets ...time.Duration) *HistogramHook {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i] < buckets[j]
+	})
+	return &HistogramHook{
+		buckets: buckets,
+		data:    make(map[string]*Histogram),
+	}
+}
+
+func (*HistogramHook) Before(*Event) {}
+
+func (h *HistogramHook) After(e *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist := h.data[e.Name]
+	if hist == nil {
+		hist = &Histogram{
+			Name:    e.Name,
+			Buckets: h.buckets,
+			Counts:  make([]int64, len(h.buckets)+1),
+		}
+		h.data[e.Name] = hist
+	}
+	idx := sort.Search(len(h.buckets), func(i int) bool {
+		return e.Duration <= h.buckets[i]
+	})
+	hist.Counts[idx]++
+	hist.Count++
+	hist.Sum += e.Duration
+	if e.Duration > hist.Max {
+		hist.Max = e.Duration
+	}
+	if e.Err != nil && e.Err != ErrNotFound {
+		hist.Errors++
+	}
+}
+
+// Snapshot returns the copy of the histograms, sorted by
+// the name.
+func (h *HistogramHook) Snapshot() []*Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hists := make([]*Histogram, 0, len(h.data))
+	for _, hist := range h.data {
+		cp := *hist
+		cp.Counts = append([]int64(nil), hist.Counts...)
+		hists = append(hists, &cp)
+	}
+	sort.Slice(hists, func(i, j int) bool {
+		return hists[i].Name < hists[j].Name
+	})
+	return hists
+}
+
+// Reset clears the recorded histograms.
+func (h *HistogramHook) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.data = make(map[string]*Histogram)
+}
+
+// Mean returns the average duration.
+func (h *Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the estimated duration of the quantile q
+// (0 < q <= 1), which is the upper bound of the bucket it
+// falls in. The Max is returned if it exceeds all the buckets.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	target := int64(q * float64(h.Count))
+	if target < 1 {
+		target = 1
+	}
+	var cnt int64
+	for idx, n := range h.Counts {
+		cnt += n
+		if cnt < target {
+			continue
+		}
+		if idx < len(h.Buckets) {
+			return h.Buckets[idx]
+		}
+		break
+	}
+	return h.Max
+}
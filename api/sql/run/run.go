@@ -1,6 +1,7 @@
 package run
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -11,36 +12,111 @@ type IDB interface {
 	Exec(sql string, vs ...interface{}) (sql.Result, error)
 }
 
+// IDBContext is the IDB with context, the context is passed
+// to the driver, so the cancellation and deadline of it can
+// stop the sql. *sql.DB, *sql.Tx and *sql.Conn implement it.
+type IDBContext interface {
+	QueryContext(ctx context.Context, sql string, vs ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, sql string, vs ...interface{}) (sql.Result, error)
+}
+
 var ErrNotFound = errors.New("data not found")
 
-func query(db IDB, sql string, rs, vs []interface{}) (*sql.Rows, error) {
+// The functions below execute the sql for the generated code.
+// The name is the stable name of the query, such as
+// "UserOper.FindById", it is passed to the hooks.
+
+func format(sql string, rs []interface{}) string {
 	if len(rs) > 0 {
 		sql = fmt.Sprintf(sql, rs...)
 	}
-	return db.Query(sql, vs...)
+	return sql
 }
 
-func exec(db IDB, sql string, rs, vs []interface{}) (sql.Result, error) {
-	if len(rs) > 0 {
-		sql = fmt.Sprintf(sql, rs...)
+type execFunc func(sql string) (sql.Result, error)
+
+type queryFunc func(sql string) (*sql.Rows, error)
+
+func exec(ctx context.Context, name, sql string, rs, vs []interface{}, do execFunc) (sql.Result, error) {
+	sql = format(sql, rs)
+	e := before(ctx, name, sql, vs)
+	result, err := do(sql)
+	if e != nil {
+		if err == nil {
+			e.Rows, _ = result.RowsAffected()
+		}
+		after(e, err)
+	}
+	return result, err
+}
+
+func query(ctx context.Context, name, sql string, rs, vs []interface{},
+	one bool, scanFunc ScanFunc, do queryFunc) error {
+	sql = format(sql, rs)
+	e := before(ctx, name, sql, vs)
+	rows, err := do(sql)
+	var n int64
+	if one {
+		n, err = scanOne(rows, err, scanFunc)
+	} else {
+		n, err = scanMany(rows, err, scanFunc)
+	}
+	if e != nil {
+		e.Rows = n
+		after(e, err)
+	}
+	return err
+}
+
+func dbExec(db IDB, vs []interface{}) execFunc {
+	return func(sql string) (sql.Result, error) {
+		return db.Exec(sql, vs...)
+	}
+}
+
+func dbQuery(db IDB, vs []interface{}) queryFunc {
+	return func(sql string) (*sql.Rows, error) {
+		return db.Query(sql, vs...)
 	}
-	return db.Exec(sql, vs...)
 }
 
+// Exec, ExecAffect, ExecLastId, QueryMany and QueryOne are
+// kept for the code generated by the earlier versions, the
+// name passed to the hooks is empty. The generated code
+// calls the "*Named" functions.
+
 func Exec(db IDB, sql string, rs, vs []interface{}) (sql.Result, error) {
-	return exec(db, sql, rs, vs)
+	return ExecNamed(db, "", sql, rs, vs)
 }
 
 func ExecAffect(db IDB, sql string, rs, vs []interface{}) (int64, error) {
-	result, err := exec(db, sql, rs, vs)
+	return ExecAffectNamed(db, "", sql, rs, vs)
+}
+
+func ExecLastId(db IDB, sql string, rs, vs []interface{}) (int64, error) {
+	return ExecLastIdNamed(db, "", sql, rs, vs)
+}
+
+func ExecNamed(db IDB, name, sql string, rs, vs []interface{}) (sql.Result, error) {
+	return exec(context.Background(), name, sql, rs, vs, dbExec(db, vs))
+}
+
+func ExecAffectNamed(db IDB, name, sql string, rs, vs []interface{}) (int64, error) {
+	return affect(ExecNamed(db, name, sql, rs, vs))
+}
+
+func ExecLastIdNamed(db IDB, name, sql string, rs, vs []interface{}) (int64, error) {
+	return lastId(ExecNamed(db, name, sql, rs, vs))
+}
+
+func affect(result sql.Result, err error) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
 
-func ExecLastId(db IDB, sql string, rs, vs []interface{}) (int64, error) {
-	result, err := exec(db, sql, rs, vs)
+func lastId(result sql.Result, err error) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
@@ -50,32 +126,108 @@ func ExecLastId(db IDB, sql string, rs, vs []interface{}) (int64, error) {
 type ScanFunc func(rows *sql.Rows) error
 
 func QueryMany(db IDB, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
-	rows, err := query(db, sql, rs, vs)
+	return QueryManyNamed(db, "", sql, rs, vs, scanFunc)
+}
+
+func QueryOne(db IDB, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
+	return QueryOneNamed(db, "", sql, rs, vs, scanFunc)
+}
+
+func QueryManyNamed(db IDB, name, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
+	return query(context.Background(), name, sql, rs, vs, false,
+		scanFunc, dbQuery(db, vs))
+}
+
+func QueryOneNamed(db IDB, name, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
+	return query(context.Background(), name, sql, rs, vs, true,
+		scanFunc, dbQuery(db, vs))
+}
+
+// scanMany scans all the rows, returns the number of rows.
+func scanMany(rows *sql.Rows, err error, scanFunc ScanFunc) (int64, error) {
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rows.Close()
+	var n int64
 	for rows.Next() {
 		err = scanFunc(rows)
 		if err != nil {
-			return err
+			return n, err
 		}
+		n++
 	}
-	return nil
+	return n, rows.Err()
 }
 
-func QueryOne(db IDB, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
-	rows, err := query(db, sql, rs, vs)
+func scanOne(rows *sql.Rows, err error, scanFunc ScanFunc) (int64, error) {
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rows.Close()
 	if rows.Next() {
 		err = scanFunc(rows)
 		if err != nil {
-			return err
+			return 0, err
 		}
-		return nil
+		return 1, nil
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
 	}
-	return ErrNotFound
+	return 0, ErrNotFound
+}
+
+func dbExecContext(ctx context.Context, db IDBContext, vs []interface{}) execFunc {
+	return func(sql string) (sql.Result, error) {
+		return db.ExecContext(ctx, sql, vs...)
+	}
+}
+
+func dbQueryContext(ctx context.Context, db IDBContext, vs []interface{}) queryFunc {
+	return func(sql string) (*sql.Rows, error) {
+		return db.QueryContext(ctx, sql, vs...)
+	}
+}
+
+func ExecContext(ctx context.Context, db IDBContext, sql string, rs, vs []interface{}) (sql.Result, error) {
+	return ExecNamedContext(ctx, db, "", sql, rs, vs)
+}
+
+func ExecAffectContext(ctx context.Context, db IDBContext, sql string, rs, vs []interface{}) (int64, error) {
+	return ExecAffectNamedContext(ctx, db, "", sql, rs, vs)
+}
+
+func ExecLastIdContext(ctx context.Context, db IDBContext, sql string, rs, vs []interface{}) (int64, error) {
+	return ExecLastIdNamedContext(ctx, db, "", sql, rs, vs)
+}
+
+func QueryManyContext(ctx context.Context, db IDBContext, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
+	return QueryManyNamedContext(ctx, db, "", sql, rs, vs, scanFunc)
+}
+
+func QueryOneContext(ctx context.Context, db IDBContext, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
+	return QueryOneNamedContext(ctx, db, "", sql, rs, vs, scanFunc)
+}
+
+func ExecNamedContext(ctx context.Context, db IDBContext, name, sql string, rs, vs []interface{}) (sql.Result, error) {
+	return exec(ctx, name, sql, rs, vs, dbExecContext(ctx, db, vs))
+}
+
+func ExecAffectNamedContext(ctx context.Context, db IDBContext, name, sql string, rs, vs []interface{}) (int64, error) {
+	return affect(ExecNamedContext(ctx, db, name, sql, rs, vs))
+}
+
+func ExecLastIdNamedContext(ctx context.Context, db IDBContext, name, sql string, rs, vs []interface{}) (int64, error) {
+	return lastId(ExecNamedContext(ctx, db, name, sql, rs, vs))
+}
+
+func QueryManyNamedContext(ctx context.Context, db IDBContext, name, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
+	return query(ctx, name, sql, rs, vs, false, scanFunc,
+		dbQueryContext(ctx, db, vs))
+}
+
+func QueryOneNamedContext(ctx context.Context, db IDBContext, name, sql string, rs, vs []interface{}, scanFunc ScanFunc) error {
+	return query(ctx, name, sql, rs, vs, true, scanFunc,
+		dbQueryContext(ctx, db, vs))
 }
@@ -0,0 +1,226 @@
+package run
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Tx is the transaction passed to the function of WithTx, it
+// implements IDB and IDBContext, so the generated methods can
+// be called with it. Passing it to WithTx again creates a
+// nested transaction by savepoint.
+type Tx struct {
+	tx    *sql.Tx
+	ctx   context.Context
+	depth int
+}
+
+func (tx *Tx) Query(sql string, vs ...interface{}) (*sql.Rows, error) {
+	return tx.tx.QueryContext(tx.ctx, sql, vs...)
+}
+
+func (tx *Tx) Exec(sql string, vs ...interface{}) (sql.Result, error) {
+	return tx.tx.ExecContext(tx.ctx, sql, vs...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, sql string, vs ...interface{}) (*sql.Rows, error) {
+	return tx.tx.QueryContext(ctx, sql, vs...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, sql string, vs ...interface{}) (sql.Result, error) {
+	return tx.tx.ExecContext(ctx, sql, vs...)
+}
+
+// Raw returns the underlying *sql.Tx. Do not commit or
+// rollback it, which is handled by WithTx.
+func (tx *Tx) Raw() *sql.Tx {
+	return tx.tx
+}
+
+// TxBeginner begins the transaction, *sql.DB and *sql.Conn
+// implement it.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// TxOptions is the options of WithTx, nil means the default
+// options.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+
+	// Retry is the max number of retries when the transaction
+	// fails by deadlock (or other retryable error), default is
+	// 0 (no retry). The whole function is called again, so it
+	// must be idempotent: the side effects other than the
+	// database (sending messages, calling other services) are
+	// repeated. The nested transaction is never retried, the
+	// error is returned to the outermost one.
+	Retry int
+
+	// RetryDelay is the delay before the first retry, it is
+	// doubled for each retry.
+	RetryDelay time.Duration
+
+	// Retryable reports whether the error can be retried, the
+	// default is IsDeadlock.
+	Retryable func(err error) bool
+}
+
+var defaultTxOptions = TxOptions{}
+
+// WithTx runs fn in a transaction. The transaction is
+// committed if fn returns nil, otherwise (or fn panics) it is
+// rolled back. If db is the *Tx of an outer WithTx, a nested
+// transaction is created by savepoint, which is rolled back
+// to the savepoint alone. The transaction fails by deadlock is
+// retried only if opts.Retry is set.
+func WithTx(db IDB, opts *TxOptions, fn func(tx IDB) error) error {
+	return withTx(context.Background(), db, opts, func(tx *Tx) error {
+		return fn(tx)
+	})
+}
+
+// WithTxContext is WithTx with context, the context is used to
+// begin the transaction and to execute the sql of the Tx.
+func WithTxContext(ctx context.Context, db IDBContext, opts *TxOptions,
+	fn func(tx IDBContext) error) error {
+	return withTx(ctx, db, opts, func(tx *Tx) error {
+		return fn(tx)
+	})
+}
+
+func withTx(ctx context.Context, db interface{}, opts *TxOptions, fn func(*Tx) error) error {
+	if opts == nil {
+		opts = &defaultTxOptions
+	}
+	if outer, ok := db.(*Tx); ok {
+		return savepoint(ctx, outer, fn)
+	}
+	beginner, ok := db.(TxBeginner)
+	if !ok {
+		return fmt.Errorf("run: %T can not begin transaction", db)
+	}
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = IsDeadlock
+	}
+	delay := opts.RetryDelay
+	for retry := 0; ; retry++ {
+		err := runTx(ctx, beginner, opts, fn)
+		if err == nil || retry >= opts.Retry || !retryable(err) {
+			return err
+		}
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+	}
+}
+
+func runTx(ctx context.Context, beginner TxBeginner, opts *TxOptions,
+	fn func(*Tx) error) (err error) {
+	sqlTx, err := beginner.BeginTx(ctx, &sql.TxOptions{
+		Isolation: opts.Isolation,
+		ReadOnly:  opts.ReadOnly,
+	})
+	if err != nil {
+		return err
+	}
+	tx := &Tx{tx: sqlTx, ctx: ctx}
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+	err = fn(tx)
+	if err != nil {
+		return rollback(err, sqlTx.Rollback())
+	}
+	return sqlTx.Commit()
+}
+
+func savepoint(ctx context.Context, outer *Tx, fn func(*Tx) error) (err error) {
+	tx := &Tx{tx: outer.tx, ctx: ctx, depth: outer.depth + 1}
+	name := fmt.Sprintf("gendb_sp_%d", tx.depth)
+	_, err = outer.tx.ExecContext(ctx, "SAVEPOINT "+name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			outer.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+	err = fn(tx)
+	if err != nil {
+		_, rerr := outer.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		return rollback(err, rerr)
+	}
+	_, err = outer.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// TxError is returned if the rollback fails after the
+// function fails, Err is the error of the function.
+type TxError struct {
+	Err      error
+	Rollback error
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("%v (rollback failed: %v)", e.Err, e.Rollback)
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+func rollback(err, rerr error) error {
+	if rerr == nil || rerr == sql.ErrTxDone {
+		return err
+	}
+	return &TxError{Err: err, Rollback: rerr}
+}
+
+// mysql: 1213 deadlock, 1205 lock wait timeout.
+var mysqlDeadlockRe = regexp.MustCompile(`^Error (1213|1205)\b`)
+
+// IsDeadlock reports whether the error is caused by deadlock
+// or serialization failure, so the transaction can be retried.
+// It recognizes the mysql error numbers 1213, 1205, the
+// SQLSTATE 40001, 40P01 (postgres), and the busy sqlite
+// database, without importing the drivers.
+func IsDeadlock(err error) bool {
+	if err == nil {
+		return false
+	}
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		switch state.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		msg := err.Error()
+		if mysqlDeadlockRe.MatchString(msg) {
+			return true
+		}
+		if strings.Contains(msg, "database is locked") {
+			return true
+		}
+	}
+	return false
+}
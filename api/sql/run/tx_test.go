@@ -0,0 +1,218 @@
+package run
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTxDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE user(id INTEGER PRIMARY KEY, name TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func countUser(t *testing.T, db *sql.DB) int {
+	var n int
+	err := db.QueryRow("SELECT COUNT(1) FROM user").Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func insertUser(db IDB, name string) error {
+	_, err := db.Exec("INSERT INTO user(name) VALUES (?)", name)
+	return err
+}
+
+func TestWithTx(t *testing.T) {
+	db := openTxDB(t)
+	err := WithTx(db, nil, func(tx IDB) error {
+		return insertUser(tx, "a")
+	})
+	fmt.Println("commit", err, countUser(t, db))
+	if err != nil || countUser(t, db) != 1 {
+		t.Fatal("commit failed")
+	}
+
+	err = WithTx(db, nil, func(tx IDB) error {
+		insertUser(tx, "b")
+		return errors.New("boom")
+	})
+	fmt.Println("rollback", err, countUser(t, db))
+	if err == nil || countUser(t, db) != 1 {
+		t.Fatal("rollback failed")
+	}
+}
+
+func TestWithTxSavepoint(t *testing.T) {
+	db := openTxDB(t)
+	err := WithTx(db, nil, func(tx IDB) error {
+		insertUser(tx, "a")
+		// The savepoints are named by the depth, rolling back
+		// to them fails if they do not exist.
+		err := WithTx(tx, nil, func(tx IDB) error {
+			_, err := tx.Exec("ROLLBACK TO SAVEPOINT gendb_sp_1")
+			if err != nil {
+				return err
+			}
+			return WithTx(tx, nil, func(tx IDB) error {
+				_, err := tx.Exec("ROLLBACK TO SAVEPOINT gendb_sp_2")
+				return err
+			})
+		})
+		if err != nil {
+			return err
+		}
+		inner := WithTx(tx, nil, func(tx IDB) error {
+			insertUser(tx, "b")
+			return errors.New("inner")
+		})
+		fmt.Println("inner", inner)
+		if inner == nil {
+			return errors.New("inner error is lost")
+		}
+		return nil
+	})
+	fmt.Println("savepoint", err, countUser(t, db))
+	if err != nil || countUser(t, db) != 1 {
+		t.Fatal("savepoint failed")
+	}
+}
+
+func TestWithTxPanic(t *testing.T) {
+	db := openTxDB(t)
+	func() {
+		defer func() {
+			p := recover()
+			fmt.Println("panic", p, countUser(t, db))
+			if p != "p" {
+				t.Fatalf("panic is not propagated: %v", p)
+			}
+		}()
+		WithTx(db, nil, func(tx IDB) error {
+			insertUser(tx, "a")
+			panic("p")
+		})
+	}()
+	if countUser(t, db) != 0 {
+		t.Fatal("panic is not rolled back")
+	}
+
+	// The panic in the nested transaction rolls back to the
+	// savepoint, the outer one decides to commit.
+	err := WithTx(db, nil, func(tx IDB) error {
+		insertUser(tx, "b")
+		func() {
+			defer func() { recover() }()
+			WithTx(tx, nil, func(tx IDB) error {
+				insertUser(tx, "c")
+				panic("p")
+			})
+		}()
+		return nil
+	})
+	fmt.Println("nested panic", err, countUser(t, db))
+	if err != nil || countUser(t, db) != 1 {
+		t.Fatal("nested panic is not rolled back")
+	}
+}
+
+func TestWithTxRetry(t *testing.T) {
+	db := openTxDB(t)
+	// No retry by default.
+	var n int
+	err := WithTx(db, nil, func(tx IDB) error {
+		n++
+		return errors.New("database is locked")
+	})
+	if n != 1 || err == nil {
+		t.Fatalf("default options called %d times", n)
+	}
+
+	n = 0
+	err = WithTx(db, &TxOptions{Retry: 3}, func(tx IDB) error {
+		n++
+		return errors.New("database is locked")
+	})
+	fmt.Println("retry", n, err)
+	if n != 4 {
+		t.Fatalf("called %d times, expect 4", n)
+	}
+
+	n = 0
+	err = WithTx(db, &TxOptions{Retry: 3}, func(tx IDB) error {
+		n++
+		if n < 3 {
+			return errors.New("database is locked")
+		}
+		return insertUser(tx, "a")
+	})
+	fmt.Println("retry", n, err, countUser(t, db))
+	if err != nil || n != 3 || countUser(t, db) != 1 {
+		t.Fatal("retry failed")
+	}
+
+	n = 0
+	err = WithTx(db, &TxOptions{Retry: 3}, func(tx IDB) error {
+		n++
+		return errors.New("boom")
+	})
+	if n != 1 {
+		t.Fatalf("not retryable error called %d times", n)
+	}
+
+	// The nested transaction is never retried.
+	n = 0
+	WithTx(db, &TxOptions{Retry: 0}, func(tx IDB) error {
+		return WithTx(tx, &TxOptions{Retry: 3}, func(tx IDB) error {
+			n++
+			return errors.New("database is locked")
+		})
+	})
+	if n != 1 {
+		t.Fatalf("nested called %d times", n)
+	}
+}
+
+type stateError string
+
+func (e stateError) Error() string    { return "state " + string(e) }
+func (e stateError) SQLState() string { return string(e) }
+
+func TestIsDeadlock(t *testing.T) {
+	cases := []struct {
+		err    error
+		expect bool
+	}{
+		{nil, false},
+		{errors.New("Error 1213: Deadlock found when trying to get lock"), true},
+		{errors.New("Error 1205: Lock wait timeout exceeded"), true},
+		{errors.New("Error 12130: unknown"), false},
+		{errors.New("Error 1062: Duplicate entry"), false},
+		{stateError("40001"), true},
+		{stateError("40P01"), true},
+		{stateError("23505"), false},
+		{errors.New("database is locked"), true},
+		{fmt.Errorf("insert: %w", errors.New("Error 1213: Deadlock")), true},
+		{&TxError{Err: stateError("40001"), Rollback: errors.New("x")}, true},
+	}
+	for _, c := range cases {
+		got := IsDeadlock(c.err)
+		fmt.Printf("%v -> %v\n", c.err, got)
+		if got != c.expect {
+			t.Fatalf("IsDeadlock(%v) = %v, expect %v", c.err, got, c.expect)
+		}
+	}
+}
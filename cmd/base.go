@@ -10,9 +10,13 @@ import (
 	"github.com/fioncat/go-gendb/cmd/clean"
 	"github.com/fioncat/go-gendb/cmd/conn"
 	"github.com/fioncat/go-gendb/cmd/gen"
+	"github.com/fioncat/go-gendb/cmd/gen/pull_orm"
 	"github.com/fioncat/go-gendb/cmd/gen/sql_model"
 	"github.com/fioncat/go-gendb/cmd/tools/check"
 	"github.com/fioncat/go-gendb/cmd/tools/exec"
+	"github.com/fioncat/go-gendb/cmd/tools/lint"
+	"github.com/fioncat/go-gendb/cmd/tools/migrate"
+	"github.com/fioncat/go-gendb/cmd/tools/schema"
 	"github.com/fioncat/go-gendb/misc/cmdt"
 	"github.com/fioncat/go-gendb/version"
 )
@@ -26,11 +30,15 @@ func init() {
 
 	cmds["gen"] = gen.Cmder
 	cmds["gen-sql-model"] = sql_model.Cmder
+	cmds["pull-orm"] = pull_orm.Cmder
 
 	cmds["conn"] = conn.Cmder
 	cmds["clean"] = clean.Cmder
 	cmds["check"] = check.Cmder
 	cmds["exec"] = exec.Cmder
+	cmds["lint"] = lint.Cmder
+	cmds["schema"] = schema.Cmder
+	cmds["migrate"] = migrate.Cmder
 }
 
 func getCmd(name string) *cmdt.Command {
@@ -97,9 +105,15 @@ Generate Commands:
     gen    Generate code for one file.
     clean  Remove generated code(s) or cached data.
     conn   Configure database connection.
+    schema Pull or diff the schema snapshot.
+    pull-orm
+           Generate orm-sql declarations from the tables.
+    migrate
+           Generate migrations from the orm-sql declarations.
 
 Debug Commands:
     cgo    Compile the go file.
     csql   Compile the sql file.
+    lint   Lint the sql files without database.
 
 Use "go-gendb help <command>" for more information about a command.`
@@ -36,6 +36,66 @@ process will report an error.
 For the specific usage of tags, please refer to the documentation
 on the project's Github homepage.
 
+The database is chosen by the "conn=[type,]key" option of the
+file. If the tables of an interface (sql) or a struct (orm-sql)
+are in another database, add the "conn" option to its tag, such
+as "+gen:sql name=Report conn=pg,report". Each connection is
+opened once, and has its own table cache.
+
+For mysql, DECIMAL is converted to string to keep it exact,
+and DATE, DATETIME and TIMESTAMP are converted to time.Time,
+which needs "parseTime=true" in the DSN of the connection
+passed to the generated code. To use other types (such as a
+decimal type), add the type mappings to "gendb.yaml":
+
+    types:
+      - db: decimal
+        go: decimal.Decimal
+        go_null: decimal.NullDecimal
+        import: github.com/shopspring/decimal
+
+For postgres, the "?" of the generated sql are converted to
+"$1", "$2", ..., the "?" in strings and comments are kept. Use
+"??" for the "?" operator of postgres, such as "data ?? 'key'".
+
+For sql, the placeholders ("${u.Name}", "#{order}") and the
+dynamic conditions ("%{if ...}", "%{for u in us}") are type
+checked against the parameters of the interface methods, with
+the package of the Go file. The errors are reported at the
+lines of the sql file. Add "type_check=false" to the file
+option to skip it.
+
+The prepare placeholder of a slice parameter (such as "${ids}"
+of "ids []int64") is expanded to "(?,?,?)", one value for each
+element, "id IN ${ids}" and "id IN (${ids})" are both ok. If the
+slice is empty, the predicate "id IN ..." is replaced by "1=0"
+("NOT (1=0)" for "NOT IN"), add "empty_in=<predicate>" to the
+file option to change it.
+
+To keep the statements under the limits of the database (the
+placeholders, the packet size), add "chunk=<param>:<size>" to
+the method tag, such as "+gen:method GetByIds chunk=ids:500",
+the slice parameter is split into chunks of size, and the
+statement is executed for each of them. The rows of a query are
+merged, and the affected rows of an exec are summed. The sql
+with "ORDER BY", "LIMIT", "GROUP BY", "DISTINCT" or aggregate
+functions can not be chunked, since they apply to each chunk
+rather than all the rows. For orm-sql, InsertBatch is split so
+that each statement has at most "max_placeholders" (default
+65535) placeholders, add it to the file option to change it.
+
+The chunks are not atomic: if a chunk fails, the chunks before
+it are not rolled back. Call the method in "run.WithTx" to
+execute all the chunks in one transaction.
+
+If the first parameter of a sql method is "context.Context",
+the generated method passes it to the database by the
+"*Context" functions of the run package. For orm-sql and
+orm-mgo, add "context=true" to the file option to add the
+context parameter to all the generated methods. (mgo has no
+context support, the context is checked before operations,
+and its deadline is used as the socket timeout.)
+
 Command Flags:
 
     <file-path>
@@ -73,5 +133,11 @@ Command Flags:
                    and hours respectively. For example, "2h30m" means
                    that the cache expiration time is 2 hours and 30
                    minutes.
+    --schema <path>
+                   Describe the tables from the schema snapshot
+                   (written by "go-gendb schema pull") or the DDL
+                   files, rather than the database. The connection
+                   and schema options in the source file will be
+                   ignored.
 
-See also: clean`
+See also: clean, schema`
@@ -0,0 +1,60 @@
+package pull_orm
+
+import (
+	"github.com/fioncat/go-gendb/generate"
+	"github.com/fioncat/go-gendb/misc/cmdt"
+)
+
+var Cmder = &cmdt.Command{
+	Name: "pull-orm",
+	Pv:   (*generate.PullOrmArg)(nil),
+
+	Usage: "pull-orm [-o <file>] [--pkg <name>] <conn> [tables...]",
+	Help:  help,
+
+	Action: func(p interface{}) error {
+		return generate.PullOrm(p.(*generate.PullOrmArg))
+	},
+}
+
+const help = `
+Pull-orm describes the data tables and writes them into a
+Go source file as orm-sql declarations ("+gen:orm" structs),
+so that the existing tables can be managed by orm-sql. The
+field types, nullable, primary key, auto increment, default
+values, indexes and comments are all pulled.
+
+Each struct is named "_<name>", and the generated file can
+be passed to "go-gendb gen" directly. It is recommended to
+review the file before committing it, especially the names
+and the Go types.
+
+Command Flags:
+    <conn>
+         The connection key, see "go-gendb help conn". It is
+         also written into the file as the "conn" option.
+    [tables...]
+         The tables to pull, by default, all tables in the
+         database are pulled.
+    -o <file>
+         The output file, default is "orm.go".
+    --pkg <name>
+         The package name, default is the name of the output
+         directory.
+    --db-type <type>
+         The database type, default is "mysql".
+    --schema <path>
+         Describe the tables from the DDL files or the schema
+         snapshot rather than the database.
+    --null <pointer|sqlnull>
+         The Go types of the nullable fields, default is
+         "pointer".
+    --log
+         Show the log.
+
+Example:
+    go-gendb pull-orm local
+    go-gendb pull-orm -o ./model/orm.go --pkg model local user detail
+    go-gendb pull-orm --schema ./gendb.schema.json local
+
+See also: gen, schema`
@@ -9,7 +9,99 @@ var Cmder = &cmdt.Command{
 	Name: "check",
 	Pv:   (*check.Arg)(nil),
 
+	Usage: "check [-d] [--db-type <type>] [--max-rows <n>] [--max-variants <n>] [--skip <rules>] [--format <format>] [-o <file>] [--save-baseline <file>] [--baseline <file>] <conn> <path>",
+	Help:  help,
+
 	Action: func(p interface{}) error {
 		return check.Do(p.(*check.Arg))
 	},
 }
+
+const help = `
+Check explains the sql methods with "check" tag in the sql
+file, and reports the performance problems found in the
+query plans. The options of the tag are the values of the
+placeholders, and the thresholds of the rules:
+
+    -- +gen:method FindUser
+    -- +gen:check id=1 max_rows=100000 skip=[filesort]
+    SELECT * FROM user WHERE id=${id};
+    -- +gen:end
+
+So "max_rows", "max_variants" and "skip" can not be used as
+placeholder names.
+
+For the dynamic method, each combination of the "%{if}"
+branches is checked, and the "%{for}" parts are expanded
+twice. If there are too many combinations, only max_variants
+of them are sampled. The problems are reported with the
+enabled branches. The placeholders in dynamic method are
+usually expressions, the value of "${conds["id"]}" or
+"${u.Id}" can also be given by "id=1" or "Id=1".
+
+If any error or warning is found, the command exits with
+non-zero code, which can be used in CI.
+
+For mysql, the plan is read from "EXPLAIN FORMAT=JSON", and
+the rules are:
+    full-scan       Full table scan.
+    join-full-scan  Full table scan on the inner table of a join.
+    filesort        Sorting can not be done by index.
+    temporary       Temporary table is used.
+    rows            Rows examined per scan exceeds max_rows.
+    unused-key      Possible keys exist but none is used.
+For other databases, only the full table scan is checked.
+
+The result can be written in the machine readable formats by
+"--format", which include the file path, the line of the
+"+gen:method" tag and the method name of each problem:
+    json   The results of all the methods.
+    junit  JUnit XML, each file is a test suite and each method
+           is a test case, the warnings are failures.
+    sarif  SARIF 2.1.0, for the code review annotations.
+
+The plans (access type, key and estimated rows of each table)
+can be saved by "--save-baseline" into a file to commit. Then
+"--baseline" compares the plans with it, and reports the
+"plan-regression" warning when a method loses its index, or
+turns to full table scan, or its estimated rows grow beyond
+the ratio. The methods not in the baseline are ignored. The
+baseline is only supported by mysql now.
+
+Command Flags:
+    <conn>
+         The connection key.
+    <path>
+         The sql file, or the directory if "-d" is given.
+    -d
+         Check all the sql files in the directory.
+    --db-type <type>
+         The database type, default is "mysql".
+    --max-rows <n>
+         The default max_rows, default is 10000, 0 means no limit.
+    --max-variants <n>
+         The default max_variants, default is 64.
+    --skip <rules>
+         The rules to skip, split by ",".
+    --format <format>
+         The output format, "text", "json", "junit" or "sarif",
+         default is "text".
+    -o <file>
+         Write the result to the file rather than stdout, it is
+         recommended when the format is not "text".
+    --save-baseline <file>
+         Save the plans into the baseline file.
+    --baseline <file>
+         Compare the plans with the baseline file.
+    --rows-ratio <ratio>
+         The max growth ratio of the estimated rows compared with
+         the baseline, default is 2.
+    --log
+         Show the log.
+
+Example:
+    go-gendb check local ./user/user.sql
+    go-gendb check -d --skip filesort,temporary local ./db/sql
+    go-gendb check -d --format sarif -o check.sarif local ./db/sql
+    go-gendb check -d --save-baseline ./db/plan.json local ./db/sql
+    go-gendb check -d --baseline ./db/plan.json local ./db/sql`
@@ -0,0 +1,59 @@
+package lint
+
+import (
+	"github.com/fioncat/go-gendb/database/tools/lint"
+	"github.com/fioncat/go-gendb/misc/cmdt"
+)
+
+var Cmder = &cmdt.Command{
+	Name: "lint",
+	Pv:   (*lint.Arg)(nil),
+
+	Usage: "lint [--db-type <type>] [--skip <rules>] <path>",
+	Help:  help,
+
+	Action: func(p interface{}) error {
+		return lint.Do(p.(*lint.Arg))
+	},
+}
+
+const help = `
+Lint reads the sql files and reports the problems without
+database connection. If the path is a directory, all the sql
+and Go files under it are read. If the path is a sql file,
+the Go files in the same directory are read to find the
+interfaces referencing the methods.
+
+The rules are:
+    no-where        UPDATE or DELETE without WHERE. For the
+                    dynamic method, the WHERE must not be in
+                    the "%{if}" parts.
+    select-star     SELECT * is used.
+    replace-string  The replace placeholder "#{}" is fed by a
+                    string parameter of the Go method, which
+                    might cause sql injection.
+    limit-prepare   The prepare placeholder "${}" in LIMIT or
+                    OFFSET is fed by a non-integer parameter
+                    (such as string), which is rejected by
+                    mysql. Only checked for "mysql".
+    unused-var      The "+gen:var" is not referenced by any
+                    method.
+    unreferenced    The method is not referenced by any Go
+                    interface.
+
+If any problem is found, the command exits with non-zero code.
+
+Command Flags:
+    <path>
+         The sql file or the directory.
+    --db-type <type>
+         The sql dialect, "mysql", "postgres" or "sqlite",
+         default is "mysql".
+    --skip <rules>
+         The rules to skip, split by ",".
+    --log
+         Show the log.
+
+Example:
+    go-gendb lint ./user/user.sql
+    go-gendb lint --skip select-star,unreferenced ./db`
@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"github.com/fioncat/go-gendb/generate"
+	"github.com/fioncat/go-gendb/misc/cmdt"
+)
+
+var Cmder = &cmdt.Command{
+	Name: "migrate",
+	Pv:   (*generate.MigrateArg)(nil),
+
+	Usage: "migrate [-d <dir>] [--name <name>] [--allow-destructive] <path>",
+	Help:  help,
+
+	Action: func(p interface{}) error {
+		return generate.Migrate(p.(*generate.MigrateArg))
+	},
+}
+
+const help = `
+Migrate compares the orm-sql declarations in the Go source
+file with the database schema, and writes the migration
+files to change the schema to the declarations:
+
+    {dir}/{timestamp}_{name}.up.sql
+    {dir}/{timestamp}_{name}.down.sql
+
+The database is chosen by the "conn" or "schema" option of
+the file, just like "gen". The migrations include creating
+tables, adding, modifying and dropping columns, primary key,
+unique key and index changes, and comment changes. The tables
+that are not declared in the file are ignored. If there is no
+change, nothing is written.
+
+The changes that may lose data or constraints (dropping
+columns or indexes, changing column types or the primary key)
+are refused, unless the "--allow-destructive" flag is given.
+
+Only mysql is supported now.
+
+Command Flags:
+    <path>
+         The Go source file of orm-sql.
+    -d <dir>
+         The directory to write the migration files, default is
+         "migrations".
+    --name <name>
+         The name of the migration, default is "migrate".
+    --schema <path>
+         Compare with the DDL files or the schema snapshot rather
+         than the database.
+    --allow-destructive
+         Write the destructive changes.
+    --log
+         Show the log.
+
+Example:
+    go-gendb migrate ./user/user_orm.go
+    go-gendb migrate -d ./db/migrations --name add_user_phone ./user/user_orm.go
+    go-gendb migrate --schema ./gendb.schema.json ./user/user_orm.go
+
+See also: gen, schema, pull-orm`
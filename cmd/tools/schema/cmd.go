@@ -0,0 +1,66 @@
+package schema
+
+import (
+	"github.com/fioncat/go-gendb/database/tools/schema"
+	"github.com/fioncat/go-gendb/misc/cmdt"
+)
+
+var Cmder = &cmdt.Command{
+	Name: "schema",
+	Pv:   (*schema.Arg)(nil),
+
+	Usage: "schema [-f <file>] [--tables <t1,t2>] <pull|diff> <conn>",
+	Help:  help,
+
+	Action: func(p interface{}) error {
+		return schema.Do(p.(*schema.Arg))
+	},
+}
+
+const help = `
+Schema manages the schema snapshot, which is a file records
+the structure of the data tables in a database. Unlike the
+table cache (see "gen --cache"), the snapshot has no TTL and
+is designed to be committed into the repository, so that
+everyone can generate the same code without database
+connection.
+
+Actions:
+    pull
+         Describe the tables from the database and write
+         them into the snapshot file.
+    diff
+         Compare the snapshot file with the database, and
+         report the drift between them. If there is any
+         drift, the command exits with 1.
+
+The snapshot is encoded as yaml if the file extension is
+".yaml" or ".yml", otherwise json.
+
+To generate code from the snapshot, use "gen --schema <file>",
+or add the file option "schema=<file>" to the source file.
+
+Command Flags:
+    <conn>
+         The connection key, see "go-gendb help conn".
+    -f <file>
+         The snapshot file, default is "gendb.schema.json".
+    --tables <t1,t2,...>
+         The tables to pull or diff, separated by ",". By default,
+         pull will describe all tables in the database, and diff
+         will compare the tables in the snapshot.
+    --all
+         For diff, also report the tables that exist in the
+         database but not in the snapshot.
+    --db-type <type>
+         The database type. For pull, the default is "mysql", for
+         diff, the default is the type recorded in the snapshot.
+    --log
+         Show the log.
+
+Example:
+    go-gendb schema pull local
+    go-gendb schema -f ./gendb.schema.yaml --tables user,detail pull local
+    go-gendb schema diff local
+
+See also: gen, conn`
@@ -42,12 +42,17 @@ func (c *Coder) Body() {
 }
 
 func (c *Coder) WriteFile(path string) error {
+	data := []byte(c.String())
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// String returns the code that has been written.
+func (c *Coder) String() string {
 	lines := make([]string, len(c.lines))
 	for idx, line := range c.lines {
 		lines[idx] = strings.TrimRightFunc(line, unicode.IsSpace)
 	}
-	data := []byte(strings.Join(lines, "\n"))
-	return ioutil.WriteFile(path, data, 0644)
+	return strings.Join(lines, "\n")
 }
 
 type SubCoder interface {
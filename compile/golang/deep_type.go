@@ -7,6 +7,13 @@ import (
 	"github.com/fioncat/go-gendb/compile/token"
 )
 
+const _map = token.Token("map")
+
+var _typeTokens = []token.Token{
+	token.LBRACK, token.RBRACK, token.MUL,
+	token.PERIOD, _map,
+}
+
 type DeepType struct {
 	Slice *DeepType `json:"slice,omitempty"`
 	Map   *DeepMap  `json:"map,omitempty"`
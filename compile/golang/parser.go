@@ -2,10 +2,20 @@ package golang
 
 import (
 	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/fioncat/go-gendb/compile/base"
-	"github.com/fioncat/go-gendb/compile/token"
+	"github.com/fioncat/go-gendb/misc/errors"
 )
 
 // IsSimpleType returns whether t is a simple type,
@@ -32,489 +42,520 @@ func IsSimpleType(t string) bool {
 	return false
 }
 
-// Go keywords that need to be concerned, not all keywords here.
-const (
-	_package   = token.Token("package")
-	_import    = token.Token("import")
-	_type      = token.Token("type")
-	_interface = token.Token("interface")
-	_struct    = token.Token("struct")
-	_comment   = token.Token("//")
-	_map       = token.Token("map")
-)
-
-var (
-	// Uses to parse multi-line import:
-	//   import (
-	//     [{name}] {path}
-	//     ...
-	//   )
-	_importsTokens = []token.Token{
-		_import,
-		token.LPAREN,
-		token.RPAREN,
-	}
-
-	// Uses to parse struct definition:
-	//   type {name} struct {
-	_structTokens = []token.Token{
-		_type,
-		_struct,
-		token.LBRACE,
-	}
-
-	// Uses to parse field definition:
-	//   {name} {field} [// {comment}]
-	_fieldTokens = []token.Token{
-		_comment,
-	}
-
-	// Uses to parse interface definition:
-	//   type {name} interface {
-	_interfaceTokens = []token.Token{
-		_type,
-		_interface,
-		token.LBRACE,
-	}
+// astParser reads the declarations of a Go file from its
+// syntax tree. The "+gen:" tags are the comment lines
+// between the previous declaration (or field, method) and
+// the current one.
+//
+// The types are kept as the expressions in the source, the
+// file is not type checked. go/types is only used to find
+// the methods of the embedded interfaces declared in other
+// files or packages.
+type astParser struct {
+	path string
+	fset *token.FileSet
+	file *ast.File
+	out  *File
+
+	// the interfaces declared in the file, to resolve the
+	// embedded interfaces.
+	inters map[string]*ast.TypeSpec
+
+	// the type information of the package, it is checked
+	// only when an embedded interface is not declared in
+	// the file.
+	checked bool
+	pkg     *types.Package
+	info    *types.Info
+}
 
-	// Uses to parse method in interface:
-	//   {name}({param}) [{ret}]
-	_methodTokens = []token.Token{
-		token.LPAREN,
-		token.RPAREN,
-		token.LBRACK,
-		token.RBRACK,
-		token.MUL,
-		token.COMMA,
-		token.PERIOD,
+func newAstParser(path string, fset *token.FileSet, file *ast.File) *astParser {
+	p := &astParser{
+		path:   path,
+		fset:   fset,
+		file:   file,
+		inters: make(map[string]*ast.TypeSpec),
 	}
-
-	_typeTokens = []token.Token{
-		token.LBRACK, token.RBRACK, token.MUL,
-		token.PERIOD, _map,
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.InterfaceType); ok {
+				p.inters[ts.Name.Name] = ts
+			}
+		}
 	}
-)
-
-// _packageParser uses to parse package definition:
-//   package {name}
-type _packageParser struct{}
-
-// Accept returns whether line is a package definition line.
-func (*_packageParser) Accept(line string) bool {
-	return _package.PrefixOf(line)
+	return p
 }
 
-// Do parse package line and returns the package name(string).
-func (*_packageParser) Do(line string) (interface{}, error) {
-	line = _package.Trim(line)
-	if line == "" {
-		return nil, fmt.Errorf("missing package name")
-	}
-	return line, nil
+func (p *astParser) line(pos token.Pos) int {
+	return p.fset.Position(pos).Line
 }
 
-// _singleImportParser uses to parse single-line import content.
-//  import [{name}] {path}
-type _singleImportParser struct{}
-
-// Accept returns whether line is a import line.
-func (*_singleImportParser) Accept(line string) bool {
-	return _import.PrefixOf(line)
+// end returns the end of node, including its line comment.
+func end(node ast.Node, comment *ast.CommentGroup) token.Pos {
+	if comment != nil && comment.End() > node.End() {
+		return comment.End()
+	}
+	return node.End()
 }
 
-// Do parse the import line, returns *Import pointer.
-func (*_singleImportParser) Do(line string) (interface{}, error) {
-	line = _import.Trim(line)
-	if line == "" {
-		return nil, fmt.Errorf("missing import content")
-	}
-	s := token.NewScanner(line, nil)
-	eles := s.Gets()
-	imp := new(Import)
-	switch len(eles) {
-	case 1:
-		// no name: "{path}"
-		e := eles[0]
-		if !e.String {
-			return nil, e.NotMatch("STRING")
-		}
-		imp.Path = e.Get()
-
-	case 2:
-		// name and path: {name} "{path}"
-		alias := eles[0]
-		path := eles[1]
-		if !alias.Indent {
-			return nil, alias.NotMatch("INDENT")
-		}
-		if !path.String {
-			return nil, path.NotMatch("STRING")
-		}
-		imp.Name = alias.Get()
-		imp.Path = path.Get()
-
-	default:
-		// invalid
-		return nil, fmt.Errorf("import statement bad format")
+// tags returns the "+gen:" tags and the other comment lines
+// between from and to.
+func (p *astParser) tags(from, to token.Pos) ([]*base.Tag, []string, error) {
+	var tags []*base.Tag
+	var comms []string
+	for _, group := range p.file.Comments {
+		if group.End() <= from {
+			continue
+		}
+		if group.Pos() >= to {
+			break
+		}
+		for _, c := range group.List {
+			if c.Pos() < from || c.End() > to {
+				continue
+			}
+			line := p.line(c.Pos())
+			tag, err := base.ParseTag(line-1, commentPrefix, c.Text)
+			if err != nil {
+				return nil, nil, errors.Trace(line, err)
+			}
+			if tag != nil {
+				tags = append(tags, tag)
+				continue
+			}
+			comms = append(comms, commentText(c.Text))
+		}
 	}
-
-	return imp, nil
+	return tags, comms, nil
 }
 
-// _multiPathsParser parse multi-lines import:
-//   import (
-//     [{name}] {path}
-//     ...
-//   )
-type _multiPathsParser struct {
-	imps []*Import
+func commentText(text string) string {
+	if strings.HasPrefix(text, "/*") {
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+	} else {
+		text = strings.TrimPrefix(text, commentPrefix)
+	}
+	return strings.TrimSpace(text)
 }
 
-// If line is the beginning of a multi-line import, return
-// the parser; otherwise, return nil. The condition is whether
-// the line is "import ("
-func acceptPaths(line string) base.ScanParser {
-	tmp := strings.Fields(line)
-	if len(tmp) <= 1 {
-		return nil
+func (p *astParser) parse(file *File) error {
+	p.out = file
+	for _, spec := range p.file.Imports {
+		imp := new(Import)
+		if spec.Name != nil {
+			imp.Name = spec.Name.Name
+		}
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return errors.Trace(p.line(spec.Pos()), err)
+		}
+		imp.Path = path
+		file.Imports = append(file.Imports, imp)
 	}
-	if _import.Equal(tmp[0]) &&
-		token.LPAREN.Equal(tmp[1]) {
-		return new(_multiPathsParser)
+
+	prev := p.file.Name.End()
+	for _, decl := range p.file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			prev = decl.End()
+			continue
+		}
+		// Each spec of the grouped "type (...)" is handled
+		// as a single declaration.
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			tags, comms, err := p.tags(prev, ts.Pos())
+			if err != nil {
+				return err
+			}
+			prev = end(ts, ts.Comment)
+			if len(tags) == 0 {
+				continue
+			}
+			switch t := ts.Type.(type) {
+			case *ast.StructType:
+				s, err := p.structType(ts, t, tags, comms)
+				if err != nil {
+					return err
+				}
+				file.Structs = append(file.Structs, s)
+
+			case *ast.InterfaceType:
+				inter, err := p.interfaceType(ts, t, tags)
+				if err != nil {
+					return err
+				}
+				file.Interfaces = append(file.Interfaces, inter)
+			}
+		}
+		prev = gd.End()
 	}
 	return nil
 }
 
-func (p *_multiPathsParser) Next(_ int, line string, _ []*base.Tag) (bool, error) {
-	if token.RPAREN.Equal(line) {
-		return false, nil
+func (p *astParser) structType(ts *ast.TypeSpec, st *ast.StructType,
+	tags []*base.Tag, comms []string) (*Struct, error) {
+	s := new(Struct)
+	s.Name = ts.Name.Name
+	s.Tags = tags
+	s.Line = p.line(ts.Pos())
+	if len(comms) > 0 {
+		s.Comment = comms[0]
 	}
-	s := token.NewScanner(line, _importsTokens)
-	es := s.Gets()
-	imp := new(Import)
-	switch len(es) {
-	case 1:
-		if !es[0].String {
-			return false, es[0].NotMatch("STRING")
+
+	prev := st.Fields.Opening
+	for _, field := range st.Fields.List {
+		tags, _, err := p.tags(prev, field.Pos())
+		if err != nil {
+			return nil, err
 		}
-		imp.Path = es[0].Get()
+		prev = end(field, field.Comment)
 
-	case 2:
-		if !es[0].Indent {
-			return false, es[0].NotMatch("INDENT")
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			value, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, errors.Trace(p.line(field.Tag.Pos()), err)
+			}
+			tag = reflect.StructTag(value)
 		}
-		if !es[1].String {
-			return false, es[1].NotMatch("STRING")
+		var comms []string
+		if field.Comment != nil {
+			for _, c := range field.Comment.List {
+				comms = append(comms, commentText(c.Text))
+			}
 		}
-		imp.Name = es[0].Get()
-		imp.Path = es[1].Get()
 
-	default:
-		return false, fmt.Errorf("import bad format")
+		names := make([]string, 0, len(field.Names))
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) == 0 {
+			// embedded field, named by its type.
+			names = append(names, embeddedName(field.Type))
+		}
+		for _, name := range names {
+			f := new(Field)
+			f.Line = p.line(field.Pos())
+			f.Name = name
+			f.Type = types.ExprString(field.Type)
+			f.Comment = strings.Join(comms, " ")
+			f.StructTag = tag
+			f.Tags = tags
+			s.Fields = append(s.Fields, f)
+		}
 	}
-
-	p.imps = append(p.imps, imp)
-	return true, nil
+	return s, nil
 }
 
-func (p *_multiPathsParser) Get() interface{} {
-	return p.imps
-}
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
 
-type _structParser struct {
-	Struct *Struct
-}
+	case *ast.SelectorExpr:
+		return t.Sel.Name
 
-func acceptStruct(idx int, line string, tags []*base.Tag, comms []string) (
-	base.ScanParser, error,
-) {
-	if len(tags) == 0 {
-		return nil, nil
-	}
-	s := token.NewScanner(line, _structTokens)
-	es := s.Gets()
-	if len(es) != 4 {
-		return nil, nil
-	}
-	if es[0].Token != _type {
-		return nil, nil
-	}
-	if !es[1].Indent {
-		return nil, nil
-	}
-	if es[2].Token != _struct {
-		return nil, nil
-	}
-	if es[3].Token != token.LBRACE {
-		return nil, nil
-	}
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
 
-	_struct := new(Struct)
-	_struct.Name = es[1].Get()
-	_struct.Tags = tags
-	_struct.Line = idx + 1
-	if len(comms) > 0 {
-		_struct.Comment = comms[0]
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
 	}
-
-	p := new(_structParser)
-	p.Struct = _struct
-
-	return p, nil
+	return types.ExprString(expr)
 }
 
-func (p *_structParser) Next(idx int, line string, tags []*base.Tag) (
-	bool, error,
-) {
-	if token.RBRACE.Equal(line) {
-		return false, nil
+func (p *astParser) interfaceType(ts *ast.TypeSpec, it *ast.InterfaceType,
+	tags []*base.Tag) (*Interface, error) {
+	line := p.line(ts.Pos())
+	if len(tags) != 1 {
+		return nil, errors.TraceFmt(line, "only allow "+
+			"one tag to mark interface")
 	}
-	s := token.NewScanner(line, _fieldTokens)
-	var e token.Element
+	inter := new(Interface)
+	inter.Name = ts.Name.Name
+	inter.Tag = tags[0]
+	inter.line = line
 
-	ok := s.Next(&e)
-	if !ok {
-		return false, s.EarlyEnd("INDENT")
+	seen := map[string]bool{inter.Name: true}
+	methods, err := p.methods(it, seen)
+	if err != nil {
+		return nil, err
 	}
-	f := new(Field)
-	f.Name = e.Get()
+	inter.Methods = methods
+	return inter, nil
+}
 
-	ok = s.Next(&e)
-	if !ok {
-		return false, s.EarlyEnd("INDENT")
+// methods returns the methods of the interface, including
+// the ones of the embedded interfaces. The duplicate methods
+// (allowed by embedding) are only returned once.
+func (p *astParser) methods(it *ast.InterfaceType, seen map[string]bool) ([]*Method, error) {
+	var methods []*Method
+	names := make(map[string]bool)
+	add := func(ms ...*Method) {
+		for _, m := range ms {
+			if names[m.Name] {
+				continue
+			}
+			names[m.Name] = true
+			methods = append(methods, m)
+		}
 	}
-	f.Type = e.Get()
 
-	ok = s.Cur(&e)
-	if ok && e.String {
-		s.Next(nil)
-	}
+	prev := it.Methods.Opening
+	for _, field := range it.Methods.List {
+		tags, _, err := p.tags(prev, field.Pos())
+		if err != nil {
+			return nil, err
+		}
+		prev = end(field, field.Comment)
 
-	ok = s.Next(&e)
-	if ok {
-		if e.Token != _comment {
-			return false, e.NotMatch("COMMENT")
+		line := p.line(field.Pos())
+		if ft, ok := field.Type.(*ast.FuncType); ok {
+			m, err := p.method(field.Names[0].Name, ft, line, tags)
+			if err != nil {
+				return nil, err
+			}
+			add(m)
+			continue
 		}
-		var tmp []string
-		for s.Next(&e) {
-			tmp = append(tmp, e.Get())
+		ms, err := p.embedded(field.Type, line, tags, seen)
+		if err != nil {
+			return nil, err
 		}
-		f.Comment = strings.Join(tmp, " ")
+		add(ms...)
 	}
-	f.Tags = tags
-	f.Line = idx + 1
-
-	p.Struct.Fields = append(p.Struct.Fields, f)
-
-	return true, nil
-}
-
-func (p *_structParser) Get() interface{} {
-	return p.Struct
+	return methods, nil
 }
 
-type _interfaceParser struct {
-	inter *Interface
-}
+// embedded returns the methods of an embedded interface. The
+// interface declared in the file keeps the lines and tags of
+// its methods. Others are resolved by type checking the
+// package, their methods are reported at the embedded line,
+// with the tags of the embedded line.
+func (p *astParser) embedded(expr ast.Expr, line int,
+	tags []*base.Tag, seen map[string]bool) ([]*Method, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		ts := p.inters[t.Name]
+		if ts != nil && ts.TypeParams == nil {
+			if seen[t.Name] {
+				return nil, errors.TraceFmt(line, `invalid `+
+					`recursive interface "%s"`, t.Name)
+			}
+			seen[t.Name] = true
+			defer delete(seen, t.Name)
+			return p.methods(ts.Type.(*ast.InterfaceType), seen)
+		}
 
-func acceptInterface(idx int, line string, tags []*base.Tag, _ []string) (
-	base.ScanParser, error,
-) {
-	if len(tags) == 0 {
-		return nil, nil
-	}
-	s := token.NewScanner(line, _interfaceTokens)
-	es := s.Gets()
-	if len(es) != 4 {
+	case *ast.BinaryExpr, *ast.UnaryExpr:
+		// Type set of the constraint, such as "~int | string",
+		// it has no method.
 		return nil, nil
 	}
 
-	if es[0].Token != _type {
-		return nil, nil
-	}
-	if !es[1].Indent {
-		return nil, nil
-	}
-	if es[2].Token != _interface {
-		return nil, nil
+	name := types.ExprString(expr)
+	p.check()
+	tv, ok := p.info.Types[expr]
+	if !ok || tv.Type == nil || tv.Type == types.Typ[types.Invalid] {
+		return nil, errors.TraceFmt(line, `can not resolve `+
+			`embedded interface "%s"`, name)
 	}
-	if es[3].Token != token.LBRACE {
-		return nil, nil
-	}
-	if len(tags) != 1 {
-		return nil, fmt.Errorf("only allow one tag to mark interface")
-	}
-	p := new(_interfaceParser)
-	p.inter = new(Interface)
-	p.inter.Name = es[1].Get()
-	p.inter.Tag = tags[0]
-	p.inter.line = idx + 1
-
-	return p, nil
-}
-
-func (p *_interfaceParser) Next(idx int, line string, tags []*base.Tag) (bool, error) {
-	if token.RBRACE.Equal(line) {
-		return false, nil
-	}
-
-	s := token.NewScanner(line, _methodTokens)
-
-	method := new(Method)
-	method.line = idx + 1
-	method.Def = line
-	method.Tags = tags
-
-	var e token.Element
-	// Method Name
-	ok := s.Next(&e)
+	iface, ok := tv.Type.Underlying().(*types.Interface)
 	if !ok {
-		return false, s.EarlyEnd("INDENT")
-	}
-	if !e.Indent {
-		return false, e.NotMatch("INDENT")
+		return nil, errors.TraceFmt(line, `embedded "%s" `+
+			`is not an interface`, name)
+	}
+	methods := make([]*Method, 0, iface.NumMethods())
+	for i := 0; i < iface.NumMethods(); i++ {
+		fn := iface.Method(i)
+		def := types.TypeString(fn.Type(), p.qualifier)
+		fexpr, err := parser.ParseExpr(def)
+		if err != nil {
+			return nil, errors.Trace(line, err)
+		}
+		m, err := p.method(fn.Name(), fexpr.(*ast.FuncType), line, tags)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
 	}
-	method.Name = e.Get()
+	return methods, nil
+}
 
-	// Param List
-	ok = s.Next(&e)
-	if !ok {
-		return false, s.EarlyEnd("LPAREN")
+// qualifier names the packages by the imports of the file.
+func (p *astParser) qualifier(pkg *types.Package) string {
+	if pkg == p.pkg {
+		return ""
 	}
-	if e.Token != token.LPAREN {
-		return false, e.NotMatch("LPAREN")
-	}
-
-	// NOTE: We only handle imports in the param list
-	for {
-		ok = s.Next(&e)
-		if !ok {
-			return false, s.EarlyEnd("RPAREN")
-		}
-		if e.Token == token.RPAREN {
-			break
-		}
-		if e.Token != token.PERIOD {
+	for _, spec := range p.file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		if path != pkg.Path() {
 			continue
 		}
-
-		// handle import
-		ok = s.Pervious(&e, 2)
-		if !ok {
-			// Never trigger
-			return false, fmt.Errorf("[method] " +
-				"No content before PERIOD")
+		if spec.Name != nil {
+			return spec.Name.Name
 		}
-
-		if !e.Indent {
-			return false, e.NotMatch("INDENT")
-		}
-
-		name := e.Get()
-		method.Imports = append(method.Imports, name)
+		break
 	}
-
-	// Return List
-	ok = s.Next(&e)
-	if !ok {
-		return false, s.EarlyEnd("LPAREN")
+	// The package is not imported by the file, but used by
+	// the embedded methods, import it for the generated code.
+	for _, imp := range p.out.Imports {
+		if imp.Path == pkg.Path() {
+			return pkg.Name()
+		}
 	}
-	if e.Token != token.LPAREN {
-		return false, e.NotMatch("LPAREN")
+	imp := &Import{Path: pkg.Path()}
+	if filepath.Base(pkg.Path()) != pkg.Name() {
+		imp.Name = pkg.Name()
 	}
+	p.out.Imports = append(p.out.Imports, imp)
+	return pkg.Name()
+}
 
-	ok = s.Cur(&e)
-	if !ok {
-		return false, s.EarlyEnd("TOKEN")
-	}
-	if !e.Indent {
-		switch e.Token {
-		case token.LBRACK:
-			s.Next(nil)
-			ok = s.Next(&e)
-			if !ok {
-				return false, s.EarlyEnd("RBRACK")
-			}
-			if e.Token != token.RBRACK {
-				return false, e.NotMatch("RBRACK")
-			}
-			method.RetSlice = true
-
-			ok = s.Cur(&e)
-			if !ok {
-				return false, s.EarlyEnd("TOKEN")
+// check type checks the package of the file. The errors are
+// ignored, since the package might be incomplete before
+// generating.
+func (p *astParser) check() {
+	if p.checked {
+		return
+	}
+	p.checked = true
+	p.info = &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+	}
+
+	files := []*ast.File{p.file}
+	dir := filepath.Dir(p.path)
+	pkgPath := p.file.Name.Name
+	abs, _ := filepath.Abs(p.path)
+	pkg, err := build.ImportDir(dir, 0)
+	if err == nil {
+		if pkg.ImportPath != "" && pkg.ImportPath != "." {
+			pkgPath = pkg.ImportPath
+		}
+		for _, name := range pkg.GoFiles {
+			path := filepath.Join(dir, name)
+			if other, _ := filepath.Abs(path); other == abs {
+				continue
 			}
-			if e.Token == token.MUL {
-				method.RetPointer = true
-				s.Next(nil)
+			file, err := parser.ParseFile(p.fset, path, nil, 0)
+			if err != nil || file.Name.Name != p.file.Name.Name {
+				continue
 			}
-
-		case token.MUL:
-			method.RetPointer = true
-			s.Next(nil)
+			files = append(files, file)
 		}
 	}
 
-	ok = s.Next(&e)
-	if !ok {
-		return false, s.EarlyEnd("INDENT")
-	}
-	if !e.Indent {
-		return false, e.NotMatch("INDENT")
+	conf := types.Config{
+		Importer: importer.ForCompiler(p.fset, "source", nil),
+		Error:    func(error) {},
 	}
+	p.pkg, _ = conf.Check(pkgPath, p.fset, files, p.info)
+}
 
-	var next token.Element
-	s.Cur(&next)
-	if next.Token == token.PERIOD {
-		importName := e.Get()
-		method.Imports = append(method.Imports,
-			importName)
-		method.RetType = importName + "."
-		s.Next(nil)
-
-		ok = s.Next(&e)
+func (p *astParser) method(name string, ft *ast.FuncType,
+	line int, tags []*base.Tag) (*Method, error) {
+	method := new(Method)
+	method.line = line
+	method.Tags = tags
+	method.Name = name
+	// The signature is printed in one line, no matter how
+	// it is written.
+	method.Def = name + strings.TrimPrefix(types.ExprString(ft), "func")
+
+	imports := make(map[string]bool)
+	ast.Inspect(ft, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
 		if !ok {
-			return false, s.EarlyEnd("INDENT")
+			return true
 		}
-		if !e.Indent {
-			return false, e.NotMatch("INDENT")
+		if ident, ok := sel.X.(*ast.Ident); ok && !imports[ident.Name] {
+			imports[ident.Name] = true
+			method.Imports = append(method.Imports, ident.Name)
 		}
-	}
-	method.RetType += e.Get()
+		return false
+	})
 
-	ok = s.Next(&e)
-	if !ok {
-		return false, s.EarlyEnd("COMMA")
-	}
-	if e.Token != token.COMMA {
-		return false, e.NotMatch("COMMA")
+	for _, field := range ft.Params.List {
+		t := types.ExprString(field.Type)
+		if len(field.Names) == 0 {
+			method.Params = append(method.Params, &Param{Type: t})
+			continue
+		}
+		for _, name := range field.Names {
+			method.Params = append(method.Params,
+				&Param{Name: name.Name, Type: t})
+		}
 	}
 
-	ok = s.Next(&e)
-	if !ok {
-		return false, s.EarlyEnd("error")
+	rets := fieldTypes(ft.Results)
+	if len(rets) != 2 {
+		return nil, errors.TraceFmt(line, `expect 2 returns `+
+			`"(T, error)", found: %d`, len(rets))
 	}
-	if e.Get() != "error" {
-		return false, e.FmtErr(`expect "error" for the `+
-			`2nd returns, found: "%s"`, e.Get())
+	if errType := types.ExprString(rets[1]); errType != "error" {
+		return nil, errors.TraceFmt(line, `expect "error" for the `+
+			`2nd returns, found: "%s"`, errType)
 	}
 
-	ok = s.Next(&e)
-	if !ok {
-		return false, s.EarlyEnd("RPAREN")
+	ret := rets[0]
+	if at, ok := ret.(*ast.ArrayType); ok && at.Len == nil {
+		method.RetSlice = true
+		ret = at.Elt
 	}
-	if e.Token != token.RPAREN {
-		return false, e.NotMatch("RPAREN")
+	if star, ok := ret.(*ast.StarExpr); ok {
+		method.RetPointer = true
+		ret = star.X
 	}
+	switch ret.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		// generic type, such as "Page[User]"
 
+	default:
+		return nil, errors.TraceFmt(line, `unsupported return `+
+			`type "%s"`, types.ExprString(rets[0]))
+	}
+	method.RetType = types.ExprString(ret)
 	if IsSimpleType(method.RetType) {
 		method.RetSimple = true
 	}
-	p.inter.Methods = append(p.inter.Methods, method)
 
-	return true, nil
+	return method, nil
 }
 
-func (p *_interfaceParser) Get() interface{} {
-	return p.inter
+// fieldTypes returns the type of each item in the list, the
+// names sharing one type ("a, b int") are expanded.
+func fieldTypes(list *ast.FieldList) []ast.Expr {
+	if list == nil {
+		return nil
+	}
+	var ts []ast.Expr
+	for _, field := range list.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			ts = append(ts, field.Type)
+		}
+	}
+	return ts
+}
+
+// parseError converts the syntax error of go/parser to the
+// error with line.
+func parseError(err error) error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) == 0 {
+		return err
+	}
+	e := list[0]
+	return errors.Trace(e.Pos.Line, fmt.Errorf("%s", e.Msg))
 }
@@ -2,107 +2,335 @@ package golang
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestParseSingleImport(t *testing.T) {
-	var lines = []string{
-		`import "github.com/a/b"`,
-		`import user "aa/bb/user"`,
-		`import "fmt/base`,
-		`var a string`,
-		``,
-		`const bbb int64`,
-	}
-
-	p := new(_singleImportParser)
-
-	for idx, line := range lines {
-		ok := p.Accept(line)
-		if ok {
-			v, err := p.Do(line)
-			if err != nil {
-				fmt.Printf("idx=%d, err=%v\n", idx, err)
-				return
-			}
-			imp := v.(*Import)
-			fmt.Printf("import %s %s\n", imp.Name, imp.Path)
-		}
+func readTestFile(t *testing.T, content string) *File {
+	lines := strings.Split(content, "\n")
+	file, err := ReadLines("test.go", lines)
+	if err != nil {
+		t.Fatal(err)
 	}
-
+	return file
 }
 
-func TestParseMultiImports(t *testing.T) {
-	var lines = []string{
-		"import (",
-		`aaa "github.com/a/b/aab"`,
-		`"fmt"`,
-		`"strings"`,
-		`"testing"`,
-		`"model "d/a""`,
-		")",
+// methodString formats the parsed method, such as
+// "Find(id int64) (*User, error) | params=[id int64] ret=*User simple=false imports=[] tags=0".
+func methodString(m *Method) string {
+	params := make([]string, len(m.Params))
+	for idx, p := range m.Params {
+		params[idx] = strings.TrimSpace(p.Name + " " + p.Type)
 	}
-	p := acceptPaths(lines[0])
-	if p == nil {
-		fmt.Println("imports is bad")
-		return
+	ret := m.RetType
+	if m.RetPointer {
+		ret = "*" + ret
 	}
-	lines = lines[1:]
-	for idx, line := range lines {
-		ok, err := p.Next(idx, line, nil)
-		if err != nil {
-			fmt.Printf("idx=%d, err=%v\n", idx, err)
+	if m.RetSlice {
+		ret = "[]" + ret
+	}
+	return fmt.Sprintf("%s | params=[%s] ret=%s simple=%v imports=%v tags=%d line=%d",
+		m.Def, strings.Join(params, ", "), ret, m.RetSimple,
+		m.Imports, len(m.Tags), m.line)
+}
+
+func expectMethods(t *testing.T, inter *Interface, expects []string) {
+	if len(inter.Methods) != len(expects) {
+		for _, m := range inter.Methods {
+			t.Log(methodString(m))
 		}
-		if !ok {
-			break
+		t.Fatalf("interface %s: %d methods, expect %d", inter.Name,
+			len(inter.Methods), len(expects))
+	}
+	for idx, m := range inter.Methods {
+		got := methodString(m)
+		if got != expects[idx] {
+			t.Errorf("interface %s method %d:\n got: %s\nwant: %s",
+				inter.Name, idx, got, expects[idx])
 		}
 	}
+}
+
+func TestParseImports(t *testing.T) {
+	file := readTestFile(t, `// +gen:sql v=0.3
+package test
+
+import "fmt"
+import user "aa/bb/user"
 
-	imps := p.Get().([]Import)
-	for _, imp := range imps {
-		fmt.Printf("%s %s\n", imp.Name, imp.Path)
+import (
+	aaa "github.com/a/b/aab"
+	"strings"
+	_ "github.com/go-sql-driver/mysql"
+)
+`)
+	expects := []Import{
+		{"", "fmt"},
+		{"user", "aa/bb/user"},
+		{"aaa", "github.com/a/b/aab"},
+		{"", "strings"},
+		{"_", "github.com/go-sql-driver/mysql"},
+	}
+	if file.Package != "test" || file.Type != "sql" {
+		t.Fatalf("package=%s type=%s", file.Package, file.Type)
+	}
+	if len(file.Imports) != len(expects) {
+		t.Fatalf("%d imports, expect %d", len(file.Imports), len(expects))
+	}
+	for idx, imp := range file.Imports {
+		if *imp != expects[idx] {
+			t.Errorf("import %d: got %v, expect %v", idx, *imp, expects[idx])
+		}
 	}
 }
 
 func TestParseInterface(t *testing.T) {
-	var lines = []string{
-		"type User interface {",
-		"    Add(db *sql.DB, u *model.User) (int64, error)",
-		"    FindById(db *sql.DB, id int64) (*model.User, error)",
-		"    FindAll(db *sql.DB, query []string) ([]*model.User, error)",
-		"    Search(query []string) ([]model.User, error)",
-		"    Search(qs []string) ([]*User, error)",
-		"    Do(a runner.Cond) (User, error)",
-		"    Do2(b runner.Many) ([]string, error)",
-		"}",
-	}
-	p, err := acceptInterface(0, lines[0], nil, nil)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	if p == nil {
-		fmt.Println("interface is bad")
-		return
-	}
-	lines = lines[1:]
-	for idx, line := range lines {
-		ok, err := p.Next(idx, line, nil)
-		if err != nil {
-			fmt.Printf("idx: %d, error: %v\n", idx, err)
-			return
-		}
-		if !ok {
-			break
+	file := readTestFile(t, `// +gen:sql v=0.3
+package test
+
+// +gen:sql name=User file=user.sql
+type User interface {
+	Add(db *sql.DB, u *model.User) (int64, error)
+	FindById(db *sql.DB, id int64) (*model.User, error)
+	FindAll(db *sql.DB, query []string) ([]*model.User, error)
+	Search(query []string) ([]model.User, error)
+	// +gen:auto-ret
+	Search2(qs []string) ([]*User, error)
+	Do(a runner.Cond) (User, error)
+	Do2(b runner.Many, _ int, rest ...string) ([]string, error)
+
+	// multi-line signature
+	Find(
+		name string,
+		age, limit int, // the age
+	) (
+		[]*model.User,
+		error,
+	)
+}
+
+type Other interface {
+	Find() (int, error)
+}
+`)
+	if len(file.Interfaces) != 1 {
+		t.Fatalf("%d interfaces, expect 1", len(file.Interfaces))
+	}
+	inter := file.Interfaces[0]
+	if inter.Name != "User" || inter.Tag.Name != "sql" || inter.line != 5 {
+		t.Fatalf("name=%s tag=%s line=%d", inter.Name, inter.Tag.Name, inter.line)
+	}
+	expectMethods(t, inter, []string{
+		"Add(db *sql.DB, u *model.User) (int64, error) | params=[db *sql.DB, u *model.User] ret=int64 simple=true imports=[sql model] tags=0 line=6",
+		"FindById(db *sql.DB, id int64) (*model.User, error) | params=[db *sql.DB, id int64] ret=*model.User simple=false imports=[sql model] tags=0 line=7",
+		"FindAll(db *sql.DB, query []string) ([]*model.User, error) | params=[db *sql.DB, query []string] ret=[]*model.User simple=false imports=[sql model] tags=0 line=8",
+		"Search(query []string) ([]model.User, error) | params=[query []string] ret=[]model.User simple=false imports=[model] tags=0 line=9",
+		"Search2(qs []string) ([]*User, error) | params=[qs []string] ret=[]*User simple=false imports=[] tags=1 line=11",
+		"Do(a runner.Cond) (User, error) | params=[a runner.Cond] ret=User simple=false imports=[runner] tags=0 line=12",
+		"Do2(b runner.Many, _ int, rest ...string) ([]string, error) | params=[b runner.Many, _ int, rest ...string] ret=[]string simple=true imports=[runner] tags=0 line=13",
+		"Find(name string, age, limit int) ([]*model.User, error) | params=[name string, age int, limit int] ret=[]*model.User simple=false imports=[model] tags=0 line=16",
+	})
+	if tag := inter.Methods[4].Tags[0]; tag.Name != "auto-ret" {
+		t.Errorf("tag of Search2: %s", tag.Name)
+	}
+}
+
+func TestParseEmbedded(t *testing.T) {
+	file := readTestFile(t, `// +gen:sql v=0.3
+package test
+
+import "io"
+
+type Base interface {
+	// +gen:auto-ret
+	Count(table string) (int64, error)
+}
+
+type Reader interface {
+	Base
+	Get(id int64) (*User, error)
+}
+
+// +gen:sql name=User file=user.sql
+type UserOper interface {
+	Reader
+	Base
+	io.Reader
+	Delete(id int64) (int64, error)
+}
+`)
+	if len(file.Interfaces) != 1 {
+		t.Fatalf("%d interfaces, expect 1", len(file.Interfaces))
+	}
+	// The methods declared in the file keep their lines and
+	// tags, the ones of the other packages are reported at the
+	// embedded line.
+	expectMethods(t, file.Interfaces[0], []string{
+		"Count(table string) (int64, error) | params=[table string] ret=int64 simple=true imports=[] tags=1 line=8",
+		"Get(id int64) (*User, error) | params=[id int64] ret=*User simple=false imports=[] tags=0 line=13",
+		"Read(p []byte) (n int, err error) | params=[p []byte] ret=int simple=true imports=[] tags=0 line=20",
+		"Delete(id int64) (int64, error) | params=[id int64] ret=int64 simple=true imports=[] tags=0 line=21",
+	})
+}
+
+func TestParseGeneric(t *testing.T) {
+	file := readTestFile(t, `// +gen:sql v=0.3
+package test
+
+type Page[T any] struct {
+	Total int64
+	Items []T
+}
+
+// +gen:sql name=Repo file=repo.sql
+type Repo[T any] interface {
+	FindPage(offset, limit int) (*Page[T], error)
+	FindMap(keys []string) (Pair[string, T], error)
+}
+`)
+	if len(file.Interfaces) != 1 || len(file.Structs) != 0 {
+		t.Fatalf("%d interfaces, %d structs", len(file.Interfaces), len(file.Structs))
+	}
+	expectMethods(t, file.Interfaces[0], []string{
+		"FindPage(offset, limit int) (*Page[T], error) | params=[offset int, limit int] ret=*Page[T] simple=false imports=[] tags=0 line=11",
+		"FindMap(keys []string) (Pair[string, T], error) | params=[keys []string] ret=Pair[string, T] simple=false imports=[] tags=0 line=12",
+	})
+}
+
+func TestParseStruct(t *testing.T) {
+	file := readTestFile(t, `// +gen:orm v=0.3
+package test
+
+type (
+	// User is the user table.
+	// +gen:orm table=user
+	User struct {
+		// +gen:orm pk
+		Id   int64  `+"`json:\"id\" db:\"id\"`"+` // the id
+		Name string `+"`json:\"name, omitempty\"`"+`
+
+		A, B int
+
+		*time.Time
+	}
+
+	Status int
+
+	// +gen:orm
+	Detail struct {
+		UserId int64 // user id
+	}
+)
+`)
+	if len(file.Structs) != 2 {
+		t.Fatalf("%d structs, expect 2", len(file.Structs))
+	}
+	user, detail := file.Structs[0], file.Structs[1]
+	if user.Name != "User" || user.Line != 7 || user.Comment != "User is the user table." ||
+		len(user.Tags) != 1 || user.Tags[0].Options[0].Value != "user" {
+		t.Fatalf("struct User: %+v", user)
+	}
+	type field struct {
+		line    int
+		name    string
+		_type   string
+		tag     reflect.StructTag
+		comment string
+		tags    int
+	}
+	expects := []field{
+		{9, "Id", "int64", `json:"id" db:"id"`, "the id", 1},
+		{10, "Name", "string", `json:"name, omitempty"`, "", 0},
+		{12, "A", "int", "", "", 0},
+		{12, "B", "int", "", "", 0},
+		{14, "Time", "*time.Time", "", "", 0},
+	}
+	if len(user.Fields) != len(expects) {
+		t.Fatalf("%d fields, expect %d", len(user.Fields), len(expects))
+	}
+	for idx, f := range user.Fields {
+		got := field{f.Line, f.Name, f.Type, f.StructTag, f.Comment, len(f.Tags)}
+		if got != expects[idx] {
+			t.Errorf("field %d: got %+v, expect %+v", idx, got, expects[idx])
 		}
 	}
-	inter := p.Get().(*Interface)
-	fmt.Printf("name = %s\n", inter.Name)
-	for _, m := range inter.Methods {
-		fmt.Println(m.Def)
-		fmt.Printf("MethodName=%s, Imports=%v, RetSlice=%v, RetP=%v, RetSimple=%v, RetType=%s\n",
-			m.Name, m.Imports, m.RetSlice, m.RetPointer,
-			m.RetSimple, m.RetType)
+	if user.Fields[0].StructTag.Get("db") != "id" {
+		t.Errorf("db tag of Id: %q", user.Fields[0].StructTag.Get("db"))
 	}
 
+	if detail.Name != "Detail" || len(detail.Fields) != 1 {
+		t.Fatalf("struct Detail: %+v", detail)
+	}
+	f := detail.Fields[0]
+	if f.Name != "UserId" || f.Type != "int64" || f.Comment != "user id" {
+		t.Errorf("field of Detail: %+v", f)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		src string
+		msg string
+	}{
+		{`// +gen:sql v=0.3
+package test
+
+// +gen:sql name=User file=user.sql
+type User interface {
+	Find(id int64) *User
+}
+`, `expect 2 returns`},
+		{`// +gen:sql v=0.3
+package test
+
+// +gen:sql name=User file=user.sql
+type User interface {
+	Find(id int64) (*User, string)
+}
+`, `expect "error" for the 2nd returns`},
+		{`// +gen:sql v=0.3
+package test
+
+// +gen:sql name=User file=user.sql
+type User interface {
+	Find(id int64) (map[string]int, error)
+}
+`, `unsupported return type`},
+		{`// +gen:sql v=0.3
+package test
+
+// +gen:sql name=User file=user.sql
+type User interface {
+	User
+	Find(id int64) (int, error)
+}
+`, `invalid recursive interface`},
+		{`// +gen:sql v=0.3
+package test
+
+// +gen:sql name=User file=user.sql
+type User interface {
+	Missing
+}
+`, `can not resolve embedded interface "Missing"`},
+		{`// +gen:sql v=0.3
+package test
+
+type User interface {
+	Find(id int64 (*User, error)
+}
+`, ``},
+	}
+	for idx, c := range cases {
+		lines := strings.Split(c.src, "\n")
+		_, err := ReadLines("test.go", lines)
+		if err == nil {
+			t.Errorf("case %d: expect error", idx)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("case %d: got %q, expect %q", idx, err.Error(), c.msg)
+		}
+	}
 }
@@ -2,6 +2,7 @@ package golang
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/fioncat/go-gendb/compile/base"
 	"github.com/fioncat/go-gendb/misc/errors"
@@ -33,10 +34,15 @@ type Method struct {
 	Name    string
 	Imports []string
 
+	Params []*Param
+
 	RetSlice   bool
 	RetPointer bool
 	RetSimple  bool
 
+	// RetType is the element type of the first return as
+	// written in the source, such as "model.User" of
+	// "[]*model.User".
 	RetType string
 
 	Def string
@@ -47,6 +53,16 @@ func (m *Method) FmtError(a string, b ...interface{}) error {
 	return errors.Trace(m.line, err)
 }
 
+// Param is a parameter of the method, the Name is empty if
+// the parameter is not named. The Type is the expression in
+// the source, such as "*model.User", it is not resolved: the
+// file is only parsed, the sql linker type checks the
+// methods with the package when generating.
+type Param struct {
+	Name string
+	Type string
+}
+
 type Struct struct {
 	Line int
 
@@ -65,5 +81,9 @@ type Field struct {
 	Type    string
 	Comment string
 
+	// StructTag is the tag in backticks, such as
+	// `json:"name,omitempty" db:"name"`.
+	StructTag reflect.StructTag
+
 	Tags []*base.Tag
 }
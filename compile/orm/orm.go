@@ -16,6 +16,10 @@ type Result struct {
 	Name  string
 	Table string
 
+	// Conn is the "conn" option of the struct, the table
+	// is in the database of it rather than the global one.
+	Conn string
+
 	Comment string
 
 	Fields []*Field
@@ -74,7 +78,11 @@ func newResult(name string, cap int) *Result {
 	return r
 }
 
-func fromDatabase(tableName, name string) (*Result, error) {
+// FromDatabase describes the table from the database and
+// converts it to Result, the nullable fields are converted
+// according to the null strategy. If name is empty, it is
+// converted from the table name.
+func FromDatabase(tableName, name, null string) (*Result, error) {
 	table, err := rdb.Get().Desc(tableName)
 	if err != nil {
 		return nil, err
@@ -84,6 +92,8 @@ func fromDatabase(tableName, name string) (*Result, error) {
 		name = coder.GoName(tableName)
 	}
 	r := newResult(name, len(fieldNames))
+	r.Table = tableName
+	r.Comment = table.GetComment()
 	for _, fieldName := range fieldNames {
 		field := table.Field(fieldName)
 		if field.IsPrimaryKey() {
@@ -95,11 +105,21 @@ func fromDatabase(tableName, name string) (*Result, error) {
 		rf.DbType = field.GetType()
 		rf.DbType = strings.ToUpper(rf.DbType)
 		rf.GoName = coder.GoName(rf.DbName)
-		rf.GoType = rdb.Get().GoType(rf.DbType)
+		rf.GoType = rdb.Get().FieldType(field, null)
 		rf.AutoIncr = field.IsAutoIncr()
+		rf.NotNull = !field.IsNullable()
+		rf.Comment = field.GetComment()
+		rf.Default = field.GetDefault()
 
 		r.addField(rf)
 	}
+	for _, idx := range table.GetIndexes() {
+		if idx.Unique {
+			r.addUnique(0, idx.Fields)
+		} else {
+			r.addIdx(0, idx.Fields)
+		}
+	}
 	err = r.parseKeys(false)
 	if err != nil {
 		// Never trigger, prevent
@@ -109,6 +129,15 @@ func fromDatabase(tableName, name string) (*Result, error) {
 	return r, nil
 }
 
+// Session returns the database session of the struct,
+// see the "conn" option.
+func (r *Result) Session() (*rdb.Session, error) {
+	if r.Conn == "" {
+		return rdb.Get(), nil
+	}
+	return rdb.Use(r.Conn)
+}
+
 func (r *Result) addIdx(line int, names []string) {
 	r.idxLines = append(r.idxLines, line)
 	r.idxNames = append(r.idxNames, names)
@@ -223,6 +252,19 @@ func parse(gfile *golang.File, mgo bool) ([]*Result, error) {
 		replaceGoType(rs)
 	}
 
+	// The strategy for nullable fields of the imported tables.
+	var null string
+	for _, opt := range gfile.Options {
+		if opt.Key != "null" {
+			continue
+		}
+		err := rdb.CheckNull(opt.Value)
+		if err != nil {
+			return nil, opt.Trace(err)
+		}
+		null = opt.Value
+	}
+
 	for _, opt := range gfile.Options {
 		if mgo || opt.Key != "import_table" {
 			continue
@@ -244,9 +286,9 @@ func parse(gfile *golang.File, mgo bool) ([]*Result, error) {
 				name = arr[1]
 
 			default:
-				return nil, opt.FmtError(`import_table "%s" is bad format`)
+				return nil, opt.FmtError(`import_table "%s" is bad format`, opt.Value)
 			}
-			r, err := fromDatabase(table, name)
+			r, err := FromDatabase(table, name, null)
 			if err != nil {
 				return nil, opt.Trace(err)
 			}
@@ -274,6 +316,16 @@ var structOptionMap = map[string]base.DecodeOptionFunc{
 		return nil
 	},
 
+	"conn": func(_ int, val string, vs []interface{}) error {
+		r := vs[0].(*Result)
+		_, _, err := rdb.ParseConn(val)
+		if err != nil {
+			return err
+		}
+		r.Conn = val
+		return nil
+	},
+
 	"primary": func(line int, val string, vs []interface{}) error {
 		r := vs[0].(*Result)
 		arr, err := base.Arr1(val)
@@ -402,7 +454,11 @@ func parseStruct(stc *golang.Struct, mgo bool) (*Result, error) {
 			rf.DbName = coder.DbName(rf.GoName)
 		}
 		if rf.DbType == "" && !mgo {
-			rf.DbType = rdb.Get().SqlType(rf.GoType)
+			sess, err := r.Session()
+			if err != nil {
+				return nil, errors.Trace(r.line, err)
+			}
+			rf.DbType = sess.SqlType(rf.GoType)
 		}
 		r.addField(rf)
 	}
@@ -18,6 +18,8 @@ type File struct {
 	Path string
 
 	Methods []*Method
+
+	Vars []*Var
 }
 
 type acceptAction func(tag *base.Tag) (base.ScanParser, error)
@@ -108,6 +110,9 @@ func readLines(path string, lines []string) (*File, error) {
 			m.Tags = append(m.Tags, tags...)
 			tags = nil
 			file.Methods = append(file.Methods, m)
+
+		case *Var:
+			file.Vars = append(file.Vars, v.(*Var))
 		}
 	}
 
@@ -115,9 +120,11 @@ func readLines(path string, lines []string) (*File, error) {
 		if m.State != nil {
 			flatState(m.State)
 		}
-		for _, dp := range m.Dps {
-			flatState(dp.State)
-		}
+		WalkDynamic(m.Dps, func(dp *DynamicPart) {
+			if dp.State != nil {
+				flatState(dp.State)
+			}
+		})
 	}
 
 	log.Infof("[compile] [sql] [%v] %s, %d method(s)",
@@ -130,6 +137,7 @@ func flatState(state *Statement) {
 	for _, ph := range state.phs {
 		if ph.pre {
 			state.Prepares = append(state.Prepares, ph.name)
+			state.PreparePos = append(state.PreparePos, ph.pos)
 			continue
 		}
 		state.Replaces = append(state.Replaces, ph.name)
@@ -2,8 +2,10 @@ package sql
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/fioncat/go-gendb/compile/base"
 	"github.com/fioncat/go-gendb/compile/token"
@@ -117,6 +119,7 @@ func parseNameFromTag(t string, tag *base.Tag) (string, error) {
 }
 
 type _varParser struct {
+	line int
 	name string
 	sqls *token.Scanner
 	phs  *token.Scanner
@@ -132,6 +135,7 @@ func acceptVar(tag *base.Tag) (base.ScanParser, error) {
 	}
 
 	p := new(_varParser)
+	p.line = tag.Line
 	p.name = name
 	p.sqls = token.EmptyScannerIC(sqlTokens)
 	p.phs = token.EmptyScanner(phTokens)
@@ -165,7 +169,7 @@ func (p *_varParser) Get() interface{} {
 	}
 	globalVars.Store(p.name, sv)
 
-	return nil
+	return &Var{line: p.line, Name: p.name}
 }
 
 type _sqlParser struct {
@@ -178,6 +182,9 @@ type _sqlParser struct {
 
 	dyn bool
 
+	chunk     string
+	chunkSize int
+
 	tags []*base.Tag
 }
 
@@ -212,17 +219,41 @@ func acceptSql(tag *base.Tag) (base.ScanParser, error) {
 
 	opts := tag.Options[1:]
 	for _, opt := range opts {
-		if opt.Key == "dyn" {
+		switch opt.Key {
+		case "dyn":
 			switch opt.Value {
 			case "true":
 				p.dyn = true
 			}
+
+		case "chunk":
+			p.chunk, p.chunkSize, err = parseChunk(opt.Value)
+			if err != nil {
+				return nil, opt.Trace(err)
+			}
 		}
 	}
 
 	return p, nil
 }
 
+// parseChunk parses the "chunk" option, such as "ids:500",
+// the name of the slice parameter and the max size of the
+// chunk.
+func parseChunk(v string) (string, int, error) {
+	tmp := strings.Split(v, ":")
+	if len(tmp) != 2 || tmp[0] == "" {
+		return "", 0, fmt.Errorf(`chunk "%s" is `+
+			`bad format, should be "{param}:{size}"`, v)
+	}
+	size, err := strconv.Atoi(tmp[1])
+	if err != nil || size <= 0 {
+		return "", 0, fmt.Errorf(`chunk size "%s" `+
+			`is not a positive integer`, tmp[1])
+	}
+	return tmp[0], size, nil
+}
+
 func (p *_sqlParser) Next(idx int, line string, tags []*base.Tag) (
 	bool, error,
 ) {
@@ -236,11 +267,11 @@ func (p *_sqlParser) Get() interface{} {
 	if p.sqls.Empty() || p.phs.Empty() {
 		return errors.TraceFmt(p.line, "method is empty")
 	}
-	sqls, err := parseVars(p.sqls, true)
+	sqls, vars, err := parseVars(p.sqls, true)
 	if err != nil {
 		return err
 	}
-	phs, err := parseVars(p.phs, false)
+	phs, _, err := parseVars(p.phs, false)
 	if err != nil {
 		return err
 	}
@@ -250,15 +281,19 @@ func (p *_sqlParser) Get() interface{} {
 		return err
 	}
 	m.line = p.line
+	m.Chunk = p.chunk
+	m.ChunkSize = p.chunkSize
 	m.Tags = p.tags
+	m.Vars = vars
 	return m
 }
 
-func parseVars(s *token.Scanner, isSqls bool) (*token.Scanner, error) {
+func parseVars(s *token.Scanner, isSqls bool) (*token.Scanner, []string, error) {
 	var e token.Element
 	var ok bool
 	var bucket []token.Element
 	var hasVar bool
+	var names []string
 	for {
 		ok = s.Next(&e)
 		if !ok {
@@ -272,17 +307,17 @@ func parseVars(s *token.Scanner, isSqls bool) (*token.Scanner, error) {
 
 		ok = s.Next(&e)
 		if !ok {
-			return nil, s.EarlyEndL("LBRACE")
+			return nil, nil, s.EarlyEndL("LBRACE")
 		}
 		if e.Token != token.LBRACE {
-			return nil, e.NotMatchL("LBRACE")
+			return nil, nil, e.NotMatchL("LBRACE")
 		}
 
 		var nameBucket []string
 		for {
 			ok = s.Next(&e)
 			if !ok {
-				return nil, s.EarlyEndL("RBRACE")
+				return nil, nil, s.EarlyEndL("RBRACE")
 			}
 			if e.Token == token.RBRACE {
 				break
@@ -290,14 +325,15 @@ func parseVars(s *token.Scanner, isSqls bool) (*token.Scanner, error) {
 			nameBucket = append(nameBucket, e.Get())
 		}
 		if len(nameBucket) == 0 {
-			return nil, e.FmtErrL("name is empty")
+			return nil, nil, e.FmtErrL("name is empty")
 		}
 		name := strings.Join(nameBucket, "")
 		v, ok := globalVars.Load(name)
 		if !ok {
-			return nil, e.FmtErrL(`can not find var "%s"`, name)
+			return nil, nil, e.FmtErrL(`can not find var "%s"`, name)
 		}
 		sqlVar := v.(*sqlVar)
+		names = append(names, name)
 		var es []token.Element
 		if isSqls {
 			es = sqlVar.sqlEs
@@ -309,9 +345,9 @@ func parseVars(s *token.Scanner, isSqls bool) (*token.Scanner, error) {
 	}
 	if !hasVar {
 		s.Reset()
-		return s, nil
+		return s, nil, nil
 	}
-	return token.CopyScanner(s, bucket), nil
+	return token.CopyScanner(s, bucket), names, nil
 }
 
 func parseMethod(sqls, phs *token.Scanner, name, inter string, dyn bool) (
@@ -693,16 +729,64 @@ func parsePh(s *token.Scanner) (*Statement, error) {
 		}
 		ph.name = strings.Join(nameBucket, "")
 
+		ph.pos = len(sqls)
 		sqls = append(sqls, phStr)
 		state.phs = append(state.phs, ph)
 	}
-	state.Sql = strings.Join(sqls, "")
-	state.Sql = strings.TrimSpace(state.Sql)
+	joined := strings.Join(sqls, "")
+	state.Sql = strings.TrimSpace(joined)
+
+	// convert the piece index of the placeholders to the
+	// offsets in the trimmed sql.
+	offset := len(strings.TrimLeftFunc(joined, unicode.IsSpace)) - len(joined)
+	pieceIdx := 0
+	for _, ph := range state.phs {
+		for ; pieceIdx < ph.pos; pieceIdx++ {
+			offset += len(sqls[pieceIdx])
+		}
+		ph.pos = offset
+	}
 
 	return state, nil
 }
 
 func parseDynamic(s *token.Scanner) ([]*DynamicPart, error) {
+	dps, tag, err := parseParts(s)
+	if err != nil {
+		return nil, err
+	}
+	if tag != nil {
+		return nil, tag.e.FmtErrL(`unexpected "%s"`, tag.name)
+	}
+
+	hasCond := false
+	for _, dp := range dps {
+		if dp.Type != DynamicTypeConst {
+			hasCond = true
+		}
+	}
+	if !hasCond {
+		return nil, fmt.Errorf("can not find dynamic " +
+			"condition, please add dynamic tag or " +
+			"change sql type to static(remove dyn=true)")
+	}
+
+	return dps, nil
+}
+
+// dynTag is the "%{name ...}" tag of the dynamic sql.
+type dynTag struct {
+	e    token.Element
+	name string
+
+	// the elements between the name and the RBRACE.
+	es []token.Element
+}
+
+// parseParts parses the parts until the end of the scanner,
+// or the tag which ends the body of the parent part, such as
+// "endif", "else", which is returned.
+func parseParts(s *token.Scanner) ([]*DynamicPart, *dynTag, error) {
 	var dps []*DynamicPart
 	var bucket []token.Element
 
@@ -711,6 +795,7 @@ func parseDynamic(s *token.Scanner) ([]*DynamicPart, error) {
 			return nil
 		}
 		os := token.CopyScanner(s, bucket)
+		bucket = nil
 		state, err := parsePh(os)
 		if err != nil {
 			return err
@@ -723,12 +808,9 @@ func parseDynamic(s *token.Scanner) ([]*DynamicPart, error) {
 			State: state,
 		}
 		dps = append(dps, dp)
-		bucket = nil
 		return nil
 	}
 
-	hasCond := false
-
 	var e token.Element
 	for {
 		ok := s.Next(&e)
@@ -754,30 +836,45 @@ func parseDynamic(s *token.Scanner) ([]*DynamicPart, error) {
 		}
 
 		if err := flushConst(); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		dp, err := parseCond(s)
+		tag, err := parseDynTag(s)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		var dp *DynamicPart
+		switch tag.name {
+		case "if":
+			dp, err = parseIf(s, tag)
+
+		case "for":
+			dp, err = parseFor(s, tag)
+
+		case "where":
+			dp, err = parseBlock(s, tag, DynamicTypeWhere, "endwhere")
+
+		case "set":
+			dp, err = parseBlock(s, tag, DynamicTypeSet, "endset")
+
+		case "switch":
+			dp, err = parseSwitch(s, tag)
+
+		default:
+			return dps, tag, nil
+		}
+		if err != nil {
+			return nil, nil, err
 		}
 		dps = append(dps, dp)
-		hasCond = true
 	}
 	if err := flushConst(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-
-	if !hasCond || len(dps) == 0 {
-		return nil, e.FmtErrL("can not find dynamic " +
-			"condition, please add dynamic tag or " +
-			"change sql type to static(remove dyn=true)")
-	}
-
-	return dps, nil
+	return dps, nil, nil
 }
 
-func parseCond(s *token.Scanner) (*DynamicPart, error) {
+func parseDynTag(s *token.Scanner) (*dynTag, error) {
 	skipSpace := func() {
 		var tmp token.Element
 		for {
@@ -794,8 +891,6 @@ func parseCond(s *token.Scanner) (*DynamicPart, error) {
 		}
 	}
 	var e token.Element
-	var subBucket []token.Element
-	dp := new(DynamicPart)
 	skipSpace()
 	ok := s.Next(&e)
 	if !ok {
@@ -804,124 +899,212 @@ func parseCond(s *token.Scanner) (*DynamicPart, error) {
 	if !e.Indent {
 		return nil, e.NotMatchL("IF/FOR")
 	}
-
-	switch e.Get() {
-	case "if":
-		dp.Type = DynamicTypeIf
-
-	case "for":
-		dp.Type = DynamicTypeFor
-
-	default:
-		return nil, e.NotMatchL("IF/FOR")
-	}
-	if dp.Type == 0 {
-		// Never trigger, prevent
-		return nil, e.FmtErrL("[parseCond] CondType not found")
-	}
+	tag := &dynTag{e: e, name: e.Get()}
 
 	skipSpace()
-	subBucket = nil
 	for {
 		ok := s.Next(&e)
 		if !ok {
 			return nil, s.EarlyEndL("RBRACE")
 		}
-		if e.Token == token.SPACE ||
-			e.Token == token.BREAK {
-			if dp.Type == DynamicTypeIf {
-				subBucket = append(subBucket, e)
-			}
-			continue
-		}
 		if e.Token == token.RBRACE {
 			break
 		}
-		subBucket = append(subBucket, e)
+		tag.es = append(tag.es, e)
+	}
+	return tag, nil
+}
+
+// parseBody parses the body of the part, returns the tag
+// ends it.
+func parseBody(s *token.Scanner, tag *dynTag, expectEnd string) (
+	[]*DynamicPart, *dynTag, error,
+) {
+	dps, end, err := parseParts(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if end == nil {
+		return nil, nil, s.EarlyEndL(expectEnd)
 	}
-	if len(subBucket) == 0 {
-		return nil, e.FmtErrL("condition is empty")
+	if len(dps) == 0 {
+		return nil, nil, tag.e.FmtErrL("condition body is empty")
 	}
+	return dps, end, nil
+}
 
-	os := token.CopyScanner(s, subBucket)
-	err := parseCondState(os, dp)
+// parseIf parses the "if" or "elif" part, and the else
+// branches after it.
+func parseIf(s *token.Scanner, tag *dynTag) (*DynamicPart, error) {
+	dp := &DynamicPart{Type: DynamicTypeIf}
+	dp.IfCond = parseCondExpr(tag.es)
+	if dp.IfCond == "" {
+		return nil, tag.e.FmtErrL("condition is empty")
+	}
+	var (
+		end *dynTag
+		err error
+	)
+	dp.Children, end, err = parseBody(s, tag, "endif")
 	if err != nil {
 		return nil, err
 	}
+	switch end.name {
+	case "endif":
+		return dp, checkEndTag(end)
 
-	subBucket = nil
-	for {
-		ok := s.Next(&e)
-		if !ok {
-			return nil, s.EarlyEndL("EndTag")
+	case "elif":
+		dp.Else, err = parseIf(s, end)
+		if err != nil {
+			return nil, err
 		}
-		if e.Token == token.PERCENT {
-			var next token.Element
-			ok = s.Cur(&next)
-			if !ok {
-				return nil, s.EarlyEndL("EndTag")
-			}
-			if next.Token == token.LBRACE {
-				s.Next(nil)
-				break
-			}
+		return dp, nil
+
+	case "else":
+		if err = checkEndTag(end); err != nil {
+			return nil, err
 		}
-		subBucket = append(subBucket, e)
+		els := &DynamicPart{Type: DynamicTypeElse}
+		els.Children, end, err = parseBody(s, end, "endif")
+		if err != nil {
+			return nil, err
+		}
+		if end.name != "endif" {
+			return nil, end.e.NotMatchL("endif")
+		}
+		dp.Else = els
+		return dp, checkEndTag(end)
+	}
+	return nil, end.e.NotMatchL("endif")
+}
+
+func parseFor(s *token.Scanner, tag *dynTag) (*DynamicPart, error) {
+	dp := &DynamicPart{Type: DynamicTypeFor}
+	var es []token.Element
+	for _, e := range tag.es {
+		if e.Token == token.SPACE ||
+			e.Token == token.BREAK {
+			continue
+		}
+		es = append(es, e)
 	}
-	if len(subBucket) == 0 {
-		return nil, e.FmtErrL("condition body is empty")
+	if len(es) == 0 {
+		return nil, tag.e.FmtErrL("condition is empty")
 	}
-	os = token.CopyScanner(s, subBucket)
-	state, err := parsePh(os)
+	err := parseCondFor(token.CopyScanner(s, es), dp)
 	if err != nil {
 		return nil, err
 	}
-	dp.State = state
 
-	subBucket = nil
-	for {
-		ok := s.Next(&e)
-		if !ok {
-			return nil, s.EarlyEndL("EndBody")
-		}
-		if e.Token == token.RBRACE {
-			break
-		}
-		subBucket = append(subBucket, e)
+	var end *dynTag
+	dp.Children, end, err = parseBody(s, tag, "endfor")
+	if err != nil {
+		return nil, err
 	}
-	if len(subBucket) != 1 {
-		return nil, e.NotMatchL("EndBody")
+	if end.name != "endfor" {
+		return nil, end.e.NotMatchL("endfor")
 	}
-	e = subBucket[0]
-	var expectEnd string
-	switch dp.Type {
-	case DynamicTypeIf:
-		expectEnd = "endif"
+	return dp, checkEndTag(end)
+}
 
-	case DynamicTypeFor:
-		expectEnd = "endfor"
+// parseBlock parses the "where" or "set" block, which has no
+// condition.
+func parseBlock(s *token.Scanner, tag *dynTag, t int, expectEnd string) (
+	*DynamicPart, error,
+) {
+	err := checkEndTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	dp := &DynamicPart{Type: t}
+	var end *dynTag
+	dp.Children, end, err = parseBody(s, tag, expectEnd)
+	if err != nil {
+		return nil, err
+	}
+	if end.name != expectEnd {
+		return nil, end.e.NotMatchL(expectEnd)
 	}
+	return dp, checkEndTag(end)
+}
 
-	if e.Get() != expectEnd {
-		return nil, e.NotMatchL(expectEnd)
+// parseSwitch parses the "switch" part, its children are the
+// "case" and "default" parts. The body of the case can be
+// empty, which appends nothing.
+func parseSwitch(s *token.Scanner, tag *dynTag) (*DynamicPart, error) {
+	dp := &DynamicPart{Type: DynamicTypeSwitch}
+	dp.SwitchExpr = parseCondExpr(tag.es)
+	if dp.SwitchExpr == "" {
+		return nil, tag.e.FmtErrL("condition is empty")
+	}
+	dps, end, err := parseParts(s)
+	if err != nil {
+		return nil, err
+	}
+	if end == nil {
+		return nil, s.EarlyEndL("endswitch")
+	}
+	if len(dps) > 0 {
+		return nil, end.e.FmtErrL("sql before the first case")
 	}
 
-	return dp, nil
-}
+	hasDefault := false
+	for {
+		var cs *DynamicPart
+		switch end.name {
+		case "endswitch":
+			if len(dp.Children) == 0 {
+				return nil, end.e.FmtErrL("switch has no case")
+			}
+			return dp, checkEndTag(end)
 
-func parseCondState(s *token.Scanner, dp *DynamicPart) error {
-	switch dp.Type {
-	case DynamicTypeIf:
-		parseCondIf(s, dp)
+		case "case":
+			cs = &DynamicPart{Type: DynamicTypeCase}
+			cs.CaseExpr = parseCondExpr(end.es)
+			if cs.CaseExpr == "" {
+				return nil, end.e.FmtErrL("case is empty")
+			}
 
-	case DynamicTypeFor:
-		return parseCondFor(s, dp)
+		case "default":
+			if hasDefault {
+				return nil, end.e.FmtErrL("default is duplicate")
+			}
+			hasDefault = true
+			if err = checkEndTag(end); err != nil {
+				return nil, err
+			}
+			cs = &DynamicPart{Type: DynamicTypeDefault}
+
+		default:
+			return nil, end.e.NotMatchL("endswitch")
+		}
+		cs.Children, end, err = parseParts(s)
+		if err != nil {
+			return nil, err
+		}
+		if end == nil {
+			return nil, s.EarlyEndL("endswitch")
+		}
+		dp.Children = append(dp.Children, cs)
+	}
+}
+
+// checkEndTag ensures the tag has no condition, such as
+// "endif", "else".
+func checkEndTag(tag *dynTag) error {
+	for _, e := range tag.es {
+		if e.Token == token.SPACE ||
+			e.Token == token.BREAK {
+			continue
+		}
+		return e.NotMatchL("RBRACE")
 	}
 	return nil
 }
 
-func parseCondIf(s *token.Scanner, dp *DynamicPart) {
-	es := s.Gets()
+// parseCondExpr returns the Go expression of the condition,
+// such as "if" and "switch".
+func parseCondExpr(es []token.Element) string {
 	bucket := make([]string, 0, len(es))
 	for _, e := range es {
 		if e.String {
@@ -931,7 +1114,7 @@ func parseCondIf(s *token.Scanner, dp *DynamicPart) {
 			bucket = append(bucket, e.Get())
 		}
 	}
-	dp.IfCond = strings.Join(bucket, "")
+	return strings.TrimSpace(strings.Join(bucket, ""))
 }
 
 func parseCondFor(s *token.Scanner, dp *DynamicPart) error {
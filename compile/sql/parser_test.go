@@ -12,15 +12,15 @@ func doParseSql(sqls [][]string) {
 	fmt.Println("==========================")
 	for _, lines := range sqls {
 		tagLine := lines[0]
-		tag, err := base.ParseTag(commPrefix, tagLine)
+		tag, err := base.ParseTag(0, commPrefix, tagLine)
 		if err != nil {
 			fmt.Printf("parse tag failed: %v\n", err)
 			return
 		}
-		p, err := newSqlParser(tag)
+		p, err := acceptSql(tag)
 		if err != nil {
-			fmt.Printf("newSqlParser failed: %v\n", err)
-			return
+			fmt.Printf("acceptSql failed: %v\n", err)
+			continue
 		}
 
 		lines = lines[1:]
@@ -32,31 +32,14 @@ func doParseSql(sqls [][]string) {
 		switch v.(type) {
 		case error:
 			fmt.Println(v.(error).Error())
-			return
+			fmt.Println("==========================")
 
 		case *Method:
 			m := v.(*Method)
 			fmt.Printf("Method name=%s, inter=%s\n",
 				m.Name, m.Inter)
 			if m.Dyn {
-				for idx, dp := range m.Dps {
-					phs := make([]string, len(dp.State.phs))
-					for idx, ph := range dp.State.phs {
-						phs[idx] = ph.String()
-					}
-					fmt.Printf("dp %d: sql=%s, phs=[%s]\n",
-						idx, dp.State.Sql, strings.Join(phs, ","))
-					switch dp.Type {
-					case DynamicTypeConst:
-
-					case DynamicTypeIf:
-						fmt.Printf("\tIfCond=%s\n", dp.IfCond)
-
-					case DynamicTypeFor:
-						fmt.Printf("\tslice=%s, ele=%s, join=%s\n",
-							dp.ForSlice, dp.ForEle, dp.ForJoin)
-					}
-				}
+				printDps(m.Dps, 0)
 			} else {
 				phs := make([]string, len(m.State.phs))
 				for idx, ph := range m.State.phs {
@@ -65,6 +48,10 @@ func doParseSql(sqls [][]string) {
 				fmt.Printf("no-dyn: sql=%s, phs=[%s]\n",
 					m.State.Sql, strings.Join(phs, ","))
 			}
+			if m.Chunk != "" {
+				fmt.Printf("Chunk = %s, ChunkSize = %d\n",
+					m.Chunk, m.ChunkSize)
+			}
 			fmt.Printf("IsExec = %v\n", m.Exec)
 			if !m.Exec {
 				for _, f := range m.Fields {
@@ -77,6 +64,169 @@ func doParseSql(sqls [][]string) {
 	}
 }
 
+func printDps(dps []*DynamicPart, indent int) {
+	tab := strings.Repeat("\t", indent)
+	for idx, dp := range dps {
+		switch dp.Type {
+		case DynamicTypeConst:
+			phs := make([]string, len(dp.State.phs))
+			for idx, ph := range dp.State.phs {
+				phs[idx] = ph.String()
+			}
+			fmt.Printf("%sdp %d: sql=%s, phs=[%s]\n", tab,
+				idx, dp.State.Sql, strings.Join(phs, ","))
+
+		case DynamicTypeIf:
+			fmt.Printf("%sdp %d: IfCond=%s\n", tab, idx, dp.IfCond)
+			printDps(dp.Children, indent+1)
+			for br := dp.Else; br != nil; br = br.Else {
+				if br.Type == DynamicTypeElse {
+					fmt.Printf("%sElse\n", tab)
+				} else {
+					fmt.Printf("%sElif=%s\n", tab, br.IfCond)
+				}
+				printDps(br.Children, indent+1)
+			}
+
+		case DynamicTypeFor:
+			fmt.Printf("%sdp %d: slice=%s, ele=%s, join=%s\n", tab,
+				idx, dp.ForSlice, dp.ForEle, dp.ForJoin)
+			printDps(dp.Children, indent+1)
+
+		case DynamicTypeWhere:
+			fmt.Printf("%sdp %d: Where\n", tab, idx)
+			printDps(dp.Children, indent+1)
+
+		case DynamicTypeSet:
+			fmt.Printf("%sdp %d: Set\n", tab, idx)
+			printDps(dp.Children, indent+1)
+
+		case DynamicTypeSwitch:
+			fmt.Printf("%sdp %d: Switch=%s\n", tab, idx, dp.SwitchExpr)
+			for _, cs := range dp.Children {
+				if cs.Type == DynamicTypeDefault {
+					fmt.Printf("%sDefault\n", tab)
+				} else {
+					fmt.Printf("%sCase=%s\n", tab, cs.CaseExpr)
+				}
+				printDps(cs.Children, indent+1)
+			}
+		}
+	}
+}
+
+// parseLines parses the lines of a method, the first line is
+// the "+gen:method" tag.
+func parseLines(lines []string) (*Method, error) {
+	tag, err := base.ParseTag(0, commPrefix, lines[0])
+	if err != nil {
+		return nil, err
+	}
+	p, err := acceptSql(tag)
+	if err != nil {
+		return nil, err
+	}
+	for idx, line := range lines[1:] {
+		_, err = p.Next(idx, line, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch v := p.Get().(type) {
+	case error:
+		return nil, v
+
+	case *Method:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unexpected result")
+}
+
+// dumpDps formats the dynamic parts in one line, such as
+// `"SELECT id FROM user" if(id > 0){"AND id=?"} else{"..."}`.
+func dumpDps(dps []*DynamicPart) string {
+	parts := make([]string, 0, len(dps))
+	for _, dp := range dps {
+		var part string
+		switch dp.Type {
+		case DynamicTypeConst:
+			part = fmt.Sprintf("%q", strings.Join(strings.Fields(dp.State.Sql), " "))
+
+		case DynamicTypeIf:
+			part = fmt.Sprintf("if(%s){%s}", dp.IfCond, dumpDps(dp.Children))
+			for br := dp.Else; br != nil; br = br.Else {
+				if br.Type == DynamicTypeElse {
+					part += fmt.Sprintf(" else{%s}", dumpDps(br.Children))
+				} else {
+					part += fmt.Sprintf(" elif(%s){%s}", br.IfCond, dumpDps(br.Children))
+				}
+			}
+
+		case DynamicTypeFor:
+			part = fmt.Sprintf("for(%s in %s join %q){%s}", dp.ForEle,
+				dp.ForSlice, dp.ForJoin, dumpDps(dp.Children))
+
+		case DynamicTypeWhere:
+			part = fmt.Sprintf("where{%s}", dumpDps(dp.Children))
+
+		case DynamicTypeSet:
+			part = fmt.Sprintf("set{%s}", dumpDps(dp.Children))
+
+		case DynamicTypeSwitch:
+			part = fmt.Sprintf("switch(%s){%s}", dp.SwitchExpr, dumpDps(dp.Children))
+
+		case DynamicTypeCase:
+			part = fmt.Sprintf("case(%s){%s}", dp.CaseExpr, dumpDps(dp.Children))
+
+		case DynamicTypeDefault:
+			part = fmt.Sprintf("default{%s}", dumpDps(dp.Children))
+
+		default:
+			part = fmt.Sprintf("unknown(%d)", dp.Type)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+type dynCase struct {
+	lines []string
+
+	// the dumped parts, or the error message expected.
+	expect string
+	err    string
+}
+
+func testDyn(t *testing.T, cases []dynCase) {
+	for _, c := range cases {
+		m, err := parseLines(c.lines)
+		if c.err != "" {
+			if err == nil {
+				t.Errorf("%s: expect error %q, got %s", c.lines[0],
+					c.err, dumpDps(m.Dps))
+				continue
+			}
+			if !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %q, expect %q", c.lines[0],
+					err.Error(), c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.lines[0], err)
+			continue
+		}
+		if !m.Dyn {
+			t.Errorf("%s: not dynamic", c.lines[0])
+			continue
+		}
+		got := dumpDps(m.Dps)
+		if got != c.expect {
+			t.Errorf("%s:\n got: %s\nwant: %s", c.lines[0], got, c.expect)
+		}
+	}
+}
+
 func TestSql0(t *testing.T) {
 	sqls := [][]string{
 		{
@@ -183,3 +333,201 @@ func TestDyn0(t *testing.T) {
 	}
 	doParseSql(sqls)
 }
+
+func TestDyn1(t *testing.T) {
+	testDyn(t, []dynCase{
+		{
+			lines: []string{
+				"-- +gen:method Search dyn=true",
+				"SELECT id, name FROM user",
+				"WHERE 1=1",
+				"%{if id > 0}",
+				"  AND id=${id}",
+				"%{elif name != \"\"}",
+				"  AND name=${name}",
+				"  %{if len(emails) > 0}",
+				"    AND email IN (%{for e in emails join ','}${e}%{endfor})",
+				"  %{endif}",
+				"%{else}",
+				"  AND is_delete=0",
+				"%{endif}",
+				"%{for u in us}",
+				"  %{if u.Admin}OR admin=${u.Id}%{else}OR id=${u.Id}%{endif}",
+				"%{endfor}",
+			},
+			expect: `"SELECT id, name FROM user WHERE 1=1" ` +
+				`if(id > 0){"AND id=?"} ` +
+				`elif(name != ""){"AND name=?" if(len(emails) > 0){"AND email IN (" for(e in emails join ","){"?"} ")"}} ` +
+				`else{"AND is_delete=0"} ` +
+				`for(u in us join ""){if(u.Admin){"OR admin=?"} else{"OR id=?"}}`,
+		},
+		{
+			lines: []string{
+				"-- +gen:method Elifs dyn=true",
+				"SELECT id FROM user WHERE",
+				"%{if a}a=1%{elif b}b=1%{elif c}%{if d}d=1%{else}e=1%{endif}%{endif}",
+			},
+			expect: `"SELECT id FROM user WHERE" if(a){"a=1"} elif(b){"b=1"} ` +
+				`elif(c){if(d){"d=1"} else{"e=1"}}`,
+		},
+		{
+			lines: []string{
+				"-- +gen:method MissingEnd dyn=true",
+				"SELECT id FROM user WHERE 1=1",
+				"%{if id > 0}",
+				"  AND id=${id}",
+			},
+			err: "expect endif, found: 'EOF'",
+		},
+		{
+			lines: []string{
+				"-- +gen:method BadEnd dyn=true",
+				"SELECT id FROM user WHERE 1=1",
+				"%{if id > 0}",
+				"  AND id=${id}",
+				"%{endfor}",
+			},
+			err: "4:2: expect endif",
+		},
+		{
+			lines: []string{
+				"-- +gen:method ElifAfterElse dyn=true",
+				"SELECT id FROM user WHERE 1=1",
+				"%{if a}a=1%{else}b=1%{elif c}c=1%{endif}",
+			},
+			err: "2:22: expect endif",
+		},
+		{
+			lines: []string{
+				"-- +gen:method Unexpected dyn=true",
+				"SELECT id FROM user WHERE 1=1",
+				"%{else}",
+			},
+			err: "unexpected \"else\"",
+		},
+	})
+}
+
+func TestDyn2(t *testing.T) {
+	testDyn(t, []dynCase{
+		{
+			lines: []string{
+				"-- +gen:method Find dyn=true",
+				"SELECT id, name FROM user",
+				"%{where}",
+				"  %{if id > 0}AND id=${id}%{endif}",
+				"  %{if name != \"\"}AND name=${name}%{endif}",
+				"%{endwhere}",
+				"ORDER BY id",
+			},
+			expect: `"SELECT id, name FROM user" ` +
+				`where{if(id > 0){"AND id=?"} if(name != ""){"AND name=?"}} ` +
+				`"ORDER BY id"`,
+		},
+		{
+			lines: []string{
+				"-- +gen:method Update dyn=true",
+				"UPDATE user",
+				"%{set}",
+				"  %{if name != nil}name=${*name},%{endif}",
+				"  %{if age != nil}age=${*age},%{endif}",
+				"%{endset}",
+				"WHERE id=${id}",
+			},
+			expect: `"UPDATE user" ` +
+				`set{if(name != nil){"name=?,"} if(age != nil){"age=?,"}} ` +
+				`"WHERE id=?"`,
+		},
+		{
+			lines: []string{
+				"-- +gen:method BadSet dyn=true",
+				"UPDATE user",
+				"%{set}",
+				"  %{if name != nil}name=${*name},%{endif}",
+				"%{endwhere}",
+			},
+			err: "4:2: expect endset",
+		},
+		{
+			lines: []string{
+				"-- +gen:method MissingEndWhere dyn=true",
+				"SELECT id FROM user",
+				"%{where}",
+				"  %{if id > 0}AND id=${id}%{endif}",
+			},
+			err: "expect endwhere, found: 'EOF'",
+		},
+	})
+}
+
+func TestDyn3(t *testing.T) {
+	testDyn(t, []dynCase{
+		{
+			lines: []string{
+				"-- +gen:method List dyn=true",
+				"SELECT id, name FROM user",
+				"ORDER BY",
+				"%{switch order}",
+				"%{case \"name\", \"title\"}",
+				"  name",
+				"%{case \"id\"}",
+				"%{default}",
+				"  id %{if desc}DESC%{endif}",
+				"%{endswitch}",
+			},
+			expect: `"SELECT id, name FROM user ORDER BY" switch(order){` +
+				`case("name", "title"){"name"} case("id"){} ` +
+				`default{"id" if(desc){"DESC"}}}`,
+		},
+		{
+			lines: []string{
+				"-- +gen:method SqlBeforeCase dyn=true",
+				"SELECT id FROM user ORDER BY",
+				"%{switch order} id %{case \"id\"} id %{endswitch}",
+			},
+			err: "sql before the first case",
+		},
+		{
+			lines: []string{
+				"-- +gen:method DupDefault dyn=true",
+				"SELECT id FROM user ORDER BY",
+				"%{switch order}%{default}id%{default}name%{endswitch}",
+			},
+			err: "default is duplicate",
+		},
+		{
+			lines: []string{
+				"-- +gen:method CaseOutside dyn=true",
+				"SELECT id FROM user ORDER BY",
+				"%{case \"id\"} id",
+			},
+			err: "unexpected \"case\"",
+		},
+		{
+			lines: []string{
+				"-- +gen:method MissingEndSwitch dyn=true",
+				"SELECT id FROM user ORDER BY",
+				"%{switch order}%{case \"id\"}id",
+			},
+			err: "expect endswitch, found: 'EOF'",
+		},
+	})
+}
+
+func TestChunk(t *testing.T) {
+	sqls := [][]string{
+		{
+			"-- +gen:method GetByIds chunk=ids:500",
+			"SELECT id, name FROM user WHERE id IN ${ids}",
+		},
+		{
+			"-- +gen:method DeleteByIds chunk=ids:0",
+			"DELETE FROM user WHERE id IN ${ids}",
+		},
+		{
+			"-- +gen:method BadChunk chunk=ids",
+			"DELETE FROM user WHERE id IN ${ids}",
+		},
+	}
+	doParseSql(sqls)
+}
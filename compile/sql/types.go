@@ -20,8 +20,16 @@ type Method struct {
 	Dyn bool
 	Dps []*DynamicPart
 
+	// Chunk is the name of the slice parameter to split, the
+	// method is executed for each ChunkSize elements of it.
+	Chunk     string
+	ChunkSize int
+
 	Fields []*QueryField
 
+	// Vars are the names of the vars referenced.
+	Vars []string
+
 	Tags []*base.Tag
 }
 
@@ -29,6 +37,12 @@ func (m *Method) LineIdx() int {
 	return m.line - 1
 }
 
+// Line returns the line number of the "+gen:method" tag,
+// starting from 1.
+func (m *Method) Line() int {
+	return m.line
+}
+
 func (m *Method) FmtError(a string, b ...interface{}) error {
 	err := fmt.Errorf(a, b...)
 	return errors.Trace(m.line, err)
@@ -39,8 +53,19 @@ const (
 	DynamicTypeConst = iota
 	DynamicTypeIf
 	DynamicTypeFor
+	DynamicTypeElse
+	DynamicTypeWhere
+	DynamicTypeSet
+	DynamicTypeSwitch
+	DynamicTypeCase
+	DynamicTypeDefault
 )
 
+// DynamicPart is a node of the dynamic sql tree. The const
+// part has the State, the "if", "else", "for", "where",
+// "set", "case" and "default" parts have the Children as
+// their bodies, which can be nested. The Children of the
+// "switch" part are its "case" and "default" parts.
 type DynamicPart struct {
 	Type int
 
@@ -51,6 +76,30 @@ type DynamicPart struct {
 	ForEle   string
 	ForSlice string
 	ForJoin  string
+
+	SwitchExpr string
+
+	// CaseExpr is the expression list of the "case", such as
+	// `"name", "id"`.
+	CaseExpr string
+
+	Children []*DynamicPart
+
+	// Else is the next branch of the "if" part, it is the
+	// "elif" (DynamicTypeIf) or the "else" (DynamicTypeElse).
+	Else *DynamicPart
+}
+
+// WalkDynamic calls fn for each part of the tree in order,
+// the parent is called before its children, and the "if"
+// before its else branches.
+func WalkDynamic(dps []*DynamicPart, fn func(dp *DynamicPart)) {
+	for _, dp := range dps {
+		for br := dp; br != nil; br = br.Else {
+			fn(br)
+			WalkDynamic(br.Children, fn)
+		}
+	}
 }
 
 type Statement struct {
@@ -59,12 +108,19 @@ type Statement struct {
 	Replaces []string
 	Prepares []string
 
+	// PreparePos are the offsets of the "?" of the Prepares
+	// in the Sql.
+	PreparePos []int
+
 	phs []*placeholder
 }
 
 type placeholder struct {
 	pre  bool
 	name string
+
+	// the offset of the placeholder in sql.
+	pos int
 }
 
 func (ph *placeholder) String() string {
@@ -74,7 +130,23 @@ func (ph *placeholder) String() string {
 	return "#" + ph.name
 }
 
+// Var is the sql fragment declared by "+gen:var", it can
+// be referenced by "@{name}" in the methods.
 type Var struct {
+	line int
+
+	Name string
+}
+
+// Line returns the line number of the "+gen:var" tag,
+// starting from 1.
+func (v *Var) Line() int {
+	return v.line
+}
+
+func (v *Var) FmtError(a string, b ...interface{}) error {
+	err := fmt.Errorf(a, b...)
+	return errors.Trace(v.line, err)
 }
 
 type QueryField struct {
@@ -0,0 +1,139 @@
+// Package config reads the project-level configuration
+// file "gendb.yaml". The file is found by walking up from
+// the directory of the source file, so one file can serve
+// all the source files of a project.
+//
+// Sample:
+//
+//	types:
+//	  - db: decimal
+//	    go: decimal.Decimal
+//	    go_null: decimal.NullDecimal
+//	    import: github.com/shopspring/decimal
+//	  - db: char(36)
+//	    go: uuid.UUID
+//	    import: github.com/google/uuid
+//	  - go: Money
+//	    sql: BIGINT
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/fioncat/go-gendb/misc/errors"
+)
+
+// Name is the name of the configuration file.
+const Name = "gendb.yaml"
+
+// Config is the project-level configuration.
+type Config struct {
+	// Path is the file path of the config.
+	Path string `yaml:"-"`
+
+	// Types overrides the type mappings between the
+	// database and Go.
+	Types []*Type `yaml:"types"`
+}
+
+// Type is a custom type mapping.
+type Type struct {
+	// Db is the database type to be converted to Go. It
+	// is case-insensitive, and matches the types with
+	// length or attributes, for example, "decimal" matches
+	// "DECIMAL(10,2)", while "char(36)" only matches
+	// "CHAR(36)". If it is empty, the mapping will only
+	// be used to convert Go type to database type.
+	Db string `yaml:"db"`
+
+	// Go is the Go type, with the package name if it is
+	// not a builtin type, such as "decimal.Decimal".
+	Go string `yaml:"go"`
+
+	// Null is the Go type for nullable fields. If it is
+	// empty, the pointer of Go type is used.
+	Null string `yaml:"go_null"`
+
+	// Import is the import path of the Go type's package.
+	Import string `yaml:"import"`
+
+	// Sql is the database type when converting Go type
+	// to database type (such as generating "CREATE TABLE").
+	// The default is Db.
+	Sql string `yaml:"sql"`
+}
+
+// Find walks up from the directory of path to find the
+// configuration file, and stops at the module root (the
+// directory containing "go.mod"). If there is no config
+// file, returns nil.
+func Find(path string) (*Config, error) {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for {
+		cfgPath := filepath.Join(dir, Name)
+		if exists(cfgPath) {
+			return Load(cfgPath)
+		}
+		if exists(filepath.Join(dir, "go.mod")) {
+			return nil, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Load reads the configuration file.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(Config)
+	err = yaml.UnmarshalStrict(data, cfg)
+	if err != nil {
+		return nil, errors.Trace(path, err)
+	}
+	cfg.Path = path
+	for idx, t := range cfg.Types {
+		err = t.check()
+		if err != nil {
+			return nil, errors.Trace(path,
+				errors.Trace(fmt.Sprintf("types[%d]", idx), err))
+		}
+	}
+	return cfg, nil
+}
+
+func (t *Type) check() error {
+	if t.Go == "" {
+		return errors.New(`missing "go"`)
+	}
+	if t.Db == "" && t.Sql == "" {
+		return errors.New(`missing "db" or "sql"`)
+	}
+	if t.Sql == "" {
+		t.Sql = strings.ToUpper(t.Db)
+	}
+	if t.Import != "" && !strings.Contains(t.Go, ".") {
+		return fmt.Errorf(`type "%s" with import `+
+			`must have package name`, t.Go)
+	}
+	return nil
+}
@@ -1,8 +1,15 @@
 package conn
 
 import (
+	"bytes"
 	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
 
+	"github.com/fioncat/go-gendb/misc/errors"
 	"github.com/fioncat/go-gendb/store"
 )
 
@@ -11,6 +18,11 @@ import (
 // connect to the database, some of which can be empty.
 // This structure will be used by different databases to
 // create connections.
+//
+// The fields can refer to the environment variables in
+// the form of "${NAME}", they are stored as it is and
+// replaced by Get, so that the secrets need not be saved
+// on the disk.
 type Config struct {
 
 	// Key is used to uniquely locate a certain
@@ -26,6 +38,16 @@ type Config struct {
 
 	// database name, can be empty
 	Database string `json:"database"`
+
+	// Path is the database file path, only used by
+	// file databases such as sqlite. Relative paths are
+	// relative to the working directory.
+	Path string `json:"path,omitempty"`
+
+	// PasswordCmd is a shell command to get the password,
+	// such as "pass show db/dev". If it is not empty, the
+	// output of the command (trimmed) overrides Password.
+	PasswordCmd string `json:"password_cmd,omitempty"`
 }
 
 // key prefix store in the disk.
@@ -48,7 +70,25 @@ func Set(key string, cfg *Config) error {
 // key from the disk. The configuration is set by Set. If
 // the configuration does not exist, an error will be returned.
 // This function will trigger a file IO operation.
+//
+// The environment variables in the configuration are
+// replaced, and the PasswordCmd is executed. Use GetRaw
+// to get the configuration as it is stored.
 func Get(key string) (*Config, error) {
+	cfg, err := GetRaw(key)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.resolve()
+	if err != nil {
+		return nil, errors.Trace(key, err)
+	}
+	return cfg, nil
+}
+
+// GetRaw is like Get, but does not resolve the
+// configuration.
+func GetRaw(key string) (*Config, error) {
 	oriKey := key
 	key = keyPrefix + key
 
@@ -71,3 +111,80 @@ func Remove(key string) error {
 	key = keyPrefix + key
 	return store.Remove(key)
 }
+
+// Keys returns the keys of all the saved connections.
+func Keys() ([]string, error) {
+	names, err := store.Names(keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(names))
+	for idx, name := range names {
+		keys[idx] = strings.TrimPrefix(name, keyPrefix)
+	}
+	return keys, nil
+}
+
+var envRe = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// expandEnv replaces "${NAME}" with the environment
+// variable, the variable must be set. "$NAME" is not
+// replaced since it is common in the passwords.
+func expandEnv(s string) (string, error) {
+	var err error
+	s = envRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envRe.FindStringSubmatch(ref)[1]
+		val, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf(`environment variable `+
+				`"%s" is not set`, name)
+		}
+		return val
+	})
+	return s, err
+}
+
+func (cfg *Config) resolve() error {
+	fields := []*string{
+		&cfg.Addr, &cfg.User, &cfg.Password,
+		&cfg.Database, &cfg.Path, &cfg.PasswordCmd,
+	}
+	for _, field := range fields {
+		val, err := expandEnv(*field)
+		if err != nil {
+			return err
+		}
+		*field = val
+	}
+	if cfg.PasswordCmd == "" {
+		return nil
+	}
+	password, err := runPasswordCmd(cfg.PasswordCmd)
+	if err != nil {
+		return errors.Trace("password_cmd", err)
+	}
+	cfg.Password = password
+	return nil
+}
+
+func runPasswordCmd(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Stdin = os.Stdin
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
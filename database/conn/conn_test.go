@@ -0,0 +1,82 @@
+package conn
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("GENDB_TEST_PASSWORD", "p$w")
+	defer os.Unsetenv("GENDB_TEST_PASSWORD")
+	os.Unsetenv("GENDB_TEST_UNSET")
+
+	cases := []struct {
+		s      string
+		expect string
+		err    string
+	}{
+		{"root", "root", ""},
+		{"${GENDB_TEST_PASSWORD}", "p$w", ""},
+		{"a-${GENDB_TEST_PASSWORD}-b", "a-p$w-b", ""},
+		// "$NAME" is kept, it is common in the passwords.
+		{"$GENDB_TEST_PASSWORD", "$GENDB_TEST_PASSWORD", ""},
+		{"${GENDB_TEST_UNSET}", "", `environment variable "GENDB_TEST_UNSET" is not set`},
+	}
+	for _, c := range cases {
+		got, err := expandEnv(c.s)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%q: got error %v, expect %q", c.s, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.s, err)
+			continue
+		}
+		if got != c.expect {
+			t.Errorf("%q: got %q, expect %q", c.s, got, c.expect)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("password_cmd uses sh")
+	}
+	os.Setenv("GENDB_TEST_USER", "admin")
+	defer os.Unsetenv("GENDB_TEST_USER")
+
+	cfg := &Config{
+		Addr:        "localhost:3306",
+		User:        "${GENDB_TEST_USER}",
+		Password:    "stored",
+		PasswordCmd: "echo '  secret  '",
+	}
+	err := cfg.resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.User != "admin" || cfg.Password != "secret" {
+		t.Fatalf("resolved: %+v", cfg)
+	}
+
+	cfg = &Config{PasswordCmd: "echo 'no such key' >&2; exit 3"}
+	err = cfg.resolve()
+	if err == nil {
+		t.Fatal("expect error of password_cmd")
+	}
+	for _, s := range []string{"password_cmd", "exit status 3", "no such key"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("missing %q in error %q", s, err.Error())
+		}
+	}
+
+	cfg = &Config{Password: "${GENDB_TEST_UNSET}"}
+	err = cfg.resolve()
+	if err == nil || !strings.Contains(err.Error(), "GENDB_TEST_UNSET") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
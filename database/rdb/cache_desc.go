@@ -28,18 +28,38 @@ var (
 // CacheTable represents the structure of the data table
 // cached on the disk. It implements the Table interface,
 // and any Table interface can be converted to it.
+// It is also the table of the schema snapshot, so it can
+// be encoded by both json and yaml.
 type CacheTable struct {
-	Name    string `json:"name"`
-	Comment string `json:"comment"`
+	Name    string `json:"name" yaml:"name"`
+	Comment string `json:"comment" yaml:"comment,omitempty"`
 
-	Fields map[string]*CacheField `json:"fields"`
+	Fields map[string]*CacheField `json:"fields" yaml:"fields"`
+
+	Indexes []*Index `json:"indexes,omitempty" yaml:"indexes,omitempty"`
+
+	// Order keeps the order of the fields in the
+	// database, map does not keep it.
+	Order []string `json:"order,omitempty" yaml:"order,omitempty"`
 }
 
-func (t *CacheTable) GetName() string         { return t.Name }
-func (t *CacheTable) GetComment() string      { return t.Comment }
-func (t *CacheTable) Field(name string) Field { return t.Fields[name] }
+func (t *CacheTable) GetName() string      { return t.Name }
+func (t *CacheTable) GetComment() string   { return t.Comment }
+func (t *CacheTable) GetIndexes() []*Index { return t.Indexes }
+
+// Field returns nil rather than the typed nil pointer if
+// the field does not exist.
+func (t *CacheTable) Field(name string) Field {
+	if f, ok := t.Fields[name]; ok {
+		return f
+	}
+	return nil
+}
 
 func (t *CacheTable) FieldNames() []string {
+	if len(t.Order) > 0 {
+		return t.Order
+	}
 	names := make([]string, 0, len(t.Fields))
 	for name := range t.Fields {
 		names = append(names, name)
@@ -51,20 +71,25 @@ func (t *CacheTable) FieldNames() []string {
 func (t *CacheTable) fromInter(it Table) {
 	t.Name = it.GetName()
 	t.Comment = it.GetComment()
+	t.Indexes = it.GetIndexes()
 
 	fieldNames := it.FieldNames()
 	t.Fields = make(map[string]*CacheField, len(fieldNames))
+	t.Order = make([]string, 0, len(fieldNames))
 	for _, fieldName := range fieldNames {
 		field := it.Field(fieldName)
 		if field == nil {
 			continue
 		}
+		t.Order = append(t.Order, fieldName)
 		t.Fields[fieldName] = &CacheField{
 			Name:      field.GetName(),
 			Comment:   field.GetComment(),
 			Type:      field.GetType(),
 			IsPrimary: field.IsPrimaryKey(),
 			AutoIncr:  field.IsAutoIncr(),
+			Nullable:  field.IsNullable(),
+			Default:   field.GetDefault(),
 		}
 	}
 }
@@ -73,11 +98,13 @@ func (t *CacheTable) fromInter(it Table) {
 // cached on the disk. It implements the Field interface,
 // and any Table interface can be converted to it.
 type CacheField struct {
-	Name      string `json:"name"`
-	Comment   string `json:"comment"`
-	Type      string `json:"type"`
-	IsPrimary bool   `json:"is_primary"`
-	AutoIncr  bool   `json:"auto_incr"`
+	Name      string `json:"name" yaml:"name"`
+	Comment   string `json:"comment" yaml:"comment,omitempty"`
+	Type      string `json:"type" yaml:"type"`
+	IsPrimary bool   `json:"is_primary" yaml:"is_primary,omitempty"`
+	AutoIncr  bool   `json:"auto_incr" yaml:"auto_incr,omitempty"`
+	Nullable  bool   `json:"nullable" yaml:"nullable,omitempty"`
+	Default   string `json:"default,omitempty" yaml:"default,omitempty"`
 }
 
 func (f *CacheField) GetName() string    { return f.Name }
@@ -85,6 +112,10 @@ func (f *CacheField) GetComment() string { return f.Comment }
 func (f *CacheField) GetType() string    { return f.Type }
 func (f *CacheField) IsPrimaryKey() bool { return f.IsPrimary }
 func (f *CacheField) IsAutoIncr() bool   { return f.AutoIncr }
+func (f *CacheField) IsNullable() bool   { return f.Nullable }
+func (f *CacheField) IsUnsigned() bool   { return typeUnsigned(f.Type) }
+func (f *CacheField) GetLength() int     { return typeLength(f.Type) }
+func (f *CacheField) GetDefault() string { return f.Default }
 
 // get table from the local disk, if cache miss, returns nil
 func getCacheTable(key string) Table {
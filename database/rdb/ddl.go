@@ -0,0 +1,797 @@
+package rdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fioncat/go-gendb/compile/token"
+	"github.com/fioncat/go-gendb/misc/errors"
+)
+
+// ddlField is the data table field parsed from the
+// "CREATE TABLE" statement.
+type ddlField struct {
+	Name    string
+	Type    string
+	Comment string
+	NotNull bool
+	Default string
+
+	primary  bool
+	autoIncr bool
+	unique   bool
+}
+
+func (f *ddlField) GetName() string    { return f.Name }
+func (f *ddlField) GetComment() string { return f.Comment }
+func (f *ddlField) GetType() string    { return f.Type }
+func (f *ddlField) IsPrimaryKey() bool { return f.primary }
+func (f *ddlField) IsAutoIncr() bool   { return f.autoIncr }
+func (f *ddlField) IsNullable() bool   { return !f.NotNull && !f.primary }
+func (f *ddlField) IsUnsigned() bool   { return typeUnsigned(f.Type) }
+func (f *ddlField) GetLength() int     { return typeLength(f.Type) }
+func (f *ddlField) GetDefault() string { return f.Default }
+
+// ddlTable is the data table parsed from the
+// "CREATE TABLE" statement.
+type ddlTable struct {
+	Name    string
+	Comment string
+
+	fields     map[string]*ddlField
+	fieldNames []string
+
+	indexes []*Index
+}
+
+func (t *ddlTable) GetName() string      { return t.Name }
+func (t *ddlTable) GetComment() string   { return t.Comment }
+func (t *ddlTable) FieldNames() []string { return t.fieldNames }
+func (t *ddlTable) GetIndexes() []*Index { return t.indexes }
+
+// Field returns nil rather than the typed nil pointer if
+// the field does not exist.
+func (t *ddlTable) Field(name string) Field {
+	if f, ok := t.fields[name]; ok {
+		return f
+	}
+	return nil
+}
+
+func (t *ddlTable) addField(f *ddlField) {
+	t.fields[f.Name] = f
+	t.fieldNames = append(t.fieldNames, f.Name)
+}
+
+var ddlSemicolon = token.Token(";")
+
+var ddlTokens = []token.Token{
+	token.LPAREN,
+	token.RPAREN,
+	token.COMMA,
+	token.PERIOD,
+	token.EQ,
+
+	ddlSemicolon,
+}
+
+// Keywords that end the type definition of a column.
+var ddlColumnKeywords = map[string]struct{}{
+	"NOT":            {},
+	"NULL":           {},
+	"DEFAULT":        {},
+	"PRIMARY":        {},
+	"AUTO_INCREMENT": {},
+	"AUTOINCREMENT":  {},
+	"COMMENT":        {},
+	"UNIQUE":         {},
+	"REFERENCES":     {},
+	"CHECK":          {},
+	"COLLATE":        {},
+	"GENERATED":      {},
+	"CONSTRAINT":     {},
+	"ON":             {},
+	"KEY":            {},
+}
+
+// LoadDDL reads the "CREATE TABLE" statements from path
+// and returns the tables described by them, indexed by
+// table name. If path is a directory, all ".sql" files
+// under it will be read (recursively).
+// dbType is the database type the DDL is written for, it
+// affects the dialect-specific rules (such as sqlite's
+// "INTEGER PRIMARY KEY").
+func LoadDDL(path, dbType string) (map[string]Table, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	if !stat.IsDir() {
+		paths = []string{path}
+	} else {
+		err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if strings.HasSuffix(info.Name(), ".sql") {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+	}
+
+	tables := make(map[string]Table)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(string(data), "\n")
+		ts, err := ParseDDL(lines, dbType)
+		if err != nil {
+			err = errors.Trace(path, err)
+			return nil, errors.OnCompile(path, lines, err)
+		}
+		for _, t := range ts {
+			if _, ok := tables[t.GetName()]; ok {
+				return nil, fmt.Errorf(`%s: table "%s" is duplicate`,
+					path, t.GetName())
+			}
+			tables[t.GetName()] = t
+		}
+	}
+	// Schema qualified tables can also be described
+	// by their bare names, unless it is ambiguous.
+	for name, t := range tables {
+		idx := strings.LastIndex(name, ".")
+		if idx < 0 {
+			continue
+		}
+		bare := name[idx+1:]
+		if _, ok := tables[bare]; !ok {
+			tables[bare] = t
+		}
+	}
+	return tables, nil
+}
+
+// ParseDDL parses the "CREATE TABLE" statements in lines.
+// Other statements (such as "USE", "INSERT", "DROP") are
+// ignored. For postgres, "COMMENT ON TABLE" and "COMMENT
+// ON COLUMN" are also parsed to fill comments.
+func ParseDDL(lines []string, dbType string) ([]Table, error) {
+	s := token.EmptyScanner(ddlTokens)
+	for idx, line := range lines {
+		line = ddlStripComment(line)
+		line = strings.ReplaceAll(line, "\t", " ")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		s.AddLine(idx, line)
+	}
+
+	var tables []*ddlTable
+	tableMap := make(map[string]*ddlTable)
+	var e token.Element
+	for s.Next(&e) {
+		switch ddlUpper(e) {
+		case "CREATE":
+			t, err := ddlCreate(s, dbType, tableMap)
+			if err != nil {
+				return nil, err
+			}
+			if t == nil {
+				continue
+			}
+			tables = append(tables, t)
+			tableMap[t.Name] = t
+
+		case "COMMENT":
+			err := ddlCommentOn(s, tableMap)
+			if err != nil {
+				return nil, err
+			}
+
+		default:
+			ddlSkip(s)
+		}
+	}
+
+	ts := make([]Table, len(tables))
+	for idx, t := range tables {
+		ts[idx] = t
+	}
+	return ts, nil
+}
+
+// remove "--" comment from the line, ignore "--" in string.
+func ddlStripComment(line string) string {
+	var quo rune
+	rs := []rune(line)
+	for idx, r := range rs {
+		switch {
+		case quo != 0:
+			if r == quo {
+				quo = 0
+			}
+
+		case r == '\'' || r == '"' || r == '`':
+			quo = r
+
+		case r == '-' && idx+1 < len(rs) && rs[idx+1] == '-':
+			return string(rs[:idx])
+		}
+	}
+	return line
+}
+
+func ddlUpper(e token.Element) string {
+	if !e.Indent {
+		return ""
+	}
+	return strings.ToUpper(e.Get())
+}
+
+// skip to the end of current statement.
+func ddlSkip(s *token.Scanner) {
+	var e token.Element
+	for s.Next(&e) {
+		if e.Token == ddlSemicolon {
+			return
+		}
+	}
+}
+
+// ddlName reads a (maybe schema qualified) name, such
+// as "user", `user`, "public.user".
+func ddlName(s *token.Scanner) (string, error) {
+	var e token.Element
+	var parts []string
+	for {
+		ok := s.Next(&e)
+		if !ok {
+			return "", s.EarlyEndL("NAME")
+		}
+		if !e.Indent && !e.String {
+			return "", e.NotMatchL("NAME")
+		}
+		parts = append(parts, e.Get())
+
+		var next token.Element
+		if !s.Cur(&next) || next.Token != token.PERIOD {
+			break
+		}
+		s.Next(nil)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func ddlCreate(s *token.Scanner, dbType string, tables map[string]*ddlTable) (*ddlTable, error) {
+	var e token.Element
+	for {
+		ok := s.Next(&e)
+		if !ok {
+			return nil, s.EarlyEndL("TABLE")
+		}
+		switch ddlUpper(e) {
+		case "TEMPORARY", "TEMP", "UNLOGGED":
+			continue
+
+		case "TABLE":
+
+		case "UNIQUE", "INDEX":
+			return nil, ddlCreateIndex(s, ddlUpper(e) == "UNIQUE", tables)
+
+		default:
+			// CREATE VIEW, CREATE DATABASE...
+			ddlSkip(s)
+			return nil, nil
+		}
+		break
+	}
+
+	var next token.Element
+	if s.Cur(&next) && ddlUpper(next) == "IF" {
+		// IF NOT EXISTS
+		s.Next(nil)
+		s.Next(nil)
+		s.Next(nil)
+	}
+
+	name, err := ddlName(s)
+	if err != nil {
+		return nil, err
+	}
+
+	t := new(ddlTable)
+	t.Name = name
+	t.fields = make(map[string]*ddlField)
+
+	ok := s.Next(&e)
+	if !ok {
+		return nil, s.EarlyEndL("LPAREN")
+	}
+	if e.Token != token.LPAREN {
+		return nil, e.NotMatchL("LPAREN")
+	}
+
+	// Split the definitions by the top-level COMMA.
+	var defs [][]token.Element
+	var def []token.Element
+	depth := 0
+	for {
+		ok = s.Next(&e)
+		if !ok {
+			return nil, s.EarlyEndL("RPAREN")
+		}
+		if e.Token == token.LPAREN {
+			depth++
+		}
+		if e.Token == token.RPAREN {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+		if e.Token == token.COMMA && depth == 0 {
+			defs = append(defs, def)
+			def = nil
+			continue
+		}
+		def = append(def, e)
+	}
+	if len(def) > 0 {
+		defs = append(defs, def)
+	}
+
+	var pks []string
+	for _, def := range defs {
+		if len(def) == 0 {
+			continue
+		}
+		names, err := ddlConstraint(def)
+		if err != nil {
+			return nil, err
+		}
+		if names != nil {
+			pks = append(pks, names...)
+			continue
+		}
+		idx, err := ddlIndex(def)
+		if err != nil {
+			return nil, err
+		}
+		if idx != nil {
+			t.indexes = append(t.indexes, idx)
+			continue
+		}
+		f, err := ddlColumn(def, dbType)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			// other constraints, such as foreign key
+			continue
+		}
+		t.addField(f)
+		if f.unique {
+			t.indexes = append(t.indexes, &Index{
+				Name:   ddlIndexName(true, []string{f.Name}),
+				Unique: true,
+				Fields: []string{f.Name},
+			})
+		}
+	}
+	for _, pk := range pks {
+		f := t.fields[pk]
+		if f == nil {
+			return nil, fmt.Errorf(`table "%s": can not find `+
+				`primary key field "%s"`, t.Name, pk)
+		}
+		f.primary = true
+	}
+	if dbType == "sqlite" || dbType == "sqlite3" {
+		ddlSqliteRowid(t)
+	}
+
+	// Table options: ENGINE=InnoDB COMMENT '...';
+	for s.Next(&e) {
+		if e.Token == ddlSemicolon {
+			break
+		}
+		if ddlUpper(e) != "COMMENT" {
+			continue
+		}
+		if s.Cur(&next) && next.Token == token.EQ {
+			s.Next(nil)
+		}
+		if s.Next(&e) && e.String {
+			t.Comment = e.Get()
+		}
+	}
+
+	return t, nil
+}
+
+// ddlIndex parses the table level index, such as:
+//
+//	UNIQUE INDEX `uk_name` (`name`)
+//	CONSTRAINT uk_name UNIQUE (name)
+//	KEY `idx_name_age` (`name`(10), `age`)
+//
+// If def is not an index, returns nil.
+func ddlIndex(def []token.Element) (*Index, error) {
+	idx := 0
+	if ddlUpper(def[0]) == "CONSTRAINT" {
+		idx = 2
+	}
+	if idx >= len(def) {
+		return nil, nil
+	}
+	var unique bool
+	switch ddlUpper(def[idx]) {
+	case "UNIQUE":
+		unique = true
+
+	case "INDEX", "KEY", "FULLTEXT", "SPATIAL":
+
+	default:
+		return nil, nil
+	}
+
+	var name string
+	if idx == 2 {
+		name = def[1].Get()
+	}
+	idx++
+	for ; idx < len(def); idx++ {
+		e := def[idx]
+		if e.Token == token.LPAREN {
+			break
+		}
+		switch ddlUpper(e) {
+		case "INDEX", "KEY":
+			continue
+		}
+		if e.Indent || e.String {
+			name = e.Get()
+		}
+	}
+	fields, err := ddlIndexFields(def[0], def[idx:])
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = ddlIndexName(unique, fields)
+	}
+	return &Index{Name: name, Unique: unique, Fields: fields}, nil
+}
+
+// ddlIndexFields reads the fields in "(...)", the length
+// and order of the fields are ignored.
+func ddlIndexFields(start token.Element, es []token.Element) ([]string, error) {
+	var fields []string
+	depth := 0
+	for _, e := range es {
+		switch {
+		case e.Token == token.LPAREN:
+			depth++
+
+		case e.Token == token.RPAREN:
+			depth--
+			if depth == 0 {
+				return fields, nil
+			}
+
+		case depth == 1 && (e.Indent || e.String):
+			switch ddlUpper(e) {
+			case "ASC", "DESC":
+				continue
+			}
+			fields = append(fields, e.Get())
+		}
+	}
+	if len(fields) == 0 {
+		return nil, start.FmtErrL("index fields is empty")
+	}
+	return fields, nil
+}
+
+func ddlIndexName(unique bool, fields []string) string {
+	prefix := "idx"
+	if unique {
+		prefix = "uk"
+	}
+	return prefix + "_" + strings.Join(fields, "_")
+}
+
+// CREATE [UNIQUE] INDEX [CONCURRENTLY] [IF NOT EXISTS] {name}
+//
+//	ON [ONLY] {table} [USING {method}] (...);
+func ddlCreateIndex(s *token.Scanner, unique bool, tables map[string]*ddlTable) error {
+	var e token.Element
+	var es []token.Element
+	for s.Next(&e) {
+		if e.Token == ddlSemicolon {
+			break
+		}
+		es = append(es, e)
+	}
+
+	var name, table string
+	idx := 0
+	for ; idx < len(es); idx++ {
+		e = es[idx]
+		switch ddlUpper(e) {
+		case "INDEX", "CONCURRENTLY", "IF", "NOT", "EXISTS", "ONLY":
+			continue
+
+		case "ON":
+			// The table name may be qualified.
+			for idx++; idx < len(es); idx++ {
+				e = es[idx]
+				if e.Token != token.PERIOD && !e.Indent && !e.String {
+					break
+				}
+				if ddlUpper(e) == "USING" {
+					break
+				}
+				table += e.Get()
+			}
+		}
+		if e.Token == token.LPAREN {
+			break
+		}
+		if table == "" && (e.Indent || e.String) {
+			name = e.Get()
+		}
+	}
+	t := tables[table]
+	if t == nil {
+		// The table is not created in the DDL.
+		return nil
+	}
+	fields, err := ddlIndexFields(es[0], es[idx:])
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = ddlIndexName(unique, fields)
+	}
+	t.indexes = append(t.indexes, &Index{
+		Name:   name,
+		Unique: unique,
+		Fields: fields,
+	})
+	return nil
+}
+
+// ddlConstraint parses the table level primary key. If def
+// is not a primary key, returns nil.
+func ddlConstraint(def []token.Element) ([]string, error) {
+	idx := 0
+	if ddlUpper(def[0]) == "CONSTRAINT" {
+		// CONSTRAINT {name} PRIMARY KEY (...)
+		idx = 2
+	}
+	if idx >= len(def) || ddlUpper(def[idx]) != "PRIMARY" {
+		return nil, nil
+	}
+	names := make([]string, 0, 1)
+	inParen := false
+	for _, e := range def[idx+1:] {
+		switch {
+		case e.Token == token.LPAREN:
+			inParen = true
+
+		case e.Token == token.RPAREN:
+			inParen = false
+
+		case inParen && (e.Indent || e.String):
+			names = append(names, e.Get())
+		}
+	}
+	if len(names) == 0 {
+		return nil, def[0].FmtErrL("primary key is empty")
+	}
+	return names, nil
+}
+
+func ddlColumn(def []token.Element, dbType string) (*ddlField, error) {
+	switch ddlUpper(def[0]) {
+	case "UNIQUE", "INDEX", "KEY", "FULLTEXT", "SPATIAL",
+		"FOREIGN", "CHECK", "CONSTRAINT", "EXCLUDE":
+		return nil, nil
+	}
+	if !def[0].Indent && !def[0].String {
+		return nil, def[0].NotMatchL("COLUMN")
+	}
+	f := new(ddlField)
+	f.Name = def[0].Get()
+	if len(def) == 1 {
+		return nil, def[0].FmtErrL(`missing type for column "%s"`, f.Name)
+	}
+
+	// The type is consisted of all the words before the
+	// column keywords, including "(...)".
+	idx := 1
+	var sb strings.Builder
+	depth := 0
+	for ; idx < len(def); idx++ {
+		e := def[idx]
+		if depth == 0 {
+			if _, ok := ddlColumnKeywords[ddlUpper(e)]; ok {
+				break
+			}
+			if ddlUpper(e) == "CHARACTER" && idx+1 < len(def) &&
+				ddlUpper(def[idx+1]) == "SET" {
+				break
+			}
+		}
+		switch e.Token {
+		case token.LPAREN:
+			depth++
+			sb.WriteString("(")
+			continue
+
+		case token.RPAREN:
+			depth--
+			sb.WriteString(")")
+			continue
+
+		case token.COMMA:
+			sb.WriteString(",")
+			continue
+		}
+		if sb.Len() > 0 && depth == 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(e.Get())
+	}
+	f.Type = sb.String()
+	switch strings.ToUpper(f.Type) {
+	case "SERIAL", "BIGSERIAL", "SMALLSERIAL":
+		f.autoIncr = true
+		f.NotNull = true
+	}
+
+	for ; idx < len(def); idx++ {
+		e := def[idx]
+		switch ddlUpper(e) {
+		case "NOT":
+			f.NotNull = true
+			idx++
+
+		case "PRIMARY":
+			f.primary = true
+			f.NotNull = true
+			idx++
+
+		case "AUTO_INCREMENT", "AUTOINCREMENT":
+			f.autoIncr = true
+
+		case "UNIQUE":
+			f.unique = true
+
+		case "IDENTITY":
+			// GENERATED ... AS IDENTITY
+			f.autoIncr = true
+
+		case "COMMENT":
+			if idx+1 < len(def) && def[idx+1].String {
+				f.Comment = def[idx+1].Get()
+				idx++
+			}
+
+		case "DEFAULT":
+			var vals []string
+			depth = 0
+			for idx+1 < len(def) {
+				next := def[idx+1]
+				if _, ok := ddlColumnKeywords[ddlUpper(next)]; ok && depth == 0 {
+					break
+				}
+				idx++
+				switch next.Token {
+				case token.LPAREN:
+					depth++
+				case token.RPAREN:
+					depth--
+				}
+				val := next.Get()
+				if next.String {
+					quo := string(next.StringRune)
+					val = quo + val + quo
+				}
+				vals = append(vals, val)
+			}
+			f.Default = strings.Join(vals, "")
+		}
+	}
+
+	return f, nil
+}
+
+// In sqlite, a single "INTEGER PRIMARY KEY" column is an
+// alias of the rowid, which is assigned automatically.
+func ddlSqliteRowid(t *ddlTable) {
+	var pk *ddlField
+	for _, f := range t.fields {
+		if !f.primary {
+			continue
+		}
+		if pk != nil {
+			return
+		}
+		pk = f
+	}
+	if pk != nil && strings.ToUpper(pk.Type) == "INTEGER" {
+		pk.autoIncr = true
+	}
+}
+
+// COMMENT ON TABLE {table} IS '...';
+// COMMENT ON COLUMN {table}.{column} IS '...';
+func ddlCommentOn(s *token.Scanner, tables map[string]*ddlTable) error {
+	var e token.Element
+	ok := s.Next(&e)
+	if !ok || ddlUpper(e) != "ON" {
+		ddlSkip(s)
+		return nil
+	}
+	ok = s.Next(&e)
+	if !ok {
+		return s.EarlyEndL("TABLE/COLUMN")
+	}
+	kind := ddlUpper(e)
+	if kind != "TABLE" && kind != "COLUMN" {
+		ddlSkip(s)
+		return nil
+	}
+	name, err := ddlName(s)
+	if err != nil {
+		return err
+	}
+	ok = s.Next(&e)
+	if !ok || ddlUpper(e) != "IS" {
+		ddlSkip(s)
+		return nil
+	}
+	ok = s.Next(&e)
+	if !ok {
+		return s.EarlyEndL("STRING")
+	}
+	comment := e.Get()
+	ddlSkip(s)
+
+	if kind == "TABLE" {
+		if t := tables[name]; t != nil {
+			t.Comment = comment
+		}
+		return nil
+	}
+	idx := strings.LastIndex(name, ".")
+	if idx < 0 {
+		return e.FmtErrL(`column "%s" missing table`, name)
+	}
+	t := tables[name[:idx]]
+	if t == nil {
+		return nil
+	}
+	if f := t.fields[name[idx+1:]]; f != nil {
+		f.Comment = comment
+	}
+	return nil
+}
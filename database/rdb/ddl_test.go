@@ -0,0 +1,161 @@
+package rdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dumpTable formats the table, one line for the table, each
+// field and each index, such as:
+//
+//	user "user table"
+//	id BIGINT pk auto null=false default= comment="user id"
+//	index uk_name [name] unique
+func dumpTable(table Table) []string {
+	lines := []string{fmt.Sprintf("%s %q", table.GetName(),
+		table.GetComment())}
+	for _, name := range table.FieldNames() {
+		f := table.Field(name)
+		line := f.GetName() + " " + f.GetType()
+		if f.IsPrimaryKey() {
+			line += " pk"
+		}
+		if f.IsAutoIncr() {
+			line += " auto"
+		}
+		if f.IsUnsigned() {
+			line += " unsigned"
+		}
+		line += fmt.Sprintf(" null=%v default=%s comment=%q",
+			f.IsNullable(), f.GetDefault(), f.GetComment())
+		lines = append(lines, line)
+	}
+	for _, idx := range table.GetIndexes() {
+		line := fmt.Sprintf("index %s %v", idx.Name, idx.Fields)
+		if idx.Unique {
+			line += " unique"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func expectDDL(t *testing.T, dbType string, lines []string, expects [][]string) {
+	ts, err := ParseDDL(lines, dbType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != len(expects) {
+		t.Fatalf("%d tables, expect %d", len(ts), len(expects))
+	}
+	for idx, table := range ts {
+		got := strings.Join(dumpTable(table), "\n")
+		expect := strings.Join(expects[idx], "\n")
+		if got != expect {
+			t.Errorf("table %d:\n got:\n%s\nwant:\n%s", idx, got, expect)
+		}
+	}
+}
+
+func TestDDLMysql(t *testing.T) {
+	expectDDL(t, "mysql", []string{
+		"DROP TABLE IF EXISTS `user`;",
+		"CREATE TABLE IF NOT EXISTS `user` (",
+		"  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT 'user id',",
+		"  `name` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'user -- name',",
+		"  `score` DECIMAL(10,2) DEFAULT 0.00, -- the score",
+		"  `create_time` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,",
+		"  PRIMARY KEY (`id`),",
+		"  UNIQUE INDEX `uk_name` (`name`),",
+		"  KEY (`score`, `create_time` DESC)",
+		") ENGINE=InnoDB COMMENT='user table';",
+	}, [][]string{{
+		`user "user table"`,
+		`id BIGINT UNSIGNED pk auto unsigned null=false default= comment="user id"`,
+		`name VARCHAR(64) null=false default='' comment="user -- name"`,
+		`score DECIMAL(10,2) null=true default=0.00 comment=""`,
+		`create_time DATETIME null=true default=CURRENT_TIMESTAMP comment=""`,
+		`index uk_name [name] unique`,
+		`index idx_score_create_time [score create_time]`,
+	}})
+}
+
+func TestDDLPostgres(t *testing.T) {
+	expectDDL(t, "postgres", []string{
+		"CREATE TABLE public.detail (",
+		"  id serial,",
+		"  text character varying(32) NOT NULL,",
+		"  CONSTRAINT pk_detail PRIMARY KEY (id)",
+		");",
+		"COMMENT ON TABLE public.detail IS 'the detail';",
+		"COMMENT ON COLUMN public.detail.text IS 'detail text';",
+		"CREATE UNIQUE INDEX IF NOT EXISTS uk_text ON public.detail USING btree (text);",
+	}, [][]string{{
+		`public.detail "the detail"`,
+		`id serial pk auto null=false default= comment=""`,
+		`text character varying(32) null=false default= comment="detail text"`,
+		`index uk_text [text] unique`,
+	}})
+}
+
+func TestDDLSqlite(t *testing.T) {
+	expectDDL(t, "sqlite", []string{
+		"CREATE TABLE user (",
+		"  id INTEGER PRIMARY KEY,",
+		"  name TEXT NOT NULL UNIQUE,",
+		"  age INT DEFAULT 18",
+		");",
+		"CREATE INDEX idx_user_name ON user(name);",
+	}, [][]string{{
+		`user ""`,
+		`id INTEGER pk auto null=false default= comment=""`,
+		`name TEXT null=false default= comment=""`,
+		`age INT null=true default=18 comment=""`,
+		`index uk_name [name] unique`,
+		`index idx_user_name [name]`,
+	}})
+}
+
+func TestInitSchemaAlias(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendb-schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "create.sql")
+	err = ioutil.WriteFile(path, []byte("CREATE TABLE user(id BIGINT);"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { sess = nil }()
+
+	cases := []struct {
+		dbType string
+		expect string
+	}{
+		{"", "mysql"},
+		{"mysql", "mysql"},
+		{"pg", "postgres"},
+		{"postgres", "postgres"},
+		{"sqlite3", "sqlite"},
+		{"sqlite", "sqlite"},
+	}
+	for _, c := range cases {
+		err = InitSchema(path, c.dbType)
+		if err != nil {
+			t.Fatalf("%q: %v", c.dbType, err)
+		}
+		if got := ConnType(""); got != c.expect {
+			t.Errorf("%q: ConnType = %q, expect %q", c.dbType, got, c.expect)
+		}
+	}
+
+	err = InitSchema(path, "oracle")
+	if err == nil || !strings.Contains(err.Error(), "unsupport database type") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
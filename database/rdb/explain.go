@@ -0,0 +1,177 @@
+package rdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/fioncat/go-gendb/misc/errors"
+)
+
+// Plan is the query plan of a sql statement, it is
+// converted from the output of "EXPLAIN", and is used
+// to check the performance problems of the statement.
+type Plan struct {
+	// Tables are the tables accessed by the statement, in
+	// the order of the plan (including the subqueries).
+	Tables []*PlanTable
+
+	// Filesort indicates that the statement needs extra
+	// sorting, which can not be done by index.
+	Filesort bool
+
+	// Temporary indicates that the statement needs a
+	// temporary table, such as GROUP BY, DISTINCT or UNION.
+	Temporary bool
+
+	// Message is the message of the plan rather than
+	// tables, such as "Impossible WHERE".
+	Message string
+}
+
+// PlanTable is the access of a table in the plan.
+type PlanTable struct {
+	Name string
+
+	// AccessType is the join type, such as "ALL" (full
+	// table scan), "index", "range", "ref", "const".
+	AccessType string
+
+	PossibleKeys []string
+	Key          string
+
+	// Rows is the estimated rows examined for each scan.
+	Rows int64
+
+	// Inner indicates that the table is the inner table
+	// of a nested loop join, it is scanned once for each
+	// row of the outer tables.
+	Inner bool
+}
+
+// explainOper is implemented by the databases which
+// support Explain.
+type explainOper interface {
+	Explain(db *sql.DB, sql string, prepares []interface{}) (*Plan, error)
+}
+
+// Explain returns the query plan of the sql statement. If
+// the database does not support it, returns an error; use
+// CanExplain to check it first.
+func (s *Session) Explain(sql string, prepares []interface{}) (*Plan, error) {
+	if s.db == nil {
+		return nil, errors.New("can not explain sql " +
+			"without database connection")
+	}
+	oper, ok := s.oper.(explainOper)
+	if !ok {
+		return nil, fmt.Errorf(`database "%s" does `+
+			`not support explain`, s.dbType)
+	}
+	return oper.Explain(s.db, sql, prepares)
+}
+
+// CanExplain reports whether the database supports Explain.
+func (s *Session) CanExplain() bool {
+	_, ok := s.oper.(explainOper)
+	return ok
+}
+
+func (*mysqlOper) Explain(db *sql.DB, sql string, prepares []interface{}) (*Plan, error) {
+	var data string
+	err := db.QueryRow("EXPLAIN FORMAT=JSON "+sql, prepares...).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	return parseMysqlPlan([]byte(data))
+}
+
+// parseMysqlPlan converts the output of "EXPLAIN FORMAT=JSON"
+// to Plan. The output is a tree of query blocks, the tables
+// are found by walking it, so that the nested loops, the
+// subqueries and the unions are all included.
+func parseMysqlPlan(data []byte) (*Plan, error) {
+	var root map[string]interface{}
+	err := json.Unmarshal(data, &root)
+	if err != nil {
+		return nil, errors.Trace("parse explain", err)
+	}
+	p := new(Plan)
+	p.walkMysql(root, false)
+	return p, nil
+}
+
+func (p *Plan) walkMysql(v interface{}, inner bool) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			p.walkMysql(item, inner)
+		}
+
+	case map[string]interface{}:
+		if name, ok := v["table_name"].(string); ok {
+			p.addMysqlTable(name, v, inner)
+		}
+		// Walk the keys in order, so that the order of the
+		// tables is stable.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			item := v[key]
+			switch key {
+			case "using_filesort":
+				if item == true {
+					p.Filesort = true
+				}
+
+			case "using_temporary_table":
+				if item == true {
+					p.Temporary = true
+				}
+
+			case "message":
+				if msg, ok := item.(string); ok {
+					p.Message = msg
+				}
+
+			case "nested_loop":
+				// The first table is the outer table, others
+				// are inner tables.
+				loop, _ := item.([]interface{})
+				for idx, sub := range loop {
+					p.walkMysql(sub, idx > 0)
+				}
+
+			default:
+				p.walkMysql(item, inner)
+			}
+		}
+	}
+}
+
+func (p *Plan) addMysqlTable(name string, v map[string]interface{}, inner bool) {
+	t := &PlanTable{Name: name, Inner: inner}
+	t.AccessType, _ = v["access_type"].(string)
+	t.Key, _ = v["key"].(string)
+	keys, _ := v["possible_keys"].([]interface{})
+	for _, key := range keys {
+		if s, ok := key.(string); ok {
+			t.PossibleKeys = append(t.PossibleKeys, s)
+		}
+	}
+	// The rows might be number or string in different
+	// versions.
+	switch rows := v["rows_examined_per_scan"].(type) {
+	case float64:
+		t.Rows = int64(rows)
+
+	case string:
+		t.Rows, _ = strconv.ParseInt(rows, 10, 64)
+	}
+	p.Tables = append(p.Tables, t)
+}
@@ -0,0 +1,106 @@
+package rdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseMysqlPlan(t *testing.T) {
+	data := `{
+  "query_block": {
+    "select_id": 1,
+    "ordering_operation": {
+      "using_filesort": true,
+      "grouping_operation": {
+        "using_temporary_table": true,
+        "nested_loop": [
+          {
+            "table": {
+              "table_name": "u",
+              "access_type": "range",
+              "possible_keys": ["idx_age"],
+              "key": "idx_age",
+              "rows_examined_per_scan": 120
+            }
+          },
+          {
+            "table": {
+              "table_name": "o",
+              "access_type": "ALL",
+              "possible_keys": ["idx_user"],
+              "rows_examined_per_scan": 50000
+            }
+          }
+        ]
+      }
+    }
+  }
+}`
+	p, err := parseMysqlPlan([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("filesort=%v, temporary=%v\n", p.Filesort, p.Temporary)
+	for _, t := range p.Tables {
+		fmt.Printf("  %s %s, keys=%v, key=%s, rows=%d, inner=%v\n",
+			t.Name, t.AccessType, t.PossibleKeys, t.Key, t.Rows, t.Inner)
+	}
+}
+
+func TestParseMysqlPlanOrder(t *testing.T) {
+	data := `{
+  "query_block": {
+    "select_id": 1,
+    "table": {
+      "table_name": "u",
+      "access_type": "ALL",
+      "rows_examined_per_scan": 100,
+      "attached_subqueries": [
+        {
+          "query_block": {
+            "select_id": 2,
+            "table": {
+              "table_name": "u",
+              "access_type": "ref",
+              "key": "idx_age",
+              "rows_examined_per_scan": 3
+            }
+          }
+        }
+      ]
+    },
+    "optimized_away_subqueries": [
+      {
+        "query_block": {
+          "select_id": 3,
+          "table": {
+            "table_name": "o",
+            "access_type": "const",
+            "key": "PRIMARY",
+            "rows_examined_per_scan": 1
+          }
+        }
+      }
+    ]
+  }
+}`
+	var first string
+	for i := 0; i < 20; i++ {
+		p, err := parseMysqlPlan([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var order string
+		for _, t := range p.Tables {
+			order += fmt.Sprintf("%s(%s) ", t.Name, t.AccessType)
+		}
+		if i == 0 {
+			first = order
+			fmt.Println(order)
+			continue
+		}
+		if order != first {
+			t.Fatalf("unstable order: %s != %s", order, first)
+		}
+	}
+}
@@ -34,7 +34,8 @@ func mysqlConnect(conn *conn.Config) (*sql.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	log.Infof("[database] [mysql] connect: %s", dsn)
+	log.Infof("[database] [mysql] connect: %s@tcp(%s:%d)/%s",
+		conn.User, addr.Host, addr.Port, conn.Database)
 	return db, nil
 }
 
@@ -53,6 +54,16 @@ func (f *mysqlField) GetComment() string { return f.Comment }
 func (f *mysqlField) GetType() string    { return f.Type }
 func (f *mysqlField) IsPrimaryKey() bool { return f.Key.String == "PRI" }
 func (f *mysqlField) IsAutoIncr() bool   { return f.Extract.String == "auto_increment" }
+func (f *mysqlField) IsNullable() bool   { return f.Null == "YES" }
+func (f *mysqlField) IsUnsigned() bool   { return typeUnsigned(f.Type) }
+func (f *mysqlField) GetLength() int     { return typeLength(f.Type) }
+
+func (f *mysqlField) GetDefault() string {
+	if !f.Default.Valid {
+		return ""
+	}
+	return sqlLiteral(f.Default.String)
+}
 
 type mysqlTable struct {
 	Name    string
@@ -60,12 +71,23 @@ type mysqlTable struct {
 
 	fields     map[string]*mysqlField
 	fieldNames []string
+
+	indexes []*Index
 }
 
-func (t *mysqlTable) GetName() string         { return t.Name }
-func (t *mysqlTable) GetComment() string      { return t.Comment }
-func (t *mysqlTable) Field(name string) Field { return t.fields[name] }
-func (t *mysqlTable) FieldNames() []string    { return t.fieldNames }
+func (t *mysqlTable) GetName() string      { return t.Name }
+func (t *mysqlTable) GetComment() string   { return t.Comment }
+func (t *mysqlTable) FieldNames() []string { return t.fieldNames }
+func (t *mysqlTable) GetIndexes() []*Index { return t.indexes }
+
+// Field returns nil rather than the typed nil pointer if
+// the field does not exist.
+func (t *mysqlTable) Field(name string) Field {
+	if f, ok := t.fields[name]; ok {
+		return f
+	}
+	return nil
+}
 
 type mysqlCheckField struct {
 	Id           sql.NullInt32
@@ -140,6 +162,42 @@ func (t *mysqlTable) setFieldsComment(db *sql.DB, dbName string) error {
 	return nil
 }
 
+// Set indexes to the table. The columns of "SHOW INDEX"
+// differ between mysql versions, so they are read by name.
+func (t *mysqlTable) setIndexes(db *sql.DB) error {
+	rows, err := db.Query(fmt.Sprintf("SHOW INDEX FROM %s", t.Name))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		for idx := range vals {
+			vals[idx] = new(sql.NullString)
+		}
+		err = rows.Scan(vals...)
+		if err != nil {
+			return err
+		}
+		m := make(map[string]string, len(cols))
+		for idx, col := range cols {
+			m[col] = vals[idx].(*sql.NullString).String
+		}
+		name := m["Key_name"]
+		if name == "PRIMARY" {
+			continue
+		}
+		t.indexes = appendIndex(t.indexes, name,
+			m["Non_unique"] == "0", m["Column_name"])
+	}
+	return rows.Err()
+}
+
 type mysqlOper struct {
 	dbName string
 }
@@ -177,19 +235,30 @@ func (o *mysqlOper) Desc(db *sql.DB, tableName string) (Table, error) {
 		table.fieldNames = append(table.fieldNames, field.Name)
 	}
 
-	// err = table.setComment(db, o.dbName)
-	// if err != nil {
-	// 	return nil, err
-	// }
+	err = table.setIndexes(db)
+	if err != nil {
+		return nil, err
+	}
 
-	// err = table.setFieldsComment(db, o.dbName)
-	// if err != nil {
-	// 	return nil, err
-	// }
+	err = table.setComment(db, o.dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = table.setFieldsComment(db, o.dbName)
+	if err != nil {
+		return nil, err
+	}
 
 	return table, nil
 }
 
+func (*mysqlOper) Tables(db *sql.DB) ([]string, error) {
+	return queryNames(db, "SHOW TABLES")
+}
+
+func (*mysqlOper) Rebind(sql string) string { return sql }
+
 func (*mysqlOper) Check(db *sql.DB, sql string, prepares []interface{}) (CheckResult, error) {
 	sql = "DESC " + sql
 	result := new(mysqlCheckResult)
@@ -223,33 +292,65 @@ func (*mysqlOper) Check(db *sql.DB, sql string, prepares []interface{}) (CheckRe
 	return result, nil
 }
 
+// ConvertType converts the mysql type to Go type. The
+// UNSIGNED integers are converted to the unsigned types.
+//
+// DECIMAL and NUMERIC are converted to string to keep them
+// exact, since float64 loses precision. Add a type mapping
+// to "gendb.yaml" to use a decimal type instead, see the
+// config package.
+//
+// DATE, DATETIME and TIMESTAMP are converted to time.Time,
+// the driver scans them to time.Time only if the DSN has
+// "parseTime=true", the connection of the generated code
+// must set it as mysqlConnect does. TIME is kept as string,
+// since it is a duration rather than a point in time.
 func (*mysqlOper) ConvertType(sqlType string) string {
 	sqlType = strings.ToUpper(sqlType)
+	unsigned := typeUnsigned(sqlType)
 	switch {
 	case strings.HasPrefix(sqlType, "VARCHAR"):
 		fallthrough
 	case strings.HasPrefix(sqlType, "CHAR"):
 		fallthrough
-	case strings.HasPrefix(sqlType, "TEXT"):
+	case strings.Contains(sqlType, "TEXT"):
 		return "string"
-	case strings.HasPrefix(sqlType, "INT"):
-		return "int32"
 	case strings.HasPrefix(sqlType, "BIGINT"):
+		if unsigned {
+			return "uint64"
+		}
 		return "int64"
+	case strings.HasPrefix(sqlType, "INT"):
+		fallthrough
+	case strings.HasPrefix(sqlType, "MEDIUMINT"):
+		fallthrough
 	case strings.HasPrefix(sqlType, "SMALLINT"):
 		fallthrough
 	case strings.HasPrefix(sqlType, "TINYINT"):
+		if unsigned {
+			return "uint32"
+		}
 		return "int32"
 	case strings.HasPrefix(sqlType, "FLOAT"):
 		return "float64"
 	case strings.HasPrefix(sqlType, "DOUBLE"):
 		return "float64"
 	case strings.HasPrefix(sqlType, "DECIMAL"):
-		return "float64"
-	case strings.HasPrefix(sqlType, "DATE"):
+		fallthrough
+	case strings.HasPrefix(sqlType, "NUMERIC"):
 		return "string"
-	case strings.HasPrefix(sqlType, "Time"):
+	case strings.HasPrefix(sqlType, "DATE"):
+		fallthrough
+	case strings.HasPrefix(sqlType, "TIMESTAMP"):
+		return "time.Time"
+	case strings.HasPrefix(sqlType, "TIME"):
 		return "string"
+	case strings.HasPrefix(sqlType, "YEAR"):
+		return "int32"
+	case strings.Contains(sqlType, "BLOB"):
+		fallthrough
+	case strings.Contains(sqlType, "BINARY"):
+		return "[]byte"
 	}
 	return "string"
 }
@@ -258,16 +359,22 @@ func (*mysqlOper) SqlType(goType string) string {
 	switch goType {
 	case "int8", "uint8", "bool", "sql.NullBool":
 		return "TINYINT"
-	case "int32", "int", "uint32", "sql.NullInt32":
+	case "int32", "int", "sql.NullInt32":
 		return "INT"
-	case "int64", "uint64", "sql.NullInt64":
+	case "uint32":
+		return "INT UNSIGNED"
+	case "int64", "sql.NullInt64":
 		return "BIGINT"
+	case "uint64":
+		return "BIGINT UNSIGNED"
 	case "float32":
 		return "FLOAT"
 	case "float64":
 		return "DOUBLE"
 	case "time.Time", "sql.NullTime":
 		return "DATETIME"
+	case "[]byte":
+		return "BLOB"
 	}
 
 	return "VARCHAR(256)"
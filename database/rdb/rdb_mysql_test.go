@@ -0,0 +1,115 @@
+package rdb
+
+import (
+	"testing"
+
+	"github.com/fioncat/go-gendb/config"
+)
+
+func TestMysqlConvertType(t *testing.T) {
+	cases := []struct {
+		sqlType string
+		expect  string
+	}{
+		{"varchar(64)", "string"},
+		{"CHAR(36)", "string"},
+		{"longtext", "string"},
+		{"bigint", "int64"},
+		{"bigint(20) unsigned", "uint64"},
+		{"BIGINT UNSIGNED", "uint64"},
+		{"int(11)", "int32"},
+		{"int unsigned", "uint32"},
+		{"mediumint", "int32"},
+		{"smallint(5) unsigned", "uint32"},
+		{"tinyint(1)", "int32"},
+		{"tinyint unsigned", "uint32"},
+		{"float", "float64"},
+		{"double", "float64"},
+		{"decimal(10,2)", "string"},
+		{"decimal(10,2) unsigned", "string"},
+		{"numeric", "string"},
+		{"date", "time.Time"},
+		{"datetime", "time.Time"},
+		{"datetime(6)", "time.Time"},
+		{"timestamp", "time.Time"},
+		{"time", "string"},
+		{"year", "int32"},
+		{"blob", "[]byte"},
+		{"varbinary(16)", "[]byte"},
+		{"json", "string"},
+	}
+	oper := new(mysqlOper)
+	for _, c := range cases {
+		got := oper.ConvertType(c.sqlType)
+		if got != c.expect {
+			t.Errorf("%s: got %s, expect %s", c.sqlType, got, c.expect)
+		}
+	}
+}
+
+func TestMysqlFieldType(t *testing.T) {
+	sess := newSess(&mysqlOper{}, mysqlConnect)
+	cases := []struct {
+		field   *ddlField
+		pointer string
+		sqlNull string
+	}{
+		{&ddlField{Type: "bigint", NotNull: true}, "int64", "int64"},
+		{&ddlField{Type: "bigint", primary: true}, "int64", "int64"},
+		{&ddlField{Type: "bigint"}, "*int64", "sql.NullInt64"},
+		{&ddlField{Type: "int"}, "*int32", "sql.NullInt32"},
+		{&ddlField{Type: "varchar(64)"}, "*string", "sql.NullString"},
+		{&ddlField{Type: "double"}, "*float64", "sql.NullFloat64"},
+		{&ddlField{Type: "datetime"}, "*time.Time", "sql.NullTime"},
+		{&ddlField{Type: "decimal(10,2)"}, "*string", "sql.NullString"},
+		{&ddlField{Type: "blob"}, "[]byte", "[]byte"},
+		// The unsigned types have no "sql.NullX", fall back
+		// to pointer.
+		{&ddlField{Type: "bigint unsigned", NotNull: true}, "uint64", "uint64"},
+		{&ddlField{Type: "bigint unsigned"}, "*uint64", "*uint64"},
+		{&ddlField{Type: "int unsigned"}, "*uint32", "*uint32"},
+	}
+	for _, c := range cases {
+		got := sess.FieldType(c.field, NullPointer)
+		if got != c.pointer {
+			t.Errorf("%s null=%v pointer: got %s, expect %s", c.field.Type,
+				c.field.IsNullable(), got, c.pointer)
+		}
+		got = sess.FieldType(c.field, NullSql)
+		if got != c.sqlNull {
+			t.Errorf("%s null=%v sqlnull: got %s, expect %s", c.field.Type,
+				c.field.IsNullable(), got, c.sqlNull)
+		}
+	}
+}
+
+func TestMysqlCustomType(t *testing.T) {
+	SetTypes([]*config.Type{{
+		Db:     "decimal",
+		Go:     "decimal.Decimal",
+		Null:   "decimal.NullDecimal",
+		Import: "github.com/shopspring/decimal",
+	}})
+	defer SetTypes(nil)
+
+	sess := newSess(&mysqlOper{}, mysqlConnect)
+	cases := []struct {
+		field  *ddlField
+		expect string
+	}{
+		{&ddlField{Type: "decimal(10,2)", NotNull: true}, "decimal.Decimal"},
+		{&ddlField{Type: "DECIMAL(10,2) UNSIGNED", NotNull: true}, "decimal.Decimal"},
+		{&ddlField{Type: "decimal(10,2)"}, "decimal.NullDecimal"},
+		{&ddlField{Type: "numeric"}, "*string"},
+	}
+	for _, c := range cases {
+		got := sess.FieldType(c.field, NullPointer)
+		if got != c.expect {
+			t.Errorf("%s: got %s, expect %s", c.field.Type, got, c.expect)
+		}
+	}
+	name, path := TypeImport("decimal.NullDecimal")
+	if name != "decimal" || path != "github.com/shopspring/decimal" {
+		t.Errorf("import of decimal: %s %s", name, path)
+	}
+}
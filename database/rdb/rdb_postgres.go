@@ -0,0 +1,432 @@
+package rdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+
+	"github.com/fioncat/go-gendb/api/sql/run"
+	"github.com/fioncat/go-gendb/database/conn"
+	"github.com/fioncat/go-gendb/misc/addr"
+	"github.com/fioncat/go-gendb/misc/errors"
+	"github.com/fioncat/go-gendb/misc/log"
+)
+
+// implement of postgres session
+
+func postgresConnect(conn *conn.Config) (*sql.DB, error) {
+	addr, err := addr.Parse(conn.Addr, 5432)
+	if err != nil {
+		return nil, errors.Trace("parse addr", err)
+	}
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		postgresDsnValue(addr.Host),
+		addr.Port,
+		postgresDsnValue(conn.User),
+		postgresDsnValue(conn.Password),
+		postgresDsnValue(conn.Database))
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, errors.Trace("connect db", err)
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("[database] [postgres] connect: host=%s port=%d dbname=%s",
+		addr.Host, addr.Port, conn.Database)
+	return db, nil
+}
+
+// postgresDsnValue quotes the value of the key/value DSN, so
+// that the spaces, quotes and "=" in it (usually in password)
+// are kept. See the "Connection Strings" of libpq.
+func postgresDsnValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+type postgresField struct {
+	Name     string
+	Type     string
+	Null     bool
+	Default  string
+	Identity bool
+	Comment  string
+
+	primary bool
+}
+
+func (f *postgresField) GetName() string    { return f.Name }
+func (f *postgresField) GetComment() string { return f.Comment }
+func (f *postgresField) GetType() string    { return f.Type }
+func (f *postgresField) IsPrimaryKey() bool { return f.primary }
+func (f *postgresField) IsNullable() bool   { return f.Null }
+func (f *postgresField) IsUnsigned() bool   { return false }
+func (f *postgresField) GetLength() int     { return typeLength(f.Type) }
+func (f *postgresField) GetDefault() string { return postgresDefault(f.Default) }
+
+// serial/bigserial columns are expanded into a "nextval"
+// default by postgres, identity columns are flagged by
+// information_schema.
+func (f *postgresField) IsAutoIncr() bool {
+	return f.Identity || strings.HasPrefix(f.Default, "nextval(")
+}
+
+type postgresTable struct {
+	Name    string
+	Comment string
+
+	fields     map[string]*postgresField
+	fieldNames []string
+
+	indexes []*Index
+}
+
+func (t *postgresTable) GetName() string      { return t.Name }
+func (t *postgresTable) GetComment() string   { return t.Comment }
+func (t *postgresTable) FieldNames() []string { return t.fieldNames }
+func (t *postgresTable) GetIndexes() []*Index { return t.indexes }
+
+// Field returns nil rather than the typed nil pointer if
+// the field does not exist.
+func (t *postgresTable) Field(name string) Field {
+	if f, ok := t.fields[name]; ok {
+		return f
+	}
+	return nil
+}
+
+type postgresCheckResult struct {
+	err   error
+	warns []string
+}
+
+func (r *postgresCheckResult) GetErr() error      { return r.err }
+func (r *postgresCheckResult) GetWarns() []string { return r.warns }
+
+type postgresOper struct{}
+
+const (
+	postgresDescSQL = `SELECT c.column_name,
+  format_type(a.atttypid, a.atttypmod),
+  c.is_nullable,
+  COALESCE(c.column_default, ''),
+  c.is_identity,
+  COALESCE(d.description, '')
+FROM information_schema.columns c
+JOIN pg_catalog.pg_attribute a
+  ON a.attrelid = $1::regclass AND a.attname = c.column_name
+LEFT JOIN pg_catalog.pg_description d
+  ON d.objoid = a.attrelid AND d.objsubid = a.attnum
+WHERE c.table_schema = $2 AND c.table_name = $3
+ORDER BY c.ordinal_position`
+
+	postgresPrimarySQL = `SELECT a.attname
+FROM pg_catalog.pg_index i
+JOIN pg_catalog.pg_attribute a
+  ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+WHERE i.indrelid = $1::regclass AND i.indisprimary`
+
+	postgresIndexSQL = `SELECT i.relname, ix.indisunique, a.attname
+FROM pg_catalog.pg_index ix
+JOIN pg_catalog.pg_class i ON i.oid = ix.indexrelid
+JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+JOIN pg_catalog.pg_attribute a
+  ON a.attrelid = ix.indrelid AND a.attnum = k.attnum
+WHERE ix.indrelid = $1::regclass AND NOT ix.indisprimary
+ORDER BY i.relname, k.ord`
+
+	postgresTablesSQL = `SELECT table_name FROM information_schema.tables
+WHERE table_schema = 'public' AND table_type = 'BASE TABLE'
+ORDER BY table_name`
+
+	postgresTableCommentSQL = `SELECT COALESCE(obj_description($1::regclass, 'pg_class'), '')`
+)
+
+func (o *postgresOper) Init(sess *Session) {}
+
+// split "schema.table" into schema and table, the
+// default schema is "public".
+func postgresSplitName(tableName string) (string, string) {
+	tmp := strings.SplitN(tableName, ".", 2)
+	if len(tmp) == 2 {
+		return tmp[0], tmp[1]
+	}
+	return "public", tableName
+}
+
+func (o *postgresOper) Desc(db *sql.DB, tableName string) (Table, error) {
+	schema, name := postgresSplitName(tableName)
+	regName := fmt.Sprintf("%s.%s", schema, name)
+
+	rows, err := db.Query(postgresDescSQL, regName, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	table := new(postgresTable)
+	table.Name = tableName
+	table.fields = make(map[string]*postgresField)
+	table.fieldNames = make([]string, 0)
+
+	for rows.Next() {
+		field := new(postgresField)
+		var null, identity string
+		err = rows.Scan(&field.Name, &field.Type, &null,
+			&field.Default, &identity, &field.Comment)
+		if err != nil {
+			return nil, err
+		}
+		field.Null = null == "YES"
+		field.Identity = identity == "YES"
+		if strings.Contains(field.Comment, "\n") {
+			field.Comment = strings.ReplaceAll(field.Comment, "\n", " ")
+		}
+		table.fields[field.Name] = field
+		table.fieldNames = append(table.fieldNames, field.Name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(table.fieldNames) == 0 {
+		return nil, fmt.Errorf(`table "%s" does not exist`, tableName)
+	}
+
+	err = table.setPrimary(db, regName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = table.setComment(db, regName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = table.setIndexes(db, regName)
+	if err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+func (t *postgresTable) setPrimary(db *sql.DB, regName string) error {
+	rows, err := db.Query(postgresPrimarySQL, regName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return err
+		}
+		field := t.fields[name]
+		if field != nil {
+			field.primary = true
+		}
+	}
+	return rows.Err()
+}
+
+func (t *postgresTable) setIndexes(db *sql.DB, regName string) error {
+	rows, err := db.Query(postgresIndexSQL, regName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, field string
+		var unique bool
+		err = rows.Scan(&name, &unique, &field)
+		if err != nil {
+			return err
+		}
+		t.indexes = appendIndex(t.indexes, name, unique, field)
+	}
+	return rows.Err()
+}
+
+// postgresDefault removes the type cast from the default
+// expression, such as "'abc'::character varying". The
+// "nextval" of serial columns is not a real default value.
+func postgresDefault(def string) string {
+	if def == "" || strings.HasPrefix(def, "nextval(") {
+		return ""
+	}
+	var quo bool
+	for idx, r := range def {
+		switch {
+		case r == '\'':
+			quo = !quo
+
+		case !quo && strings.HasPrefix(def[idx:], "::"):
+			return def[:idx]
+		}
+	}
+	return def
+}
+
+func (t *postgresTable) setComment(db *sql.DB, regName string) error {
+	rows, err := db.Query(postgresTableCommentSQL, regName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err = rows.Scan(&t.Comment)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(t.Comment, "\n") {
+			t.Comment = strings.ReplaceAll(t.Comment, "\n", " ")
+		}
+	}
+	return rows.Err()
+}
+
+// Tables only returns the tables in "public" schema, tables
+// in other schemas need to be described with the qualified
+// name.
+func (*postgresOper) Tables(db *sql.DB) ([]string, error) {
+	return queryNames(db, postgresTablesSQL)
+}
+
+// postgres uses "$n" as the bind variable, while go-gendb
+// generates "?".
+func (*postgresOper) Rebind(sql string) string {
+	return run.BindDollar(sql)
+}
+
+func (o *postgresOper) Check(db *sql.DB, sql string, prepares []interface{}) (CheckResult, error) {
+	sql = "EXPLAIN " + o.Rebind(sql)
+	result := new(postgresCheckResult)
+	rows, err := db.Query(sql, prepares...)
+	if err != nil {
+		result.err = err
+		return result, nil
+	}
+	defer rows.Close()
+
+	var plans []string
+	for rows.Next() {
+		var plan string
+		err = rows.Scan(&plan)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+
+	for _, plan := range plans {
+		plan = strings.TrimSpace(plan)
+		plan = strings.TrimPrefix(plan, "->")
+		plan = strings.TrimSpace(plan)
+		if !strings.HasPrefix(plan, "Seq Scan on ") {
+			continue
+		}
+		table := strings.TrimPrefix(plan, "Seq Scan on ")
+		if idx := strings.Index(table, " "); idx > 0 {
+			table = table[:idx]
+		}
+		warn := fmt.Sprintf(`full-table-scan for table "%s", rows=%d`,
+			table, postgresPlanRows(plan))
+		result.warns = append(result.warns, warn)
+	}
+
+	return result, nil
+}
+
+// extract the estimated rows from a plan line, such as
+// "Seq Scan on user  (cost=0.00..1.05 rows=5 width=36)"
+func postgresPlanRows(plan string) int64 {
+	idx := strings.Index(plan, "rows=")
+	if idx < 0 {
+		return 0
+	}
+	plan = plan[idx+len("rows="):]
+	if idx = strings.Index(plan, " "); idx > 0 {
+		plan = plan[:idx]
+	}
+	rows, _ := strconv.ParseInt(plan, 10, 64)
+	return rows
+}
+
+func (*postgresOper) ConvertType(sqlType string) string {
+	sqlType = strings.ToUpper(sqlType)
+	switch {
+	case strings.HasPrefix(sqlType, "INTERVAL"):
+		return "string"
+	case strings.HasPrefix(sqlType, "BIGINT"),
+		strings.HasPrefix(sqlType, "BIGSERIAL"),
+		strings.HasPrefix(sqlType, "INT8"):
+		return "int64"
+	case strings.HasPrefix(sqlType, "SMALLINT"),
+		strings.HasPrefix(sqlType, "SMALLSERIAL"),
+		strings.HasPrefix(sqlType, "INTEGER"),
+		strings.HasPrefix(sqlType, "SERIAL"),
+		strings.HasPrefix(sqlType, "INT"):
+		return "int32"
+	case strings.HasPrefix(sqlType, "REAL"),
+		strings.HasPrefix(sqlType, "FLOAT4"):
+		return "float32"
+	case strings.HasPrefix(sqlType, "DOUBLE"),
+		strings.HasPrefix(sqlType, "FLOAT"):
+		return "float64"
+	case strings.HasPrefix(sqlType, "NUMERIC"),
+		strings.HasPrefix(sqlType, "DECIMAL"),
+		strings.HasPrefix(sqlType, "MONEY"):
+		// keep exact
+		return "string"
+	case strings.HasPrefix(sqlType, "BOOL"):
+		return "bool"
+	case strings.HasPrefix(sqlType, "BYTEA"):
+		return "[]byte"
+	case strings.HasPrefix(sqlType, "CHARACTER"),
+		strings.HasPrefix(sqlType, "VARCHAR"),
+		strings.HasPrefix(sqlType, "CHAR"),
+		strings.HasPrefix(sqlType, "TEXT"),
+		strings.HasPrefix(sqlType, "UUID"),
+		strings.HasPrefix(sqlType, "JSON"):
+		return "string"
+	case strings.HasPrefix(sqlType, "DATE"),
+		strings.HasPrefix(sqlType, "TIMESTAMP"):
+		return "time.Time"
+	case strings.HasPrefix(sqlType, "TIME"):
+		return "string"
+	}
+	return "string"
+}
+
+func (*postgresOper) SqlType(goType string) string {
+	switch goType {
+	case "bool", "sql.NullBool":
+		return "BOOLEAN"
+	case "int8", "uint8", "int16", "uint16":
+		return "SMALLINT"
+	case "int32", "int", "uint32", "sql.NullInt32":
+		return "INTEGER"
+	case "int64", "uint64", "sql.NullInt64":
+		return "BIGINT"
+	case "float32":
+		return "REAL"
+	case "float64", "sql.NullFloat64":
+		return "DOUBLE PRECISION"
+	case "[]byte":
+		return "BYTEA"
+	case "time.Time", "sql.NullTime":
+		return "TIMESTAMP"
+	}
+
+	return "VARCHAR(256)"
+}
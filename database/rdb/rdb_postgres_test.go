@@ -0,0 +1,64 @@
+package rdb
+
+import (
+	"testing"
+)
+
+func TestPostgresRebind(t *testing.T) {
+	cases := []struct {
+		sql    string
+		expect string
+	}{
+		{"SELECT id FROM user", "SELECT id FROM user"},
+		{"SELECT id FROM user WHERE id=? AND name=?",
+			"SELECT id FROM user WHERE id=$1 AND name=$2"},
+		{"SELECT '?' FROM user WHERE id IN (?,?,?)",
+			"SELECT '?' FROM user WHERE id IN ($1,$2,$3)"},
+		{`SELECT "a?b" FROM user WHERE name=?`,
+			`SELECT "a?b" FROM user WHERE name=$1`},
+		{"SELECT 'it''s ?' FROM user WHERE id=?",
+			"SELECT 'it''s ?' FROM user WHERE id=$1"},
+		{"SELECT data ?? 'key' FROM user WHERE id=?",
+			"SELECT data ? 'key' FROM user WHERE id=$1"},
+		{"SELECT data ??| ? FROM user",
+			"SELECT data ?| $1 FROM user"},
+		{"SELECT id -- why?\nFROM user WHERE id=?",
+			"SELECT id -- why?\nFROM user WHERE id=$1"},
+		{"SELECT id FROM user WHERE id=? -- by id?",
+			"SELECT id FROM user WHERE id=$1 -- by id?"},
+		{"SELECT id /* id=? */ FROM user WHERE id=?",
+			"SELECT id /* id=? */ FROM user WHERE id=$1"},
+		{"SELECT a-b FROM user WHERE id=?/2",
+			"SELECT a-b FROM user WHERE id=$1/2"},
+		{"SELECT 'unclosed ?", "SELECT 'unclosed ?"},
+		{"SELECT id /* unclosed ?", "SELECT id /* unclosed ?"},
+	}
+	o := new(postgresOper)
+	for _, c := range cases {
+		got := o.Rebind(c.sql)
+		if got != c.expect {
+			t.Errorf("Rebind(%q) = %q, expect %q", c.sql, got, c.expect)
+		}
+	}
+}
+
+func TestPostgresDsnValue(t *testing.T) {
+	cases := []struct {
+		v      string
+		expect string
+	}{
+		{"", `''`},
+		{"root", `'root'`},
+		{"pass word", `'pass word'`},
+		{"a=b", `'a=b'`},
+		{`it's`, `'it\'s'`},
+		{`back\slash`, `'back\\slash'`},
+		{`\'`, `'\\\''`},
+	}
+	for _, c := range cases {
+		got := postgresDsnValue(c.v)
+		if got != c.expect {
+			t.Errorf("postgresDsnValue(%q) = %s, expect %s", c.v, got, c.expect)
+		}
+	}
+}
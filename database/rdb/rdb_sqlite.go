@@ -0,0 +1,346 @@
+package rdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/fioncat/go-gendb/database/conn"
+	"github.com/fioncat/go-gendb/misc/errors"
+	"github.com/fioncat/go-gendb/misc/log"
+)
+
+// implement of sqlite session
+
+func sqliteConnect(conn *conn.Config) (*sql.DB, error) {
+	path := conn.Path
+	if path == "" {
+		// For compatibility, the database name can
+		// also be used as the file path.
+		path = conn.Database
+	}
+	if path == "" {
+		return nil, errors.New("missing sqlite file path")
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, errors.Trace("connect db", err)
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("[database] [sqlite] connect: %s", path)
+	return db, nil
+}
+
+type sqliteField struct {
+	Cid     int
+	Name    string
+	Type    string
+	NotNull bool
+	Default sql.NullString
+	Pk      int
+
+	autoIncr bool
+}
+
+func (f *sqliteField) GetName() string    { return f.Name }
+func (f *sqliteField) GetComment() string { return "" }
+func (f *sqliteField) GetType() string    { return f.Type }
+func (f *sqliteField) IsPrimaryKey() bool { return f.Pk > 0 }
+func (f *sqliteField) IsAutoIncr() bool   { return f.autoIncr }
+func (f *sqliteField) IsUnsigned() bool   { return typeUnsigned(f.Type) }
+func (f *sqliteField) GetLength() int     { return typeLength(f.Type) }
+func (f *sqliteField) GetDefault() string { return f.Default.String }
+
+// The primary key can be NULL in sqlite unless it is the
+// rowid, but that is a well-known bug, don't treat it
+// as nullable.
+func (f *sqliteField) IsNullable() bool { return !f.NotNull && f.Pk == 0 }
+
+type sqliteTable struct {
+	Name string
+
+	fields     map[string]*sqliteField
+	fieldNames []string
+
+	indexes []*Index
+}
+
+func (t *sqliteTable) GetName() string      { return t.Name }
+func (t *sqliteTable) GetComment() string   { return "" }
+func (t *sqliteTable) FieldNames() []string { return t.fieldNames }
+func (t *sqliteTable) GetIndexes() []*Index { return t.indexes }
+
+// Field returns nil rather than the typed nil pointer if
+// the field does not exist.
+func (t *sqliteTable) Field(name string) Field {
+	if f, ok := t.fields[name]; ok {
+		return f
+	}
+	return nil
+}
+
+func (t *sqliteTable) setIndexes(db *sql.DB) error {
+	// The columns of "PRAGMA index_list" differ between
+	// sqlite versions, the first three are always "seq",
+	// "name" and "unique".
+	rows, err := db.Query(fmt.Sprintf("PRAGMA index_list(`%s`)", t.Name))
+	if err != nil {
+		return err
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return err
+	}
+	var names []string
+	uniques := make(map[string]bool)
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		for idx := range vals {
+			vals[idx] = new(sql.NullString)
+		}
+		err = rows.Scan(vals...)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		name := vals[1].(*sql.NullString).String
+		if len(cols) > 3 && vals[3].(*sql.NullString).String == "pk" {
+			continue
+		}
+		names = append(names, name)
+		uniques[name] = vals[2].(*sql.NullString).String == "1"
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fields, err := queryIndexInfo(db, name)
+		if err != nil {
+			return err
+		}
+		for _, field := range fields {
+			t.indexes = appendIndex(t.indexes, name,
+				uniques[name], field)
+		}
+	}
+	return nil
+}
+
+func queryIndexInfo(db *sql.DB, name string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA index_info(`%s`)", name))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fields []string
+	for rows.Next() {
+		var seqno, cid int
+		var field string
+		err = rows.Scan(&seqno, &cid, &field)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, rows.Err()
+}
+
+type sqliteCheckResult struct {
+	err   error
+	warns []string
+}
+
+func (r *sqliteCheckResult) GetErr() error      { return r.err }
+func (r *sqliteCheckResult) GetWarns() []string { return r.warns }
+
+type sqliteOper struct{}
+
+const (
+	sqliteTableSQL  = "SELECT sql FROM sqlite_master WHERE type='table' AND name=?"
+	sqliteTablesSQL = "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+)
+
+func (o *sqliteOper) Init(sess *Session) {}
+
+func (o *sqliteOper) Desc(db *sql.DB, tableName string) (Table, error) {
+	// The CREATE TABLE statement is needed to find out
+	// the AUTOINCREMENT column, and also tells us whether
+	// the table exists (PRAGMA returns nothing for a
+	// missing table).
+	var createSql string
+	err := db.QueryRow(sqliteTableSQL, tableName).Scan(&createSql)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf(`table "%s" does not exist`, tableName)
+		}
+		return nil, err
+	}
+	createSql = strings.ToUpper(createSql)
+
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(`%s`)", tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	table := new(sqliteTable)
+	table.Name = tableName
+	table.fields = make(map[string]*sqliteField)
+	table.fieldNames = make([]string, 0)
+
+	var pkCnt int
+	for rows.Next() {
+		field := new(sqliteField)
+		err = rows.Scan(&field.Cid, &field.Name, &field.Type,
+			&field.NotNull, &field.Default, &field.Pk)
+		if err != nil {
+			return nil, err
+		}
+		if field.Pk > 0 {
+			pkCnt++
+		}
+		table.fields[field.Name] = field
+		table.fieldNames = append(table.fieldNames, field.Name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = table.setIndexes(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single "INTEGER PRIMARY KEY" column is an alias of
+	// the rowid, it is assigned automatically by sqlite.
+	if pkCnt == 1 {
+		for _, field := range table.fields {
+			if field.Pk == 0 {
+				continue
+			}
+			if strings.ToUpper(field.Type) == "INTEGER" ||
+				strings.Contains(createSql, "AUTOINCREMENT") {
+				field.autoIncr = true
+			}
+		}
+	}
+
+	return table, nil
+}
+
+func (*sqliteOper) Tables(db *sql.DB) ([]string, error) {
+	return queryNames(db, sqliteTablesSQL)
+}
+
+func (*sqliteOper) Rebind(sql string) string { return sql }
+
+func (*sqliteOper) Check(db *sql.DB, sql string, prepares []interface{}) (CheckResult, error) {
+	sql = "EXPLAIN QUERY PLAN " + sql
+	result := new(sqliteCheckResult)
+	rows, err := db.Query(sql, prepares...)
+	if err != nil {
+		result.err = err
+		return result, nil
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var details []string
+	for rows.Next() {
+		// The number of columns differs between sqlite
+		// versions, the detail is always the last one.
+		vals := make([]interface{}, len(cols))
+		for idx := range vals {
+			vals[idx] = new(string)
+		}
+		err = rows.Scan(vals...)
+		if err != nil {
+			return nil, err
+		}
+		detail := *vals[len(vals)-1].(*string)
+		details = append(details, detail)
+	}
+
+	for _, detail := range details {
+		// "SCAN TABLE user" in old versions and "SCAN user"
+		// in new versions. A scan using an index is a
+		// covering index scan, not a full table scan.
+		if !strings.HasPrefix(detail, "SCAN ") {
+			continue
+		}
+		if strings.Contains(detail, " USING ") {
+			continue
+		}
+		table := strings.TrimPrefix(detail, "SCAN ")
+		table = strings.TrimPrefix(table, "TABLE ")
+		if idx := strings.Index(table, " "); idx > 0 {
+			table = table[:idx]
+		}
+		warn := fmt.Sprintf(`full-table-scan for table "%s"`, table)
+		result.warns = append(result.warns, warn)
+	}
+
+	return result, nil
+}
+
+// ConvertType converts the sqlite type according to the
+// type affinity rules, see https://www.sqlite.org/datatype3.html
+func (*sqliteOper) ConvertType(sqlType string) string {
+	sqlType = strings.ToUpper(sqlType)
+	switch {
+	case strings.Contains(sqlType, "INT"):
+		return "int64"
+	case strings.Contains(sqlType, "CHAR"),
+		strings.Contains(sqlType, "CLOB"),
+		strings.Contains(sqlType, "TEXT"):
+		return "string"
+	case strings.Contains(sqlType, "BLOB"), sqlType == "":
+		return "[]byte"
+	case strings.Contains(sqlType, "REAL"),
+		strings.Contains(sqlType, "FLOA"),
+		strings.Contains(sqlType, "DOUB"):
+		return "float64"
+	case strings.HasPrefix(sqlType, "BOOL"):
+		return "bool"
+	case strings.HasPrefix(sqlType, "DATE"),
+		strings.HasPrefix(sqlType, "TIMESTAMP"):
+		// go-sqlite3 parses these declared types as time.
+		return "time.Time"
+	case strings.HasPrefix(sqlType, "TIME"):
+		return "string"
+	}
+	// NUMERIC affinity
+	return "float64"
+}
+
+func (*sqliteOper) SqlType(goType string) string {
+	switch goType {
+	case "bool", "sql.NullBool":
+		return "BOOLEAN"
+	case "int8", "uint8", "int16", "uint16",
+		"int32", "int", "uint32", "sql.NullInt32",
+		"int64", "uint64", "sql.NullInt64":
+		return "INTEGER"
+	case "float32", "float64", "sql.NullFloat64":
+		return "REAL"
+	case "[]byte":
+		return "BLOB"
+	case "time.Time", "sql.NullTime":
+		return "DATETIME"
+	}
+
+	return "TEXT"
+}
@@ -3,6 +3,7 @@ package rdb
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,10 +12,7 @@ import (
 	"github.com/fioncat/go-gendb/misc/log"
 )
 
-var (
-	tableInfo     map[string]Table
-	tableInfoOnce sync.Once
-)
+var tableInfoOnce sync.Once
 
 // Session stores the connection to the remote database
 // (RDB only) and some related operations of the database.
@@ -27,6 +25,9 @@ var (
 // specific database type. But need to give the type during
 // initialization.
 type Session struct {
+	// database type, such as "mysql", "postgres".
+	dbType string
+
 	// database connection configuration.
 	cfg *conn.Config
 
@@ -38,6 +39,14 @@ type Session struct {
 
 	// function to connect database
 	connect ConnectFunc
+
+	// tables parsed from the DDL files, if it is not
+	// nil, the session describes tables offline.
+	schema map[string]Table
+
+	// tables described by the session, each connection
+	// has its own tables.
+	tableInfo map[string]Table
 }
 
 const (
@@ -47,6 +56,12 @@ const (
 	descCacheNotHit
 )
 
+// DbType returns the database type of the session,
+// such as "mysql".
+func (s *Session) DbType() string {
+	return s.dbType
+}
+
 // Desc is used to describe a data table. It will return
 // some basic information of the data table, including
 // table name, comments, table field information, etc.
@@ -62,15 +77,25 @@ const (
 // same table, you can use the cache. The cache expiration
 // time defaults to the TABLE_CACHE_TTL variable.
 func (s *Session) Desc(tableName string) (table Table, err error) {
+	if s.schema != nil {
+		table = s.schema[tableName]
+		if table == nil {
+			err = fmt.Errorf(`table "%s" does not exist `+
+				`in the schema`, tableName)
+		}
+		return
+	}
 	tableInfoOnce.Do(func() {
 		log.Infof("[database] [desc] cacheEnable=%v, cacheTTL=%v",
 			EnableTableCache, TableCacheTTL)
-		tableInfo = make(map[string]Table)
 	})
+	if s.tableInfo == nil {
+		s.tableInfo = make(map[string]Table)
+	}
 	start := time.Now()
 	var cacheStatus int
 
-	table = tableInfo[tableName]
+	table = s.tableInfo[tableName]
 	if table != nil {
 		cacheStatus = descCacheMem
 		return
@@ -92,7 +117,7 @@ func (s *Session) Desc(tableName string) (table Table, err error) {
 				log.Infof("[database] [desc] [%v] %s, cache=%s",
 					time.Since(start), tableName, cacheStatusStr)
 			}
-			tableInfo[tableName] = table
+			s.tableInfo[tableName] = table
 		}
 	}()
 	if !EnableTableCache {
@@ -129,6 +154,10 @@ func (s *Session) Desc(tableName string) (table Table, err error) {
 // of the CheckResult interface. See the interface documentation
 // for details.
 func (s *Session) Check(sql string, prepares []interface{}) (CheckResult, error) {
+	if s.db == nil {
+		return nil, errors.New("can not check sql " +
+			"without database connection")
+	}
 	return s.oper.Check(s.db, sql, prepares)
 }
 
@@ -137,25 +166,56 @@ func (s *Session) Check(sql string, prepares []interface{}) (CheckResult, error)
 // Go type.
 // The conversion logic of different types of databases is
 // different.
+//
+// The custom types in the config file take precedence,
+// see SetTypes.
 func (s *Session) GoType(sqlType string) string {
+	if t := customGoType(sqlType); t != nil {
+		return t.Go
+	}
 	return s.oper.ConvertType(sqlType)
 }
 
+// FieldType converts the field to Go type. Unlike GoType,
+// it takes the nullability into account, "null" is the
+// strategy for nullable fields, see NullType.
+func (s *Session) FieldType(field Field, null string) string {
+	if t := customGoType(field.GetType()); t != nil {
+		if !field.IsNullable() {
+			return t.Go
+		}
+		if t.Null != "" {
+			return t.Null
+		}
+		return NullType(t.Go, NullPointer)
+	}
+	goType := s.oper.ConvertType(field.GetType())
+	if !field.IsNullable() {
+		return goType
+	}
+	return NullType(goType, null)
+}
+
+// SqlType converts the Go type to the database type, it
+// is the reverse of GoType.
 func (s *Session) SqlType(goType string) string {
-	return s.oper.SqlType(goType)
+	if sqlType := customSqlType(goType); sqlType != "" {
+		return sqlType
+	}
+	return s.oper.SqlType(BaseType(goType))
 }
 
 // Query directly uses the session's database connection
 // to execute a SQL query statement.
 func (s *Session) Query(sql string, vs ...interface{}) (*sql.Rows, error) {
-	return s.db.Query(sql, vs...)
+	return s.db.Query(s.oper.Rebind(sql), vs...)
 }
 
 // Exec directly uses the session's database connection
 // to execute a SQL execution statement, and returns the
 // number of rows affected by the statement execution.
 func (s *Session) Exec(sql string, vs ...interface{}) (int64, error) {
-	res, err := s.db.Exec(sql, vs...)
+	res, err := s.db.Exec(s.oper.Rebind(sql), vs...)
 	if err != nil {
 		return 0, err
 	}
@@ -205,9 +265,17 @@ type dbOper interface {
 	// not involve caching and directly manipulates the database.
 	Desc(db *sql.DB, tableName string) (Table, error)
 
+	// Tables returns the names of all the tables in the
+	// connected database.
+	Tables(db *sql.DB) ([]string, error)
+
 	// Check is the specific implementation of checking sql statement
 	Check(db *sql.DB, sql string, prepares []interface{}) (CheckResult, error)
 
+	// Rebind converts the "?" bind variables generated by
+	// go-gendb to the ones of the database.
+	Rebind(sql string) string
+
 	// ConvertType is a specific implementation of converting
 	// database type to Go type.
 	ConvertType(sqlType string) string
@@ -234,6 +302,24 @@ type Table interface {
 	// FieldNames returns all field names. Generally
 	// used to traverse all fields of the table.
 	FieldNames() []string
+
+	// GetIndexes returns the indexes of the data table,
+	// the primary key is not included.
+	GetIndexes() []*Index
+}
+
+// Index represents an index of the data table.
+type Index struct {
+	Name   string   `json:"name" yaml:"name"`
+	Unique bool     `json:"unique,omitempty" yaml:"unique,omitempty"`
+	Fields []string `json:"fields" yaml:"fields,flow"`
+}
+
+func (idx *Index) String() string {
+	if idx.Unique {
+		return fmt.Sprintf("unique%v", idx.Fields)
+	}
+	return fmt.Sprint(idx.Fields)
 }
 
 // Field represents the data table field, which is
@@ -251,6 +337,24 @@ type Field interface {
 	IsPrimaryKey() bool
 
 	IsAutoIncr() bool
+
+	// IsNullable returns whether the field can be NULL.
+	IsNullable() bool
+
+	// IsUnsigned returns whether the field is an unsigned
+	// number.
+	IsUnsigned() bool
+
+	// GetLength returns the length in the field type, such
+	// as 64 for "VARCHAR(64)". If there is no length,
+	// returns 0.
+	GetLength() int
+
+	// GetDefault returns the default value of the field
+	// as sql expression, such as "'abc'", "0" and
+	// "CURRENT_TIMESTAMP". If there is no default value,
+	// returns empty.
+	GetDefault() string
 }
 
 // CheckResult represents the result of checking the sql
@@ -272,8 +376,9 @@ type CheckResult interface {
 type ConnectFunc func(cfg *conn.Config) (*sql.DB, error)
 
 // Save the mapping of all currently supported database
-// types and their Session (not yet connected).
-var initSessM = make(map[string]*Session)
+// types and the functions to create their Session (not yet
+// connected). Each connection has its own Session.
+var initSessM = make(map[string]func() *Session)
 
 // Create a session that is not connected to the database,
 // the session here cannot call the database operation method,
@@ -287,15 +392,159 @@ func newSess(oper dbOper, connFunc ConnectFunc) *Session {
 
 func init() {
 	// init all support database
-	initSessM["mysql"] = newSess(&mysqlOper{}, mysqlConnect)
+	initSessM["mysql"] = func() *Session {
+		return newSess(&mysqlOper{}, mysqlConnect)
+	}
+
+	pgSess := func() *Session {
+		return newSess(&postgresOper{}, postgresConnect)
+	}
+	initSessM["postgres"] = pgSess
+	initSessM["pg"] = pgSess
+
+	sqliteSess := func() *Session {
+		return newSess(&sqliteOper{}, sqliteConnect)
+	}
+	initSessM["sqlite"] = sqliteSess
+	initSessM["sqlite3"] = sqliteSess
 }
 
 var (
 	// global session
 	sess *Session
 	mu   sync.Mutex
+
+	// the sessions opened, the key is the connection key.
+	sessions = make(map[string]*Session)
+
+	// the global session is pinned by PinSchema
+	pinned bool
 )
 
+// ParseConn parses the "conn" option, whose format is
+// "[type,]key", the default type is "mysql".
+func ParseConn(opt string) (key, dbType string, err error) {
+	tmp := strings.Split(opt, ",")
+	switch len(tmp) {
+	case 1:
+		return opt, "mysql", nil
+
+	case 2:
+		return tmp[1], tmp[0], nil
+	}
+	return "", "", fmt.Errorf(`conn `+
+		`config "%s" is bad format`, opt)
+}
+
+// Open returns the session of the connection "key". The
+// session is connected and registered at the first call,
+// the following calls with the same key return it directly.
+// Unlike Init, the global session is not changed, so that
+// different parts of a file can use different databases.
+//
+// If the global session is pinned (see PinSchema), it is
+// returned for all the connections.
+func Open(key, dbType string) (*Session, error) {
+	if pinned {
+		log.Infof("[database] [schema] pinned, ignore connection %s", key)
+		return sess, nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if alias, ok := dbTypeAlias[dbType]; ok {
+		dbType = alias
+	}
+	if s := sessions[key]; s != nil {
+		if s.dbType != dbType {
+			return nil, fmt.Errorf(`connection "%s" is `+
+				`opened as "%s", not "%s"`, key, s.dbType, dbType)
+		}
+		return s, nil
+	}
+
+	newSess := initSessM[dbType]
+	if newSess == nil {
+		return nil, fmt.Errorf(
+			"unsupport database type: \"%s\"", dbType)
+	}
+	s := newSess()
+
+	cfg, err := conn.Get(key)
+	if err != nil {
+		return nil, errors.Trace("read connection", err)
+	}
+
+	s.dbType = dbType
+	s.cfg = cfg
+	s.db, err = s.connect(cfg)
+	if err != nil {
+		return nil, errors.Trace("init database", err)
+	}
+	s.oper.Init(s)
+	sessions[key] = s
+	log.Infof("[database] open connection %s, type=%s", key, dbType)
+
+	return s, nil
+}
+
+// Use returns the session of the "conn" option (see
+// ParseConn). If opt is empty, returns the global session.
+func Use(opt string) (*Session, error) {
+	if opt == "" {
+		err := MustInit()
+		if err != nil {
+			return nil, err
+		}
+		return sess, nil
+	}
+	key, dbType, err := ParseConn(opt)
+	if err != nil {
+		return nil, err
+	}
+	return Open(key, dbType)
+}
+
+// ConnType returns the database type of the "conn" option
+// (see ParseConn) without connecting. If opt is empty or the
+// global session is pinned, returns the type of the global
+// session, "mysql" if there is none.
+func ConnType(opt string) string {
+	if opt == "" || pinned {
+		if sess != nil && sess.dbType != "" {
+			return sess.dbType
+		}
+		return "mysql"
+	}
+	_, dbType, err := ParseConn(opt)
+	if err != nil {
+		return "mysql"
+	}
+	if alias, ok := dbTypeAlias[dbType]; ok {
+		dbType = alias
+	}
+	return dbType
+}
+
+// the aliases of the database types.
+var dbTypeAlias = map[string]string{
+	"pg":      "postgres",
+	"sqlite3": "sqlite",
+}
+
+// CheckType returns the database type, the alias (such as
+// "pg") is converted. If the type is not supported, returns
+// an error.
+func CheckType(dbType string) (string, error) {
+	if alias, ok := dbTypeAlias[dbType]; ok {
+		dbType = alias
+	}
+	if initSessM[dbType] == nil {
+		return "", fmt.Errorf(
+			"unsupport database type: \"%s\"", dbType)
+	}
+	return dbType, nil
+}
+
 // Init will take out the connection configuration according
 // to "key", select the database type according to "dbType"
 // (if the connection configuration does not exist or the
@@ -305,31 +554,95 @@ var (
 //
 // If the database connection is wrong, the function will also
 // return an error. If Init is called repeatedly, the global
-// session will be overwritten.
+// session will be overwritten. The connection is opened by
+// Open, so initializing the same key again reuses it.
 //
 // Through Get(), you can get the global session and call its
 // methods. If Init is not called when Get is called, the program
 // will exit abnormally. You can use MustInit() to check whether
 // Init() is called and successful.
 func Init(key, dbType string) error {
-	sess = initSessM[dbType]
-	if sess == nil {
-		return fmt.Errorf(
-			"unsupport database type: \"%s\"", dbType)
+	if pinned {
+		log.Infof("[database] [schema] pinned, ignore connection %s", key)
+		return nil
 	}
-
-	cfg, err := conn.Get(key)
+	s, err := Open(key, dbType)
 	if err != nil {
-		return errors.Trace("read connection", err)
+		return err
+	}
+	sess = s
+	return nil
+}
+
+// InitSchema is similar to Init, but the global session
+// does not connect to the database. Tables are described
+// offline from path, which can be:
+//
+//   - A schema snapshot file (".json", ".yaml" or ".yml")
+//     written by "go-gendb schema pull".
+//   - A ".sql" file or a directory of ".sql" files, the
+//     "CREATE TABLE" statements in them will be parsed.
+//
+// "dbType" is used to select the dialect of the DDL and
+// the type conversion. If it is empty, the type recorded
+// in the snapshot is used ("mysql" for DDL).
+//
+// The session initialized by InitSchema can only be used
+// to describe tables and convert types, operations that
+// need database connection will fail.
+func InitSchema(path, dbType string) error {
+	if pinned {
+		log.Infof("[database] [schema] pinned, ignore %s", path)
+		return nil
+	}
+	var tables map[string]Table
+	if IsSnapshot(path) {
+		snapshot, err := ReadSnapshot(path)
+		if err != nil {
+			return err
+		}
+		if dbType == "" {
+			dbType = snapshot.DbType
+		}
+		tables = snapshot.tableMap()
+	}
+	if dbType == "" {
+		dbType = "mysql"
 	}
 
-	sess.cfg = cfg
-	sess.db, err = sess.connect(cfg)
+	dbType, err := CheckType(dbType)
 	if err != nil {
-		return errors.Trace("init database", err)
+		return err
+	}
+	newSess := initSessM[dbType]
+
+	if tables == nil {
+		tables, err = LoadDDL(path, dbType)
+		if err != nil {
+			return err
+		}
 	}
-	sess.oper.Init(sess)
 
+	sess = newSess()
+	sess.dbType = dbType
+	sess.schema = tables
+	log.Infof("[database] [schema] load %d tables from %s",
+		len(tables), path)
+
+	return nil
+}
+
+// PinSchema calls InitSchema and pins the global session,
+// the following calls of Init and InitSchema will be
+// ignored. It is used to force the generation to read
+// tables from the snapshot, no matter what connection
+// the source file specifies.
+func PinSchema(path, dbType string) error {
+	err := InitSchema(path, dbType)
+	if err != nil {
+		return err
+	}
+	pinned = true
 	return nil
 }
 
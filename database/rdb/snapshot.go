@@ -0,0 +1,277 @@
+package rdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/fioncat/go-gendb/misc/errors"
+)
+
+// Snapshot is the exported structure of the data tables
+// in a database. Unlike the table cache, it has no TTL and
+// is designed to be committed into the repository, so that
+// code can be generated from it without database connection,
+// and the drift between it and the database can be reported.
+// The tables are sorted by name, and it can be encoded as
+// json or yaml, both of them are easy to diff.
+type Snapshot struct {
+	DbType   string `json:"db_type" yaml:"db_type"`
+	Database string `json:"database,omitempty" yaml:"database,omitempty"`
+
+	Tables []*CacheTable `json:"tables" yaml:"tables"`
+}
+
+// IsSnapshot reports whether the path is a snapshot file,
+// according to its extension.
+func IsSnapshot(path string) bool {
+	switch filepath.Ext(path) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+func isYaml(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// ReadSnapshot reads the snapshot from the file. The file
+// is decoded as yaml if its extension is ".yaml" or ".yml",
+// otherwise json.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := new(Snapshot)
+	if isYaml(path) {
+		err = yaml.Unmarshal(data, s)
+	} else {
+		err = json.Unmarshal(data, s)
+	}
+	if err != nil {
+		return nil, errors.Trace(path, err)
+	}
+	return s, nil
+}
+
+// WriteSnapshot writes the snapshot to the file, the
+// encoding is the same as ReadSnapshot.
+func WriteSnapshot(path string, s *Snapshot) error {
+	var data []byte
+	var err error
+	if isYaml(path) {
+		data, err = yaml.Marshal(s)
+	} else {
+		data, err = json.MarshalIndent(s, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// queryNames executes the query and returns the first
+// column of all rows.
+func queryNames(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (s *Snapshot) tableMap() map[string]Table {
+	m := make(map[string]Table, len(s.Tables))
+	for _, t := range s.Tables {
+		m[t.Name] = t
+	}
+	return m
+}
+
+// Tables returns the names of all the tables in the database.
+func (s *Session) Tables() ([]string, error) {
+	if s.db == nil {
+		names := make([]string, 0, len(s.schema))
+		for name := range s.schema {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+	return s.oper.Tables(s.db)
+}
+
+// Snapshot describes the tables directly from the database
+// (no cache) and returns them as a Snapshot. If tables is
+// empty, all tables in the database will be described.
+func (s *Session) Snapshot(tables []string) (*Snapshot, error) {
+	if s.db == nil {
+		return nil, errors.New("can not pull schema " +
+			"without database connection")
+	}
+	var err error
+	if len(tables) == 0 {
+		tables, err = s.Tables()
+		if err != nil {
+			return nil, errors.Trace("list tables", err)
+		}
+	}
+
+	snapshot := new(Snapshot)
+	snapshot.DbType = s.dbType
+	if s.cfg != nil {
+		snapshot.Database = s.cfg.Database
+	}
+	snapshot.Tables = make([]*CacheTable, 0, len(tables))
+	for _, name := range tables {
+		table, err := s.oper.Desc(s.db, name)
+		if err != nil {
+			return nil, errors.Trace(name, err)
+		}
+		cacheTable := new(CacheTable)
+		cacheTable.fromInter(table)
+		snapshot.Tables = append(snapshot.Tables, cacheTable)
+	}
+	sort.Slice(snapshot.Tables, func(i, j int) bool {
+		return snapshot.Tables[i].Name < snapshot.Tables[j].Name
+	})
+	return snapshot, nil
+}
+
+// DiffSnapshot compares the old snapshot with the current
+// one, and returns the drift between them, one line for each
+// difference. "+" means it only exists in the current one,
+// "-" means it only exists in the old one, "~" means it is
+// modified. If they are the same, returns nil.
+// Only the tables in the old snapshot are compared, unless
+// all is true.
+func DiffSnapshot(old, cur *Snapshot, all bool) []string {
+	var diffs []string
+	oldTables := old.tableMap()
+	curTables := cur.tableMap()
+
+	for _, ot := range old.Tables {
+		nt := curTables[ot.Name]
+		if nt == nil {
+			diffs = append(diffs, fmt.Sprintf(`- table "%s"`, ot.Name))
+			continue
+		}
+		diffs = append(diffs, diffTable(ot, nt.(*CacheTable))...)
+	}
+	if all {
+		for _, nt := range cur.Tables {
+			if oldTables[nt.Name] == nil {
+				diffs = append(diffs, fmt.Sprintf(`+ table "%s"`, nt.Name))
+			}
+		}
+	}
+	return diffs
+}
+
+func diffTable(ot, nt *CacheTable) []string {
+	var diffs []string
+	add := func(sign, format string, a ...interface{}) {
+		diff := fmt.Sprintf(format, a...)
+		diff = fmt.Sprintf(`%s table "%s": %s`, sign, ot.Name, diff)
+		diffs = append(diffs, diff)
+	}
+	if ot.Comment != nt.Comment {
+		add("~", `comment "%s" -> "%s"`, ot.Comment, nt.Comment)
+	}
+	for _, name := range ot.FieldNames() {
+		of := ot.Fields[name]
+		nf := nt.Fields[name]
+		if nf == nil {
+			add("-", `field "%s" %s`, name, of.Type)
+			continue
+		}
+		if !strings.EqualFold(of.Type, nf.Type) {
+			add("~", `field "%s" type %s -> %s`, name, of.Type, nf.Type)
+		}
+		if of.IsPrimary != nf.IsPrimary {
+			add("~", `field "%s" primary key %v -> %v`, name,
+				of.IsPrimary, nf.IsPrimary)
+		}
+		if of.AutoIncr != nf.AutoIncr {
+			add("~", `field "%s" auto increment %v -> %v`, name,
+				of.AutoIncr, nf.AutoIncr)
+		}
+		if of.Nullable != nf.Nullable {
+			add("~", `field "%s" nullable %v -> %v`, name,
+				of.Nullable, nf.Nullable)
+		}
+		if of.Default != nf.Default {
+			add("~", `field "%s" default "%s" -> "%s"`, name,
+				of.Default, nf.Default)
+		}
+		if of.Comment != nf.Comment {
+			add("~", `field "%s" comment "%s" -> "%s"`, name,
+				of.Comment, nf.Comment)
+		}
+	}
+	for _, name := range nt.FieldNames() {
+		if ot.Fields[name] == nil {
+			add("+", `field "%s" %s`, name, nt.Fields[name].Type)
+		}
+	}
+
+	oldIdxes := indexMap(ot.Indexes)
+	curIdxes := indexMap(nt.Indexes)
+	for _, oi := range ot.Indexes {
+		ni := curIdxes[oi.Name]
+		if ni == nil {
+			add("-", `index "%s" %v`, oi.Name, oi.Fields)
+			continue
+		}
+		if oi.Unique != ni.Unique || !sameFields(oi.Fields, ni.Fields) {
+			add("~", `index "%s" %s -> %s`, oi.Name, oi, ni)
+		}
+	}
+	for _, ni := range nt.Indexes {
+		if oldIdxes[ni.Name] == nil {
+			add("+", `index "%s" %v`, ni.Name, ni.Fields)
+		}
+	}
+	return diffs
+}
+
+func indexMap(idxes []*Index) map[string]*Index {
+	m := make(map[string]*Index, len(idxes))
+	for _, idx := range idxes {
+		m[idx.Name] = idx
+	}
+	return m
+}
+
+func sameFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package rdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fioncat/go-gendb/config"
+)
+
+// The strategies to represent the nullable fields in Go.
+const (
+	// NullPointer uses the pointer of the type, such as
+	// "*string", NULL is scanned as nil.
+	NullPointer = "pointer"
+
+	// NullSql uses the "sql.NullX" types, such as
+	// "sql.NullString".
+	NullSql = "sqlnull"
+)
+
+// CheckNull checks whether the null strategy is supported.
+// The empty strategy means the default one (NullPointer).
+func CheckNull(null string) error {
+	switch null {
+	case "", NullPointer, NullSql:
+		return nil
+	}
+	return fmt.Errorf(`unknown null strategy "%s", `+
+		`expect "%s" or "%s"`, null, NullPointer, NullSql)
+}
+
+var sqlNullTypes = map[string]string{
+	"string":    "sql.NullString",
+	"int64":     "sql.NullInt64",
+	"int32":     "sql.NullInt32",
+	"float64":   "sql.NullFloat64",
+	"bool":      "sql.NullBool",
+	"time.Time": "sql.NullTime",
+}
+
+// NullType converts the Go type of a nullable field
+// according to the null strategy. The types which can
+// hold NULL already (such as "[]byte") are not converted.
+// For NullSql, the types without "sql.NullX" (such as
+// "uint64") fall back to pointer.
+func NullType(goType, null string) string {
+	if goType == "[]byte" || strings.HasPrefix(goType, "*") {
+		return goType
+	}
+	if null == NullSql {
+		if nullType, ok := sqlNullTypes[goType]; ok {
+			return nullType
+		}
+	}
+	return "*" + goType
+}
+
+// BaseType is the reverse of NullType, it returns the
+// type that the nullable type holds.
+func BaseType(goType string) string {
+	goType = strings.TrimPrefix(goType, "*")
+	for baseType, nullType := range sqlNullTypes {
+		if nullType == goType {
+			return baseType
+		}
+	}
+	return goType
+}
+
+var typeImports = map[string]string{
+	"time": "time",
+	"sql":  "database/sql",
+}
+
+// TypeImport returns the package name and import path of
+// the Go type generated by rdb (including the custom types).
+// If the type does not need import, returns empty.
+func TypeImport(goType string) (string, string) {
+	goType = strings.TrimLeft(goType, "*[]")
+	idx := strings.Index(goType, ".")
+	if idx < 0 {
+		return "", ""
+	}
+	name := goType[:idx]
+	for _, t := range customTypes {
+		if t.Import == "" {
+			continue
+		}
+		if strings.HasPrefix(t.Go, name+".") {
+			return name, t.Import
+		}
+	}
+	return name, typeImports[name]
+}
+
+// custom type mappings from the config file.
+var customTypes []*config.Type
+
+// SetTypes sets the custom type mappings, they take
+// precedence over the database's builtin mappings. nil
+// means no custom types.
+func SetTypes(types []*config.Type) {
+	customTypes = types
+}
+
+// normalize the db type for comparing.
+func normType(sqlType string) string {
+	sqlType = strings.ToUpper(strings.TrimSpace(sqlType))
+	return strings.Join(strings.Fields(sqlType), " ")
+}
+
+// find the custom type for the db type.
+func customGoType(sqlType string) *config.Type {
+	sqlType = normType(sqlType)
+	for _, t := range customTypes {
+		if t.Db == "" {
+			continue
+		}
+		db := normType(t.Db)
+		if sqlType == db {
+			return t
+		}
+		if !strings.HasPrefix(sqlType, db) {
+			continue
+		}
+		// "DECIMAL" matches "DECIMAL(10,2)" and
+		// "DECIMAL UNSIGNED", but not "DECIMALX".
+		switch sqlType[len(db)] {
+		case '(', ' ':
+			return t
+		}
+	}
+	return nil
+}
+
+// find the custom db type for the Go type.
+func customSqlType(goType string) string {
+	goType = strings.TrimPrefix(goType, "*")
+	for _, t := range customTypes {
+		if t.Go == goType || t.Null == goType {
+			return t.Sql
+		}
+	}
+	return ""
+}
+
+// sqlLiteral converts the raw default value to sql
+// expression. Numbers and function calls (such as
+// "CURRENT_TIMESTAMP", "now()") are kept, others are
+// quoted as string.
+func sqlLiteral(val string) string {
+	if _, err := strconv.ParseFloat(val, 64); err == nil {
+		return val
+	}
+	upper := strings.ToUpper(val)
+	if upper == "NULL" || strings.HasPrefix(upper, "CURRENT_") ||
+		strings.Contains(val, "(") {
+		return val
+	}
+	return "'" + strings.ReplaceAll(val, "'", "''") + "'"
+}
+
+// appendIndex adds the field to the index with the name,
+// the index will be created if it does not exist. Fields
+// should be added in the order of the index.
+func appendIndex(idxes []*Index, name string, unique bool, field string) []*Index {
+	for _, idx := range idxes {
+		if idx.Name == name {
+			idx.Fields = append(idx.Fields, field)
+			return idxes
+		}
+	}
+	return append(idxes, &Index{
+		Name:   name,
+		Unique: unique,
+		Fields: []string{field},
+	})
+}
+
+// typeUnsigned checks the "UNSIGNED" attribute of the
+// db type, such as "int(10) unsigned".
+func typeUnsigned(sqlType string) bool {
+	return strings.Contains(strings.ToUpper(sqlType), "UNSIGNED")
+}
+
+// typeLength extracts the length of the db type, such
+// as 64 for "varchar(64)", 10 for "decimal(10,2)".
+func typeLength(sqlType string) int {
+	start := strings.Index(sqlType, "(")
+	if start < 0 {
+		return 0
+	}
+	end := strings.IndexAny(sqlType[start:], ",)")
+	if end < 0 {
+		return 0
+	}
+	s := strings.TrimSpace(sqlType[start+1 : start+end])
+	length, _ := strconv.Atoi(s)
+	return length
+}
@@ -0,0 +1,142 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/fioncat/go-gendb/database/rdb"
+	"github.com/fioncat/go-gendb/misc/errors"
+	"github.com/fioncat/go-gendb/version"
+)
+
+// the rule name of the plan regression.
+const regressionRule = "plan-regression"
+
+// Baseline records the plans of the methods, it is saved
+// into a file and committed, to detect the regressions of
+// the plans caused by the schema changes.
+type Baseline struct {
+	Version string `json:"version"`
+
+	// Plans are the plans of the methods, the key is
+	// "{path}:{method}", with the branch set for the
+	// dynamic method.
+	Plans map[string][]*BaselineTable `json:"plans"`
+}
+
+// BaselineTable is the access of a table in the plan.
+type BaselineTable struct {
+	Table  string `json:"table"`
+	Access string `json:"access"`
+	Key    string `json:"key,omitempty"`
+	Rows   int64  `json:"rows"`
+}
+
+func (item *checkItem) baselineKey() string {
+	return filepath.ToSlash(item.Path) + ":" + item.title()
+}
+
+func saveBaseline(path string, items []*checkItem) error {
+	b := &Baseline{
+		Version: version.Short,
+		Plans:   make(map[string][]*BaselineTable, len(items)),
+	}
+	for _, item := range items {
+		if item.plan == nil {
+			continue
+		}
+		ts := make([]*BaselineTable, len(item.plan.Tables))
+		for idx, t := range item.plan.Tables {
+			ts[idx] = &BaselineTable{
+				Table:  t.Name,
+				Access: t.AccessType,
+				Key:    t.Key,
+				Rows:   t.Rows,
+			}
+		}
+		b.Plans[item.baselineKey()] = ts
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func loadBaseline(path string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := new(Baseline)
+	err = json.Unmarshal(data, b)
+	if err != nil {
+		return nil, errors.Trace(path, err)
+	}
+	return b, nil
+}
+
+// compare appends the regressions of the plans to the
+// warnings of the items. The methods which are not in the
+// baseline are ignored. A method regresses when a table
+// loses its index (or turns to full table scan), or the
+// estimated rows grow beyond ratio.
+func (b *Baseline) compare(items []*checkItem, ratio float64) {
+	for _, item := range items {
+		if item.plan == nil {
+			continue
+		}
+		olds, ok := b.Plans[item.baselineKey()]
+		if !ok {
+			continue
+		}
+		// The same table might be accessed more than once,
+		// match them in order.
+		oldMap := make(map[string][]*BaselineTable, len(olds))
+		for _, old := range olds {
+			oldMap[old.Table] = append(oldMap[old.Table], old)
+		}
+		for _, t := range item.plan.Tables {
+			ts := oldMap[t.Name]
+			if len(ts) == 0 {
+				continue
+			}
+			old := ts[0]
+			oldMap[t.Name] = ts[1:]
+			for _, msg := range compareTable(old, t, ratio) {
+				item.warns = append(item.warns, &Warn{
+					Rule:    regressionRule,
+					Message: msg,
+				})
+			}
+		}
+	}
+}
+
+func compareTable(old *BaselineTable, t *rdb.PlanTable, ratio float64) []string {
+	var msgs []string
+	switch {
+	case old.Key != "" && t.Key == "":
+		msgs = append(msgs, fmt.Sprintf(`table "%s" lost `+
+			`index "%s", access %s -> %s`, t.Name, old.Key,
+			old.Access, t.AccessType))
+
+	case old.Access != "ALL" && t.AccessType == "ALL":
+		msgs = append(msgs, fmt.Sprintf(`table "%s" turns `+
+			`to full table scan, access %s -> ALL`, t.Name,
+			old.Access))
+	}
+	base := old.Rows
+	if base < 1 {
+		base = 1
+	}
+	if float64(t.Rows) > float64(base)*ratio {
+		msgs = append(msgs, fmt.Sprintf(`table "%s" estimated `+
+			`rows grow from %d to %d, exceeds %gx`, t.Name,
+			old.Rows, t.Rows, ratio))
+	}
+	return msgs
+}
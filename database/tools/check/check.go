@@ -22,6 +22,17 @@ type Arg struct {
 
 	DbType string `flag:"db-type" default:"mysql"`
 
+	MaxRows     int    `flag:"max-rows" default:"10000"`
+	MaxVariants int    `flag:"max-variants" default:"64"`
+	Skip        string `flag:"skip"`
+
+	Format string `flag:"format" default:"text"`
+	Output string `flag:"o"`
+
+	SaveBaseline string `flag:"save-baseline"`
+	Baseline     string `flag:"baseline"`
+	RowsRatio    string `flag:"rows-ratio" default:"2"`
+
 	Conn string `arg:"conn"`
 	Path string `arg:"path"`
 }
@@ -29,10 +40,17 @@ type Arg struct {
 type checkItem struct {
 	Path string
 	Name string
+	Line int
+
+	// Branch is the branch set of the dynamic method.
+	Branch string
 
 	exec *common.Exec
+	opts *Options
 
-	result rdb.CheckResult
+	plan  *rdb.Plan
+	err   error
+	warns []*Warn
 }
 
 func Do(arg *Arg) error {
@@ -44,6 +62,32 @@ func Do(arg *Arg) error {
 		return err
 	}
 
+	report := reports[arg.Format]
+	if report == nil && arg.Format != "text" {
+		return fmt.Errorf(`unknown format "%s"`, arg.Format)
+	}
+
+	sess := rdb.Get()
+	var baseline *Baseline
+	var ratio float64
+	if arg.Baseline != "" || arg.SaveBaseline != "" {
+		if !sess.CanExplain() {
+			return fmt.Errorf(`baseline requires explain, `+
+				`database "%s" does not support it`, sess.DbType())
+		}
+	}
+	if arg.Baseline != "" {
+		baseline, err = loadBaseline(arg.Baseline)
+		if err != nil {
+			return err
+		}
+		ratio, err = strconv.ParseFloat(arg.RowsRatio, 64)
+		if err != nil || ratio < 1 {
+			return fmt.Errorf(`rows-ratio "%s" is `+
+				`invalid, it should be >= 1`, arg.RowsRatio)
+		}
+	}
+
 	var paths []string
 	if !arg.Dir {
 		paths = []string{arg.Path}
@@ -54,9 +98,18 @@ func Do(arg *Arg) error {
 		}
 	}
 
+	opts := &Options{
+		MaxRows:     int64(arg.MaxRows),
+		MaxVariants: arg.MaxVariants,
+	}
+	opts.Skip, err = parseSkip(arg.Skip)
+	if err != nil {
+		return err
+	}
+
 	var items []*checkItem
 	for _, path := range paths {
-		subItems, err := getToCheck(path)
+		subItems, err := getToCheck(path, opts)
 		if err != nil {
 			return err
 		}
@@ -67,20 +120,86 @@ func Do(arg *Arg) error {
 	}
 
 	for _, item := range items {
-		exec := item.exec
-		res, err := rdb.Get().Check(exec.Sql, exec.Vals)
+		err = item.check(sess)
 		if err != nil {
 			return fmt.Errorf(`Check method "%s" `+
-				`failed: %v`, item.Name, err)
+				`failed: %v`, item.title(), err)
+		}
+	}
+	if baseline != nil {
+		baseline.compare(items, ratio)
+	}
+	if arg.SaveBaseline != "" {
+		err = saveBaseline(arg.SaveBaseline, items)
+		if err != nil {
+			return errors.Trace("save baseline", err)
 		}
-		item.result = res
 	}
 
 	// Show Result
-	showResult(paths, items)
+	if report == nil {
+		showResult(paths, items)
+	} else {
+		data, err := report(items)
+		if err != nil {
+			return err
+		}
+		if arg.Output == "" {
+			fmt.Println(string(data))
+		} else {
+			data = append(data, '\n')
+			err = ioutil.WriteFile(arg.Output, data, 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	errCnt, warnCnt := countResult(items)
+	if errCnt > 0 || warnCnt > 0 {
+		return fmt.Errorf("check failed, %d error(s), "+
+			"%d warning(s)", errCnt, warnCnt)
+	}
 	return nil
 }
 
+// check runs the sql of the item. If the database supports
+// explain, the rules are applied to the query plan.
+func (item *checkItem) check(sess *rdb.Session) error {
+	exec := item.exec
+	if sess.CanExplain() {
+		plan, err := sess.Explain(exec.Sql, exec.Vals)
+		if err != nil {
+			// The sql error is the result of checking.
+			item.err = err
+			return nil
+		}
+		item.plan = plan
+		item.warns = runRules(plan, item.opts)
+		return nil
+	}
+	res, err := sess.Check(exec.Sql, exec.Vals)
+	if err != nil {
+		return err
+	}
+	item.err = res.GetErr()
+	for _, warn := range res.GetWarns() {
+		item.warns = append(item.warns, &Warn{
+			Rule:    "full-scan",
+			Message: warn,
+		})
+	}
+	return nil
+}
+
+// title returns the method name, with the branch set for
+// the dynamic method.
+func (item *checkItem) title() string {
+	if item.Branch == "" {
+		return item.Name
+	}
+	return fmt.Sprintf("%s (%s)", item.Name, item.Branch)
+}
+
 func fetchDir(dir string) ([]string, error) {
 	var paths []string
 	wf := func(path string, info os.FileInfo, err error) error {
@@ -104,7 +223,7 @@ func fetchDir(dir string) ([]string, error) {
 	return paths, nil
 }
 
-func getToCheck(path string) ([]*checkItem, error) {
+func getToCheck(path string, opts *Options) ([]*checkItem, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -121,22 +240,48 @@ func getToCheck(path string) ([]*checkItem, error) {
 		if !common.FindMethodTag(m, "check") {
 			continue
 		}
-		exec, err := common.Method2Exec(m, "check")
+		mopts, err := methodOptions(m, opts)
 		if err != nil {
 			err = errors.OnCompile(path, lines, err)
 			return nil, err
 		}
-		item := new(checkItem)
-		item.Path = path
-		item.Name = m.Name
-		item.exec = exec
+		vs, err := common.Method2Variants(m, "check", mopts.MaxVariants)
+		if err != nil {
+			err = errors.OnCompile(path, lines, err)
+			return nil, err
+		}
+		for _, v := range vs {
+			item := new(checkItem)
+			item.Path = path
+			item.Name = m.Name
+			item.Line = m.Line()
+			if len(vs) > 1 {
+				item.Branch = v.BranchDesc()
+			}
+			item.exec = v.Exec
+			item.opts = mopts
 
-		items = append(items, item)
+			items = append(items, item)
+		}
 	}
 
 	return items, nil
 }
 
+func countResult(items []*checkItem) (int, int) {
+	var errCnt, warnCnt int
+	for _, item := range items {
+		switch {
+		case item.err != nil:
+			errCnt += 1
+
+		case len(item.warns) > 0:
+			warnCnt += 1
+		}
+	}
+	return errCnt, warnCnt
+}
+
 func showResult(paths []string, items []*checkItem) {
 	group := make(map[string][]*checkItem)
 	for _, item := range items {
@@ -154,27 +299,26 @@ func showResult(paths []string, items []*checkItem) {
 		}
 		var nameLen int
 		for _, item := range items {
-			if len(item.Name) > nameLen {
-				nameLen = len(item.Name)
+			if len(item.title()) > nameLen {
+				nameLen = len(item.title())
 			}
 		}
 		nameFmt := "%-" + strconv.Itoa(nameLen) + "s"
 
 		fmt.Printf("file: %s\n", path)
 		for _, item := range items {
-			r := item.result
-			name := fmt.Sprintf(nameFmt, item.Name)
+			name := fmt.Sprintf(nameFmt, item.title())
 			fmt.Printf("  sql: %s", name)
-			if err := r.GetErr(); err != nil {
+			if err := item.err; err != nil {
 				errCnt += 1
 				fmt.Printf("\n    %s\n",
 					term.Red("error: "+err.Error()))
 				continue
 			}
-			if warns := r.GetWarns(); len(warns) > 0 {
+			if warns := item.warns; len(warns) > 0 {
 				warnCnt += 1
 				for _, warn := range warns {
-					fmt.Printf("\n    %s\n", term.Warn(warn))
+					fmt.Printf("\n    %s\n", term.Warn(warn.String()))
 				}
 			} else {
 				okCnt += 1
@@ -0,0 +1,279 @@
+package check
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fioncat/go-gendb/version"
+)
+
+// reportFunc converts the check results to the machine
+// readable format.
+type reportFunc func(items []*checkItem) ([]byte, error)
+
+var reports = map[string]reportFunc{
+	"json":  reportJSON,
+	"junit": reportJUnit,
+	"sarif": reportSARIF,
+}
+
+type jsonItem struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Method string `json:"method"`
+	Branch string `json:"branch,omitempty"`
+	Status string `json:"status"`
+
+	Error string      `json:"error,omitempty"`
+	Warns []*jsonWarn `json:"warnings,omitempty"`
+}
+
+type jsonWarn struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type jsonReport struct {
+	Ok    int `json:"ok"`
+	Error int `json:"error"`
+	Warn  int `json:"warn"`
+
+	Items []*jsonItem `json:"items"`
+}
+
+func (item *checkItem) status() string {
+	switch {
+	case item.err != nil:
+		return "error"
+
+	case len(item.warns) > 0:
+		return "warn"
+	}
+	return "ok"
+}
+
+func reportJSON(items []*checkItem) ([]byte, error) {
+	r := new(jsonReport)
+	r.Error, r.Warn = countResult(items)
+	r.Ok = len(items) - r.Error - r.Warn
+	r.Items = make([]*jsonItem, len(items))
+	for idx, item := range items {
+		ji := &jsonItem{
+			Path:   item.Path,
+			Line:   item.Line,
+			Method: item.Name,
+			Branch: item.Branch,
+			Status: item.status(),
+		}
+		if item.err != nil {
+			ji.Error = item.err.Error()
+		}
+		for _, warn := range item.warns {
+			ji.Warns = append(ji.Warns, &jsonWarn{
+				Rule:    warn.Rule,
+				Message: warn.Message,
+			})
+		}
+		r.Items[idx] = ji
+	}
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// JUnit: each sql file is a test suite, and each method
+// is a test case. The warnings are failures.
+type junitSuites struct {
+	XMLName xml.Name      `xml:"testsuites"`
+	Suites  []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Cases    []*junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+func reportJUnit(items []*checkItem) ([]byte, error) {
+	r := new(junitSuites)
+	suites := make(map[string]*junitSuite)
+	for _, item := range items {
+		suite := suites[item.Path]
+		if suite == nil {
+			suite = &junitSuite{Name: item.Path}
+			suites[item.Path] = suite
+			r.Suites = append(r.Suites, suite)
+		}
+		c := &junitCase{
+			Name:      item.title(),
+			Classname: item.Path,
+			File:      item.Path,
+			Line:      item.Line,
+		}
+		suite.Tests += 1
+		switch {
+		case item.err != nil:
+			suite.Errors += 1
+			c.Error = &junitMessage{
+				Message: item.err.Error(),
+				Content: item.err.Error(),
+			}
+
+		case len(item.warns) > 0:
+			suite.Failures += 1
+			lines := make([]string, len(item.warns))
+			for idx, warn := range item.warns {
+				lines[idx] = warn.String()
+			}
+			c.Failure = &junitMessage{
+				Message: fmt.Sprintf("%d warning(s)", len(item.warns)),
+				Content: strings.Join(lines, "\n"),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	data, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// SARIF 2.1.0, see https://docs.oasis-open.org/sarif/sarif/v2.1.0/
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+
+	// the rule id of the sql error.
+	sarifErrorRule = "sql-error"
+)
+
+type sarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool      `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	Version        string       `json:"version"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId    string           `json:"ruleId"`
+	Level     string           `json:"level"`
+	Message   sarifMessage     `json:"message"`
+	Locations []*sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysical `json:"physicalLocation"`
+}
+
+type sarifPhysical struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           sarifRegion   `json:"region"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func reportSARIF(items []*checkItem) ([]byte, error) {
+	driver := sarifDriver{
+		Name:           "go-gendb",
+		Version:        version.Full,
+		InformationURI: "https://github.com/fioncat/go-gendb",
+	}
+	driver.Rules = append(driver.Rules, &sarifRule{
+		Id:               sarifErrorRule,
+		ShortDescription: sarifMessage{Text: "the sql can not be explained"},
+	})
+	driver.Rules = append(driver.Rules, &sarifRule{
+		Id:               regressionRule,
+		ShortDescription: sarifMessage{Text: "the plan regresses from the baseline"},
+	})
+	for _, r := range Rules() {
+		driver.Rules = append(driver.Rules, &sarifRule{
+			Id:               r.Name,
+			ShortDescription: sarifMessage{Text: r.Desc},
+		})
+	}
+
+	run := &sarifRun{Tool: sarifTool{Driver: driver}}
+	// The results must not be null.
+	run.Results = make([]*sarifResult, 0)
+	for _, item := range items {
+		loc := &sarifLocation{PhysicalLocation: sarifPhysical{
+			ArtifactLocation: sarifArtifact{URI: filepath.ToSlash(item.Path)},
+			Region:           sarifRegion{StartLine: item.Line},
+		}}
+		if item.err != nil {
+			run.Results = append(run.Results, &sarifResult{
+				RuleId: sarifErrorRule,
+				Level:  "error",
+				Message: sarifMessage{Text: fmt.Sprintf("%s: %v",
+					item.title(), item.err)},
+				Locations: []*sarifLocation{loc},
+			})
+			continue
+		}
+		for _, warn := range item.warns {
+			run.Results = append(run.Results, &sarifResult{
+				RuleId: warn.Rule,
+				Level:  "warning",
+				Message: sarifMessage{Text: fmt.Sprintf("%s: %s",
+					item.title(), warn.Message)},
+				Locations: []*sarifLocation{loc},
+			})
+		}
+	}
+
+	log := &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []*sarifRun{run},
+	}
+	return json.MarshalIndent(log, "", "  ")
+}
@@ -0,0 +1,246 @@
+package check
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fioncat/go-gendb/compile/base"
+	"github.com/fioncat/go-gendb/compile/sql"
+	"github.com/fioncat/go-gendb/database/rdb"
+)
+
+// Options are the thresholds of the rules. They are given
+// by the command flags, and can be overwritten by the
+// "check" tag of each method:
+//
+//	-- +gen:check max_rows=100000 skip=[filesort,temporary]
+//
+// So "max_rows", "max_variants" and "skip" can not be used
+// as placeholder names in the methods to check.
+type Options struct {
+	// MaxRows is the max estimated rows examined for
+	// each table scan, 0 means no limit.
+	MaxRows int64
+
+	// MaxVariants is the max variants of the dynamic
+	// method to check, see common.Method2Variants.
+	MaxVariants int
+
+	// Skip are the names of the rules to skip.
+	Skip map[string]bool
+}
+
+func (o *Options) clone() *Options {
+	skip := make(map[string]bool, len(o.Skip))
+	for name := range o.Skip {
+		skip[name] = true
+	}
+	return &Options{
+		MaxRows:     o.MaxRows,
+		MaxVariants: o.MaxVariants,
+		Skip:        skip,
+	}
+}
+
+// Warn is a problem found by the rule.
+type Warn struct {
+	Rule    string
+	Message string
+}
+
+func (w *Warn) String() string {
+	return fmt.Sprintf("[%s] %s", w.Rule, w.Message)
+}
+
+// Rule checks the query plan, returns the problems found.
+type Rule struct {
+	Name string
+	Desc string
+
+	Check func(p *rdb.Plan, opts *Options) []string
+}
+
+var rules = make(map[string]*Rule)
+
+// Register adds a rule to check, the rule with the same
+// name will be replaced.
+func Register(r *Rule) {
+	rules[r.Name] = r
+}
+
+// Rules returns all the registered rules, sorted by name.
+func Rules() []*Rule {
+	rs := make([]*Rule, 0, len(rules))
+	for _, r := range rules {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Name < rs[j].Name
+	})
+	return rs
+}
+
+func runRules(p *rdb.Plan, opts *Options) []*Warn {
+	var warns []*Warn
+	for _, r := range Rules() {
+		if opts.Skip[r.Name] {
+			continue
+		}
+		for _, msg := range r.Check(p, opts) {
+			warns = append(warns, &Warn{Rule: r.Name, Message: msg})
+		}
+	}
+	return warns
+}
+
+// parseSkip parses the rule names, which are split by ",".
+func parseSkip(val string) (map[string]bool, error) {
+	skip := make(map[string]bool)
+	for _, name := range strings.Split(val, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if rules[name] == nil {
+			return nil, fmt.Errorf(`unknown rule "%s"`, name)
+		}
+		skip[name] = true
+	}
+	return skip, nil
+}
+
+// methodOptions overwrites the options by the "check" tag
+// of the method.
+func methodOptions(m *sql.Method, opts *Options) (*Options, error) {
+	opts = opts.clone()
+	for _, tag := range m.Tags {
+		if tag.Name != "check" {
+			continue
+		}
+		for _, opt := range tag.Options {
+			switch opt.Key {
+			case "max_rows":
+				n, err := strconv.ParseInt(opt.Value, 10, 64)
+				if err != nil || n < 0 {
+					return nil, opt.FmtError(`max_rows "%s" `+
+						`is not a valid number`, opt.Value)
+				}
+				opts.MaxRows = n
+
+			case "max_variants":
+				n, err := strconv.Atoi(opt.Value)
+				if err != nil || n <= 0 {
+					return nil, opt.FmtError(`max_variants "%s" `+
+						`is not a valid number`, opt.Value)
+				}
+				opts.MaxVariants = n
+
+			case "skip":
+				names, err := base.Arr1(opt.Value)
+				if err != nil {
+					return nil, opt.Trace(err)
+				}
+				skip, err := parseSkip(strings.Join(names, ","))
+				if err != nil {
+					return nil, opt.Trace(err)
+				}
+				for name := range skip {
+					opts.Skip[name] = true
+				}
+			}
+		}
+	}
+	return opts, nil
+}
+
+func init() {
+	Register(&Rule{
+		Name: "full-scan",
+		Desc: "full table scan",
+		Check: func(p *rdb.Plan, _ *Options) []string {
+			var warns []string
+			for _, t := range p.Tables {
+				if t.AccessType == "ALL" && !t.Inner {
+					warns = append(warns, fmt.Sprintf(`full table `+
+						`scan for table "%s", rows=%d`, t.Name, t.Rows))
+				}
+			}
+			return warns
+		},
+	})
+
+	Register(&Rule{
+		Name: "join-full-scan",
+		Desc: "full table scan on the inner table of a join",
+		Check: func(p *rdb.Plan, _ *Options) []string {
+			var warns []string
+			for _, t := range p.Tables {
+				if t.AccessType == "ALL" && t.Inner {
+					warns = append(warns, fmt.Sprintf(`full table `+
+						`scan for inner table "%s" of join, it is `+
+						`scanned for each outer row, rows=%d`, t.Name, t.Rows))
+				}
+			}
+			return warns
+		},
+	})
+
+	Register(&Rule{
+		Name: "filesort",
+		Desc: "sorting can not be done by index",
+		Check: func(p *rdb.Plan, _ *Options) []string {
+			if p.Filesort {
+				return []string{"using filesort"}
+			}
+			return nil
+		},
+	})
+
+	Register(&Rule{
+		Name: "temporary",
+		Desc: "temporary table is used",
+		Check: func(p *rdb.Plan, _ *Options) []string {
+			if p.Temporary {
+				return []string{"using temporary table"}
+			}
+			return nil
+		},
+	})
+
+	Register(&Rule{
+		Name: "rows",
+		Desc: "too many rows examined, see max_rows",
+		Check: func(p *rdb.Plan, opts *Options) []string {
+			if opts.MaxRows <= 0 {
+				return nil
+			}
+			var warns []string
+			for _, t := range p.Tables {
+				if t.Rows > opts.MaxRows {
+					warns = append(warns, fmt.Sprintf(`table "%s" `+
+						`examines %d rows, exceeds %d`, t.Name,
+						t.Rows, opts.MaxRows))
+				}
+			}
+			return warns
+		},
+	})
+
+	Register(&Rule{
+		Name: "unused-key",
+		Desc: "possible keys exist but none is used",
+		Check: func(p *rdb.Plan, _ *Options) []string {
+			var warns []string
+			for _, t := range p.Tables {
+				if len(t.PossibleKeys) > 0 && t.Key == "" {
+					warns = append(warns, fmt.Sprintf(`table "%s" `+
+						`has possible keys %v, but none is used`,
+						t.Name, t.PossibleKeys))
+				}
+			}
+			return warns
+		},
+	})
+}
@@ -2,6 +2,8 @@ package common
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/fioncat/go-gendb/compile/sql"
 )
@@ -27,19 +29,9 @@ func Method2Exec(m *sql.Method, name string) (
 		return nil, m.FmtError(`dynamic ` +
 			`method do not support execution.`)
 	}
-	vals := make(map[string]string)
-	for _, tag := range m.Tags {
-		if tag.Name != name {
-			continue
-		}
-		for _, opt := range tag.Options {
-			_, ok := vals[opt.Key]
-			if ok {
-				return nil, tag.FmtError(`param`+
-					` "%s" is duplcate`, opt.Key)
-			}
-			vals[opt.Key] = opt.Value
-		}
+	vals, err := tagValues(m, name)
+	if err != nil {
+		return nil, err
 	}
 
 	// Handle replace values
@@ -53,7 +45,7 @@ func Method2Exec(m *sql.Method, name string) (
 		}
 		reps[idx] = val
 	}
-	sql := fmt.Sprintf(m.State.Sql, reps...)
+	sql := fmt.Sprintf(inParens(m.State), reps...)
 
 	// Handle prepare values
 	pres := make([]interface{}, len(m.State.Prepares))
@@ -69,3 +61,29 @@ func Method2Exec(m *sql.Method, name string) (
 
 	return &Exec{Sql: sql, Vals: pres}, nil
 }
+
+// inRe matches the IN predicate before the placeholder.
+var inRe = regexp.MustCompile(`(?i)\bIN\s*$`)
+
+// inParens returns the sql of the statement, the prepare
+// placeholders of the slices without parens, such as
+// "id IN ${ids}", are wrapped into "(?)". The generated code
+// expands them by run.In, but the check and exec send the sql
+// directly, with one value for each placeholder.
+func inParens(state *sql.Statement) string {
+	var sb strings.Builder
+	last := 0
+	for _, pos := range state.PreparePos {
+		if !inRe.MatchString(state.Sql[:pos]) {
+			continue
+		}
+		sb.WriteString(state.Sql[last:pos])
+		sb.WriteString("(?)")
+		last = pos + 1
+	}
+	if last == 0 {
+		return state.Sql
+	}
+	sb.WriteString(state.Sql[last:])
+	return sb.String()
+}
@@ -0,0 +1,382 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/fioncat/go-gendb/api/sql/run"
+	"github.com/fioncat/go-gendb/compile/sql"
+)
+
+// ForRepeat is the number of iterations to expand the
+// "for" parts of the dynamic method, so that the "join"
+// of the loop is included.
+const ForRepeat = 2
+
+// Variant is a rendered shape of the dynamic method, each
+// "if" chain and "switch" takes one of its branches or none.
+type Variant struct {
+	// Branches are the descriptions of the branches taken,
+	// such as "if a > 0", "elif b > 0", "else of if a > 0",
+	// `case "id" of switch order`.
+	Branches []string
+
+	Exec *Exec
+}
+
+// BranchDesc returns the description of the branch set.
+func (v *Variant) BranchDesc() string {
+	if len(v.Branches) == 0 {
+		return "no if branch"
+	}
+	return strings.Join(v.Branches, ", ")
+}
+
+// choice is an "if" chain or a "switch" of the dynamic
+// method. opts[0] is the default option, which is nil (no
+// branch is taken), the "else" or the "default" branch, the
+// others are the "if", "elif" and "case" branches.
+type choice struct {
+	opts []*sql.DynamicPart
+}
+
+func collectChoices(dps []*sql.DynamicPart, cs []*choice) []*choice {
+	for _, dp := range dps {
+		switch dp.Type {
+		case sql.DynamicTypeIf:
+			c := &choice{opts: []*sql.DynamicPart{nil}}
+			for br := dp; br != nil; br = br.Else {
+				if br.Type == sql.DynamicTypeElse {
+					c.opts[0] = br
+				} else {
+					c.opts = append(c.opts, br)
+				}
+			}
+			cs = append(cs, c)
+			for br := dp; br != nil; br = br.Else {
+				cs = collectChoices(br.Children, cs)
+			}
+
+		case sql.DynamicTypeSwitch:
+			c := &choice{opts: []*sql.DynamicPart{nil}}
+			for _, br := range dp.Children {
+				if br.Type == sql.DynamicTypeDefault {
+					c.opts[0] = br
+				} else {
+					c.opts = append(c.opts, br)
+				}
+			}
+			cs = append(cs, c)
+			for _, br := range dp.Children {
+				cs = collectChoices(br.Children, cs)
+			}
+
+		case sql.DynamicTypeFor, sql.DynamicTypeWhere, sql.DynamicTypeSet:
+			cs = collectChoices(dp.Children, cs)
+		}
+	}
+	return cs
+}
+
+// Method2Variants renders the method into the variants to
+// execute. The static method has only one variant. For the
+// dynamic method, all the combinations of the "if" chains
+// and "switch" parts are rendered; if the count exceeds
+// max, only max of them are sampled, including none and all
+// of the "if" branches taken, and each single branch taken.
+// The sampling is stable for the same method.
+//
+// The values of the placeholders are the options of the
+// tag. Since the placeholders of the dynamic method are
+// usually expressions, such as `conds["id"]` and `u.Name`,
+// the value can also be given by the last name of the
+// expression ("id" and "Name").
+func Method2Variants(m *sql.Method, name string, max int) (
+	[]*Variant, error,
+) {
+	if !m.Dyn {
+		exec, err := Method2Exec(m, name)
+		if err != nil {
+			return nil, err
+		}
+		return []*Variant{{Exec: exec}}, nil
+	}
+	vals, err := tagValues(m, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := collectChoices(m.Dps, nil)
+	sizes := make([]int, len(cs))
+	for idx, c := range cs {
+		sizes[idx] = len(c.opts)
+	}
+
+	var vs []*Variant
+	// The branches in the chain not taken are not rendered,
+	// so different sets might render the same variant.
+	seen := make(map[string]bool)
+	for _, set := range choiceSets(sizes, max) {
+		taken := make(map[*sql.DynamicPart]bool, len(cs))
+		for idx, c := range cs {
+			if br := c.opts[set[idx]]; br != nil {
+				taken[br] = true
+			}
+		}
+		v := new(Variant)
+		r := &renderer{m: m, name: name, vals: vals, taken: taken,
+			described: make(map[*sql.DynamicPart]bool)}
+		err = r.parts(m.Dps)
+		if err != nil {
+			return nil, err
+		}
+		v.Branches = r.branches
+		sql := strings.Join(r.sqls, " ")
+		if len(r.reps) > 0 {
+			sql = fmt.Sprintf(sql, r.reps...)
+		}
+		v.Exec = &Exec{Sql: sql, Vals: r.pres}
+		key := v.BranchDesc()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// choiceSets returns the options of the choices, sizes are
+// the numbers of options, 0 is the default option.
+func choiceSets(sizes []int, max int) [][]int {
+	if max <= 0 {
+		max = 1
+	}
+	total := 1
+	for _, size := range sizes {
+		total *= size
+		if total > max {
+			break
+		}
+	}
+	if total <= max {
+		sets := make([][]int, 0, total)
+		for code := 0; code < total; code++ {
+			set := make([]int, len(sizes))
+			rest := code
+			for idx, size := range sizes {
+				set[idx] = rest % size
+				rest /= size
+			}
+			sets = append(sets, set)
+		}
+		return sets
+	}
+
+	var sets [][]int
+	seen := make(map[string]bool)
+	add := func(set []int) {
+		if len(sets) >= max {
+			return
+		}
+		key := fmt.Sprint(set)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		sets = append(sets, set)
+	}
+
+	n := len(sizes)
+	none := make([]int, n)
+	all := make([]int, n)
+	for idx := range all {
+		all[idx] = 1
+	}
+	add(none)
+	add(all)
+	for idx, size := range sizes {
+		for opt := 1; opt < size; opt++ {
+			set := make([]int, n)
+			set[idx] = opt
+			add(set)
+		}
+	}
+
+	// Fixed seed, so that the result is the same for
+	// each check.
+	r := rand.New(rand.NewSource(int64(n)))
+	for try := 0; len(sets) < max && try < max*16; try++ {
+		set := make([]int, n)
+		for idx, size := range sizes {
+			set[idx] = r.Intn(size)
+		}
+		add(set)
+	}
+	return sets
+}
+
+// renderer renders the dynamic parts with the taken
+// branches.
+type renderer struct {
+	m     *sql.Method
+	name  string
+	vals  map[string]string
+	taken map[*sql.DynamicPart]bool
+
+	sqls     []string
+	reps     []interface{}
+	pres     []interface{}
+	branches []string
+
+	// the branches described, the branch in "for" is
+	// rendered several times.
+	described map[*sql.DynamicPart]bool
+}
+
+func (r *renderer) state(state *sql.Statement) error {
+	for _, rep := range state.Replaces {
+		val, ok := lookupValue(r.vals, rep)
+		if !ok {
+			return r.m.FmtError(`%s: can not `+
+				`find value for replace placeholder`+
+				` "%s"`, r.name, rep)
+		}
+		r.reps = append(r.reps, val)
+	}
+	for _, pre := range state.Prepares {
+		val, ok := lookupValue(r.vals, pre)
+		if !ok {
+			return r.m.FmtError(`%s: can not `+
+				`find value for prepare placeholder`+
+				` "%s"`, r.name, pre)
+		}
+		r.pres = append(r.pres, val)
+	}
+	return nil
+}
+
+func (r *renderer) parts(dps []*sql.DynamicPart) error {
+	for _, dp := range dps {
+		var err error
+		switch dp.Type {
+		case sql.DynamicTypeConst:
+			r.sqls = append(r.sqls, inParens(dp.State))
+			err = r.state(dp.State)
+
+		case sql.DynamicTypeIf:
+			err = r.chain(dp)
+
+		case sql.DynamicTypeSwitch:
+			err = r.cases(dp)
+
+		case sql.DynamicTypeFor:
+			// The join is added only between the iterations
+			// which rendered parts, the same as the generated
+			// code.
+			join := len(r.sqls)
+			for idx := 0; idx < ForRepeat; idx++ {
+				start := len(r.sqls)
+				err = r.parts(dp.Children)
+				if err != nil {
+					return err
+				}
+				if dp.ForJoin != "" && start > join && len(r.sqls) > start {
+					r.sqls[start-1] += dp.ForJoin
+				}
+			}
+
+		case sql.DynamicTypeWhere:
+			start := len(r.sqls)
+			err = r.parts(dp.Children)
+			run.Where(r.sqls[start:])
+
+		case sql.DynamicTypeSet:
+			start := len(r.sqls)
+			err = r.parts(dp.Children)
+			run.Set(r.sqls[start:])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *renderer) chain(dp *sql.DynamicPart) error {
+	for br := dp; br != nil; br = br.Else {
+		if !r.taken[br] {
+			continue
+		}
+		if r.described[br] {
+			return r.parts(br.Children)
+		}
+		r.described[br] = true
+		switch {
+		case br.Type == sql.DynamicTypeElse:
+			r.branches = append(r.branches, "else of if "+dp.IfCond)
+
+		case br == dp:
+			r.branches = append(r.branches, "if "+br.IfCond)
+
+		default:
+			r.branches = append(r.branches, "elif "+br.IfCond)
+		}
+		return r.parts(br.Children)
+	}
+	return nil
+}
+
+func (r *renderer) cases(dp *sql.DynamicPart) error {
+	for _, br := range dp.Children {
+		if !r.taken[br] {
+			continue
+		}
+		if !r.described[br] {
+			r.described[br] = true
+			if br.Type == sql.DynamicTypeDefault {
+				r.branches = append(r.branches,
+					"default of switch "+dp.SwitchExpr)
+			} else {
+				r.branches = append(r.branches, fmt.Sprintf(
+					"case %s of switch %s", br.CaseExpr, dp.SwitchExpr))
+			}
+		}
+		return r.parts(br.Children)
+	}
+	return nil
+}
+
+func tagValues(m *sql.Method, name string) (map[string]string, error) {
+	vals := make(map[string]string)
+	for _, tag := range m.Tags {
+		if tag.Name != name {
+			continue
+		}
+		for _, opt := range tag.Options {
+			_, ok := vals[opt.Key]
+			if ok {
+				return nil, tag.FmtError(`param`+
+					` "%s" is duplcate`, opt.Key)
+			}
+			vals[opt.Key] = opt.Value
+		}
+	}
+	return vals, nil
+}
+
+// lookupValue finds the value of the placeholder, if it is
+// not found, use the last name of the expression.
+func lookupValue(vals map[string]string, ph string) (string, bool) {
+	if val, ok := vals[ph]; ok {
+		return val, true
+	}
+	ph = strings.TrimRight(ph, `"']) `)
+	idx := strings.LastIndexAny(ph, `.["'(`)
+	if idx < 0 {
+		return "", false
+	}
+	val, ok := vals[ph[idx+1:]]
+	return val, ok
+}
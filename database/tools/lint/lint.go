@@ -0,0 +1,489 @@
+package lint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fioncat/go-gendb/compile/golang"
+	"github.com/fioncat/go-gendb/compile/sql"
+	"github.com/fioncat/go-gendb/database/rdb"
+	"github.com/fioncat/go-gendb/misc/errors"
+	"github.com/fioncat/go-gendb/misc/log"
+	"github.com/fioncat/go-gendb/misc/term"
+)
+
+type Arg struct {
+	Log bool `flag:"log"`
+
+	DbType string `flag:"db-type" default:"mysql"`
+	Skip   string `flag:"skip"`
+
+	Path string `arg:"path"`
+}
+
+// the rule names.
+const (
+	ruleNoWhere      = "no-where"
+	ruleSelectStar   = "select-star"
+	ruleReplaceStr   = "replace-string"
+	ruleLimitPrepare = "limit-prepare"
+	ruleUnusedVar    = "unused-var"
+	ruleUnreferenced = "unreferenced"
+)
+
+var allRules = []string{
+	ruleNoWhere, ruleSelectStar, ruleReplaceStr,
+	ruleLimitPrepare, ruleUnusedVar, ruleUnreferenced,
+}
+
+// nonIntTypes are the basic types which can not be the
+// value of LIMIT and OFFSET in mysql, the string is quoted
+// (or sent as string by the prepared statement), which is
+// rejected by mysql.
+var nonIntTypes = map[string]bool{
+	"string":  true,
+	"bool":    true,
+	"float32": true,
+	"float64": true,
+}
+
+type problem struct {
+	rule string
+	err  error
+}
+
+type linter struct {
+	arg  *Arg
+	skip map[string]bool
+
+	// the sql dialect, the alias is converted.
+	dbType string
+
+	files []*sql.File
+
+	// the methods referenced by the Go interfaces, the
+	// key is the method.
+	refs map[*sql.Method]*golang.Method
+
+	problems []*problem
+}
+
+// Do lints the sql file(s) without database connection. If
+// the path is a directory, all the sql files and the Go
+// files under it are read; if it is a sql file, the Go files
+// in the same directory are read to find the references.
+func Do(arg *Arg) error {
+	if arg.Log {
+		log.Init(true, "")
+	}
+	dbType, err := rdb.CheckType(arg.DbType)
+	if err != nil {
+		return err
+	}
+	l := &linter{
+		arg:    arg,
+		dbType: dbType,
+		skip:   make(map[string]bool),
+		refs:   make(map[*sql.Method]*golang.Method),
+	}
+	for _, name := range strings.Split(arg.Skip, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !isRule(name) {
+			return fmt.Errorf(`unknown rule "%s"`, name)
+		}
+		l.skip[name] = true
+	}
+
+	sqlPaths, goPaths, err := fetchPaths(arg.Path)
+	if err != nil {
+		return err
+	}
+	if len(sqlPaths) == 0 {
+		return fmt.Errorf("no sql file to lint")
+	}
+	for _, path := range sqlPaths {
+		file, err := sql.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		l.files = append(l.files, file)
+	}
+	for _, path := range goPaths {
+		err = l.readGo(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range l.files {
+		for _, m := range file.Methods {
+			l.method(file, m)
+		}
+	}
+	l.vars()
+
+	for _, p := range l.problems {
+		fmt.Printf("%s %v\n", term.Warn("["+p.rule+"]"), p.err)
+		errors.ShowCompile(p.err)
+		fmt.Println()
+	}
+	if len(l.problems) > 0 {
+		return fmt.Errorf("lint found %d problem(s)", len(l.problems))
+	}
+	fmt.Println(term.Info("no problem found"))
+	return nil
+}
+
+func isRule(name string) bool {
+	for _, rule := range allRules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+func fetchPaths(path string) ([]string, []string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.IsDir() {
+		dir := filepath.Dir(path)
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		var goPaths []string
+		for _, info := range infos {
+			if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
+				goPaths = append(goPaths, filepath.Join(dir, info.Name()))
+			}
+		}
+		return []string{path}, goPaths, nil
+	}
+
+	var sqlPaths, goPaths []string
+	err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".sql":
+			// Skip the sql files which are not go-gendb
+			// files, such as DDL.
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(string(data), "+gen:sql") {
+				sqlPaths = append(sqlPaths, path)
+			}
+
+		case ".go":
+			goPaths = append(goPaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return sqlPaths, goPaths, nil
+}
+
+func (l *linter) add(rule string, file *sql.File, err error) {
+	if l.skip[rule] {
+		return
+	}
+	err = errors.Trace(file.Path, err)
+	err = errors.OnCompile(file.Path, file.Lines, err)
+	l.problems = append(l.problems, &problem{
+		rule: rule,
+		err:  err,
+	})
+}
+
+// readGo finds the sql methods referenced by the "sql"
+// interfaces in the Go file. The Go files which are not
+// go-gendb sql files are ignored.
+func (l *linter) readGo(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), "+gen:sql") {
+		return nil
+	}
+	file, err := golang.ReadLines(path, strings.Split(string(data), "\n"))
+	if err != nil {
+		return err
+	}
+	if file.Type != "sql" {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	for _, inter := range file.Interfaces {
+		if inter.Tag.Name != "sql" {
+			continue
+		}
+		var name string
+		var sqlPaths []string
+		for _, opt := range inter.Tag.Options {
+			switch opt.Key {
+			case "", "name":
+				name = opt.Value
+
+			case "file":
+				sqlPaths = append(sqlPaths, filepath.Join(dir, opt.Value))
+			}
+		}
+		for _, goMethod := range inter.Methods {
+			m := l.findMethod(sqlPaths, name, goMethod.Name)
+			if m != nil {
+				l.refs[m] = goMethod
+			}
+		}
+	}
+	return nil
+}
+
+// findMethod finds the sql method just like the sql linker,
+// the method declared with the interface name first.
+func (l *linter) findMethod(paths []string, inter, name string) *sql.Method {
+	var found *sql.Method
+	for _, path := range paths {
+		for _, file := range l.files {
+			if !samePath(file.Path, path) {
+				continue
+			}
+			for _, m := range file.Methods {
+				if m.Name != name {
+					continue
+				}
+				if m.Inter == inter {
+					return m
+				}
+				if found == nil {
+					found = m
+				}
+			}
+		}
+	}
+	return found
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+var (
+	whereRe      = regexp.MustCompile(`(?i)\bWHERE\b`)
+	selectStarRe = regexp.MustCompile(`(?i)\bSELECT\s+(DISTINCT\s+)?(\w+\.)?\*|,\s*(\w+\.)?\*`)
+	limitPrepRe  = regexp.MustCompile(`(?i)(\b(LIMIT|OFFSET)|\bLIMIT\s+\S+\s*,)\s*$`)
+)
+
+func firstKeyword(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// methodSql returns the sql of the method. For the dynamic
+// method, the parts always included (see dynamicSqls) are
+// returned, all the parts (all the branches) are included
+// if all is true.
+func methodSql(m *sql.Method, all bool) string {
+	if !m.Dyn {
+		return m.State.Sql
+	}
+	return strings.Join(dynamicSqls(m.Dps, all), " ")
+}
+
+// dynamicSqls returns the sqls of the parts. If all is
+// false, only the parts always included are returned: the
+// top level const parts, and the "where" and "set" blocks
+// with them.
+func dynamicSqls(dps []*sql.DynamicPart, all bool) []string {
+	var sqls []string
+	for _, dp := range dps {
+		switch dp.Type {
+		case sql.DynamicTypeConst:
+			sqls = append(sqls, dp.State.Sql)
+
+		case sql.DynamicTypeWhere, sql.DynamicTypeSet:
+			sub := dynamicSqls(dp.Children, all)
+			if len(sub) == 0 {
+				continue
+			}
+			if dp.Type == sql.DynamicTypeWhere {
+				sqls = append(sqls, "WHERE")
+			} else {
+				sqls = append(sqls, "SET")
+			}
+			sqls = append(sqls, sub...)
+
+		default:
+			if !all {
+				continue
+			}
+			for br := dp; br != nil; br = br.Else {
+				sqls = append(sqls, dynamicSqls(br.Children, all)...)
+			}
+		}
+	}
+	return sqls
+}
+
+func (l *linter) method(file *sql.File, m *sql.Method) {
+	constSql := methodSql(m, false)
+	allSql := methodSql(m, true)
+
+	switch firstKeyword(allSql) {
+	case "UPDATE", "DELETE":
+		// The WHERE in the "if" parts might be disabled.
+		if !whereRe.MatchString(constSql) {
+			l.add(ruleNoWhere, file, m.FmtError(`%s: %s without `+
+				`WHERE affects all the rows`, m.Name,
+				firstKeyword(allSql)))
+		}
+	}
+
+	if selectStarRe.MatchString(allSql) {
+		l.add(ruleSelectStar, file, m.FmtError(`%s: SELECT * `+
+			`breaks when the table changes, list the fields`, m.Name))
+	}
+
+	goMethod := l.refs[m]
+	if goMethod == nil {
+		l.add(ruleUnreferenced, file, m.FmtError(`%s: method `+
+			`is not referenced by any Go interface`, m.Name))
+		return
+	}
+	params := methodParams(goMethod)
+	if l.dbType == "mysql" {
+		for _, name := range limitPrepares(m) {
+			if nonIntTypes[params[name]] {
+				l.add(ruleLimitPrepare, file, m.FmtError(`%s: `+
+					`placeholder "${%s}" in LIMIT or OFFSET is `+
+					`fed by %s parameter, mysql only accepts `+
+					`integer`, m.Name, name, params[name]))
+			}
+		}
+	}
+	for _, rep := range methodReplaces(m) {
+		if params[rep] == "string" {
+			l.add(ruleReplaceStr, file, m.FmtError(`%s: replace `+
+				`placeholder "#{%s}" is fed by string parameter, `+
+				`it might cause sql injection, use "${%s}" or `+
+				`check the value`, m.Name, rep, rep))
+		}
+	}
+}
+
+// limitPrepares returns the names of the prepare
+// placeholders in LIMIT and OFFSET.
+func limitPrepares(m *sql.Method) []string {
+	var names []string
+	states := func(state *sql.Statement) {
+		for idx, name := range state.Prepares {
+			if idx >= len(state.PreparePos) {
+				break
+			}
+			if limitPrepRe.MatchString(state.Sql[:state.PreparePos[idx]]) {
+				names = append(names, name)
+			}
+		}
+	}
+	if !m.Dyn {
+		states(m.State)
+		return names
+	}
+	sql.WalkDynamic(m.Dps, func(dp *sql.DynamicPart) {
+		if dp.State != nil {
+			states(dp.State)
+		}
+	})
+	return names
+}
+
+func methodReplaces(m *sql.Method) []string {
+	if !m.Dyn {
+		return m.State.Replaces
+	}
+	var reps []string
+	sql.WalkDynamic(m.Dps, func(dp *sql.DynamicPart) {
+		if dp.State != nil {
+			reps = append(reps, dp.State.Replaces...)
+		}
+	})
+	return reps
+}
+
+// methodParams returns the types of the parameters of the
+// Go method, parsed from its definition.
+func methodParams(m *golang.Method) map[string]string {
+	params := make(map[string]string)
+	idx := strings.Index(m.Def, "(")
+	if idx < 0 {
+		return params
+	}
+	expr, err := parser.ParseExpr("func" + m.Def[idx:])
+	if err != nil {
+		return params
+	}
+	ft, ok := expr.(*ast.FuncType)
+	if !ok || ft.Params == nil {
+		return params
+	}
+	for _, field := range ft.Params.List {
+		ident, ok := field.Type.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		for _, name := range field.Names {
+			params[name.Name] = ident.Name
+		}
+	}
+	return params
+}
+
+// vars reports the vars which are not referenced by any
+// method. The vars are global, they can be referenced by
+// the methods in other files.
+func (l *linter) vars() {
+	used := make(map[string]bool)
+	for _, file := range l.files {
+		for _, m := range file.Methods {
+			for _, name := range m.Vars {
+				used[name] = true
+			}
+		}
+	}
+	for _, file := range l.files {
+		for _, v := range file.Vars {
+			if !used[v.Name] {
+				l.add(ruleUnusedVar, file, v.FmtError(`var "%s" `+
+					`is not referenced by any method`, v.Name))
+			}
+		}
+	}
+}
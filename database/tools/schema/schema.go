@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fioncat/go-gendb/database/rdb"
+	"github.com/fioncat/go-gendb/misc/log"
+	"github.com/fioncat/go-gendb/misc/term"
+)
+
+type Arg struct {
+	Log bool `flag:"log"`
+	All bool `flag:"all"`
+
+	DbType string `flag:"db-type"`
+	File   string `flag:"f" default:"gendb.schema.json"`
+	Tables string `flag:"tables"`
+
+	Action string `arg:"action"`
+	Conn   string `arg:"conn"`
+}
+
+func Do(arg *Arg) error {
+	if arg.Log {
+		log.Init(true, "")
+	}
+	switch arg.Action {
+	case "pull":
+		return pull(arg)
+
+	case "diff":
+		return diff(arg)
+	}
+	return fmt.Errorf(`unknown action "%s", `+
+		`expect "pull" or "diff"`, arg.Action)
+}
+
+func pull(arg *Arg) error {
+	dbType := arg.DbType
+	if dbType == "" {
+		dbType = "mysql"
+	}
+	err := rdb.Init(arg.Conn, dbType)
+	if err != nil {
+		return err
+	}
+
+	var tables []string
+	if arg.Tables != "" {
+		tables = strings.Split(arg.Tables, ",")
+	}
+	snapshot, err := rdb.Get().Snapshot(tables)
+	if err != nil {
+		return err
+	}
+
+	err = rdb.WriteSnapshot(arg.File, snapshot)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pull %s tables into %s\n",
+		term.Info(fmt.Sprint(len(snapshot.Tables))), arg.File)
+	return nil
+}
+
+func diff(arg *Arg) error {
+	old, err := rdb.ReadSnapshot(arg.File)
+	if err != nil {
+		return err
+	}
+	dbType := arg.DbType
+	if dbType == "" {
+		dbType = old.DbType
+	}
+	err = rdb.Init(arg.Conn, dbType)
+	if err != nil {
+		return err
+	}
+
+	var tables []string
+	if !arg.All {
+		tables = make([]string, len(old.Tables))
+		for idx, t := range old.Tables {
+			tables[idx] = t.Name
+		}
+	}
+	if arg.Tables != "" {
+		tables = strings.Split(arg.Tables, ",")
+	}
+	cur, err := snapshotIgnoreMissing(tables)
+	if err != nil {
+		return err
+	}
+
+	diffs := rdb.DiffSnapshot(old, cur, arg.All)
+	if len(diffs) == 0 {
+		fmt.Println(term.Info("no drift, the snapshot is up to date"))
+		return nil
+	}
+	for _, diff := range diffs {
+		switch diff[0] {
+		case '+':
+			fmt.Println(term.Info(diff))
+		case '-':
+			fmt.Println(term.Red(diff))
+		default:
+			fmt.Println(term.Warn(diff))
+		}
+	}
+	return fmt.Errorf("found %d drift(s) between %s and "+
+		"the database", len(diffs), arg.File)
+}
+
+// The tables in the snapshot may have been dropped in the
+// database, they should be reported as drift rather than
+// an error.
+func snapshotIgnoreMissing(tables []string) (*rdb.Snapshot, error) {
+	if len(tables) == 0 {
+		return rdb.Get().Snapshot(nil)
+	}
+	names, err := rdb.Get().Tables()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(names))
+	for _, name := range names {
+		exists[name] = true
+	}
+	toPull := make([]string, 0, len(tables))
+	for _, table := range tables {
+		// Qualified names might not be listed (such as the
+		// tables in other schemas of postgres).
+		if exists[table] || strings.Contains(table, ".") {
+			toPull = append(toPull, table)
+		}
+	}
+	if len(toPull) == 0 {
+		return &rdb.Snapshot{}, nil
+	}
+	return rdb.Get().Snapshot(toPull)
+}
@@ -25,6 +25,7 @@ type Arg struct {
 	Cache   bool   `flag:"cache"`
 
 	CacheTTL string `flag:"cache-ttl"`
+	Schema   string `flag:"schema"`
 
 	Path string `arg:"path"`
 }
@@ -44,6 +45,14 @@ func Do(arg *Arg) error {
 		}
 		rdb.TableCacheTTL = cacheDuration
 	}
+	if arg.Schema != "" {
+		// The snapshot takes precedence over the
+		// connection in the source file.
+		err := rdb.PinSchema(arg.Schema, "")
+		if err != nil {
+			return err
+		}
+	}
 	data, err := ioutil.ReadFile(arg.Path)
 	if err != nil {
 		return err
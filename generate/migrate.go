@@ -0,0 +1,101 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fioncat/go-gendb/coder"
+	"github.com/fioncat/go-gendb/compile/golang"
+	"github.com/fioncat/go-gendb/database/rdb"
+	"github.com/fioncat/go-gendb/link"
+	"github.com/fioncat/go-gendb/misc/errors"
+	"github.com/fioncat/go-gendb/misc/log"
+	"github.com/fioncat/go-gendb/misc/term"
+	"github.com/fioncat/go-gendb/version"
+)
+
+type MigrateArg struct {
+	Log bool `flag:"log"`
+
+	Dir    string `flag:"d" default:"migrations"`
+	Name   string `flag:"name" default:"migrate"`
+	Schema string `flag:"schema"`
+
+	AllowDestructive bool `flag:"allow-destructive"`
+
+	Path string `arg:"path"`
+}
+
+// Migrate writes the up/down migration files from the
+// database schema to the orm-sql declarations.
+func Migrate(arg *MigrateArg) error {
+	if arg.Log {
+		log.Init(true, "")
+	}
+	if arg.Schema != "" {
+		err := rdb.PinSchema(arg.Schema, "")
+		if err != nil {
+			return err
+		}
+	}
+	file, err := golang.ReadFile(arg.Path)
+	if err != nil {
+		return err
+	}
+	m, err := link.Migrate(file)
+	if err != nil {
+		return errors.OnCompile(arg.Path, file.Lines, err)
+	}
+	if len(m.Up) == 0 {
+		fmt.Println(term.Info("no change, the schema is up to date"))
+		return nil
+	}
+	if len(m.Destructive) > 0 && !arg.AllowDestructive {
+		for _, desc := range m.Destructive {
+			fmt.Println(term.Red("- " + desc))
+		}
+		return fmt.Errorf("found %d destructive change(s), use "+
+			"--allow-destructive to write them", len(m.Destructive))
+	}
+
+	err = os.MkdirAll(arg.Dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	prefix := time.Now().Format("20060102150405") + "_" + arg.Name
+	prefix = filepath.Join(arg.Dir, prefix)
+	for _, mf := range []struct {
+		suffix string
+		stmts  []string
+	}{
+		{".up.sql", m.Up},
+		{".down.sql", m.Down},
+	} {
+		path := prefix + mf.suffix
+		err = writeMigration(path, arg.Path, mf.stmts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("write %s statement(s) into %s\n",
+			term.Info(fmt.Sprint(len(mf.stmts))), path)
+	}
+	return nil
+}
+
+func writeMigration(path, source string, stmts []string) error {
+	c := new(coder.Coder)
+	c.P(0, "-- Code generated by go-gendb migrate.")
+	c.P(0, "-- go-gendb version: ", version.Short)
+	c.P(0, "-- source: ", source)
+	for _, stmt := range stmts {
+		c.Empty()
+		for _, line := range strings.Split(stmt, "\n") {
+			c.P(0, line)
+		}
+	}
+	c.Empty()
+	return c.WriteFile(path)
+}
@@ -0,0 +1,220 @@
+package generate
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fioncat/go-gendb/coder"
+	"github.com/fioncat/go-gendb/compile/orm"
+	"github.com/fioncat/go-gendb/config"
+	"github.com/fioncat/go-gendb/database/rdb"
+	"github.com/fioncat/go-gendb/misc/errors"
+	"github.com/fioncat/go-gendb/misc/log"
+	"github.com/fioncat/go-gendb/misc/term"
+	"github.com/fioncat/go-gendb/version"
+)
+
+type PullOrmArg struct {
+	Log bool `flag:"log"`
+
+	Output  string `flag:"o" default:"orm.go"`
+	Package string `flag:"pkg"`
+	DbType  string `flag:"db-type"`
+	Schema  string `flag:"schema"`
+	Null    string `flag:"null"`
+
+	Conn   string   `arg:"conn"`
+	Tables []string `arg:"tables"`
+}
+
+// PullOrm describes the tables and writes them into a Go
+// source file as orm-sql declarations, so that the legacy
+// tables can be managed by the orm-sql linker.
+func PullOrm(arg *PullOrmArg) error {
+	if arg.Log {
+		log.Init(true, "")
+	}
+	err := rdb.CheckNull(arg.Null)
+	if err != nil {
+		return err
+	}
+	pkg := arg.Package
+	if pkg == "" {
+		dir, err := filepath.Abs(filepath.Dir(arg.Output))
+		if err != nil {
+			return err
+		}
+		pkg = filepath.Base(dir)
+	}
+
+	cfg, err := config.Find(arg.Output)
+	if err != nil {
+		return err
+	}
+	if cfg != nil {
+		rdb.SetTypes(cfg.Types)
+	}
+
+	dbType := arg.DbType
+	if arg.Schema != "" {
+		// Describe the tables offline, the conn is only
+		// written into the file.
+		err = rdb.InitSchema(arg.Schema, dbType)
+	} else {
+		if dbType == "" {
+			dbType = "mysql"
+		}
+		err = rdb.Init(arg.Conn, dbType)
+	}
+	if err != nil {
+		return err
+	}
+	if dbType == "" {
+		dbType = rdb.Get().DbType()
+	}
+
+	tables := arg.Tables
+	if len(tables) == 0 {
+		tables, err = rdb.Get().Tables()
+		if err != nil {
+			return errors.Trace("list tables", err)
+		}
+	}
+	rs := make([]*orm.Result, len(tables))
+	for idx, table := range tables {
+		rs[idx], err = orm.FromDatabase(table, "", arg.Null)
+		if err != nil {
+			return errors.Trace(table, err)
+		}
+	}
+
+	c := new(coder.Coder)
+	c.P(0, "// +gen:orm-sql v=", version.Short)
+	c.P(0, "// +gen:orm-sql conn=", dbType, ",", arg.Conn)
+	if arg.Null != "" {
+		c.P(0, "// +gen:orm-sql null=", arg.Null)
+	}
+	c.Empty()
+	c.P(0, "package ", pkg)
+	pullOrmImports(c, rs)
+	for _, r := range rs {
+		c.Empty()
+		pullOrmStruct(c, r)
+	}
+	c.Empty()
+
+	err = c.WriteFile(arg.Output)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pull %s tables into %s\n",
+		term.Info(fmt.Sprint(len(rs))), arg.Output)
+	return nil
+}
+
+func pullOrmImports(c *coder.Coder, rs []*orm.Result) {
+	pathMap := make(map[string]bool)
+	var paths []string
+	for _, r := range rs {
+		for _, f := range r.Fields {
+			_, path := rdb.TypeImport(f.GoType)
+			if path == "" || pathMap[path] {
+				continue
+			}
+			pathMap[path] = true
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	sort.Strings(paths)
+	c.Empty()
+	if len(paths) == 1 {
+		c.P(0, "import ", coder.Quote(paths[0]))
+		return
+	}
+	c.P(0, "import (")
+	for _, path := range paths {
+		c.P(1, coder.Quote(path))
+	}
+	c.P(0, ")")
+}
+
+func pullOrmStruct(c *coder.Coder, r *orm.Result) {
+	if r.Comment != "" {
+		c.P(0, "// ", r.Comment)
+	}
+	c.P(0, "// +gen:orm table=", ormValue(r.Table), " name=", r.Name)
+
+	// The single field indexes are written as the field
+	// flags, others are written here.
+	fieldFlags := make(map[*orm.Field][]string, len(r.Fields))
+	for _, f := range r.PrimaryKey.Fields {
+		fieldFlags[f] = append(fieldFlags[f], "primary")
+	}
+	for _, f := range r.Fields {
+		if f.AutoIncr {
+			fieldFlags[f] = append(fieldFlags[f], "auto-incr")
+		}
+		if f.NotNull {
+			fieldFlags[f] = append(fieldFlags[f], "notnull")
+		}
+	}
+	keyTag := func(name string, keys []*orm.Index) {
+		var arrs []string
+		for _, key := range keys {
+			if len(key.Fields) == 1 {
+				f := key.Fields[0]
+				fieldFlags[f] = append(fieldFlags[f], name)
+				continue
+			}
+			names := make([]string, len(key.Fields))
+			for idx, f := range key.Fields {
+				names[idx] = f.GoName
+			}
+			arrs = append(arrs, "["+strings.Join(names, ",")+"]")
+		}
+		if len(arrs) > 0 {
+			c.P(0, "// +gen:orm ", name, "=", strings.Join(arrs, ","))
+		}
+	}
+	keyTag("index", r.Indexes)
+	keyTag("unique", r.UniqueKeys)
+
+	c.P(0, "type _", coder.UnExport(r.Name), " struct {")
+	for idx, f := range r.Fields {
+		if idx > 0 {
+			c.Empty()
+		}
+		if flags := fieldFlags[f]; len(flags) > 0 {
+			c.P(1, "// +gen:orm flags=[", strings.Join(flags, ","), "]")
+		}
+		if coder.DbName(f.GoName) != f.DbName {
+			c.P(1, "// +gen:orm name=", ormValue(f.DbName))
+		}
+		c.P(1, "// +gen:orm type=", ormValue(f.DbType))
+		if f.Default != "" {
+			c.P(1, "// +gen:orm default=", ormValue(f.Default))
+		}
+		if f.Comment != "" {
+			c.P(1, f.GoName, " ", f.GoType, " // ", f.Comment)
+		} else {
+			c.P(1, f.GoName, " ", f.GoType)
+		}
+	}
+	c.P(0, "}")
+}
+
+// ormValue quotes the tag value, the quotation mark
+// that does not appear in the value is used.
+func ormValue(val string) string {
+	for _, quo := range []string{`"`, "'", "`"} {
+		if !strings.Contains(val, quo) {
+			return quo + val + quo
+		}
+	}
+	return `"` + val + `"`
+}
@@ -36,6 +36,9 @@ func (t *target) Imports(ic *coder.Import) {
 	ic.Add("", "gopkg.in/mgo.v2/bson")
 	ic.Add("mgo", "gopkg.in/mgo.v2")
 	ic.Add("mgoapi", t.conf["mgoapi_path"])
+	if t.context() {
+		ic.Add("", "context")
+	}
 }
 
 func (t *target) Consts(c *coder.Var, ic *coder.Import) {
@@ -121,9 +124,18 @@ func (t *target) Funcs(c *coder.FunctionGroup) {
 		return
 	}
 	sessUse := t.conf["sess_use"]
-	var colParam string
+	// The arguments to call the oper methods.
+	var args []string
+	if t.context() {
+		args = append(args, "ctx")
+	}
 	if sessUse == "sess" {
-		colParam = "sess"
+		args = append(args, "sess")
+	}
+	colParam := strings.Join(args, ", ")
+	argPrefix := colParam
+	if argPrefix != "" {
+		argPrefix += ", "
 	}
 
 	f := c.Add()
@@ -137,13 +149,9 @@ func (t *target) Funcs(c *coder.FunctionGroup) {
 	}
 	// Ensure Indexes
 	f = c.Add()
-	if sessUse == "sess" {
-		f.Def("Ensure"+t.r.Name+"Indexes",
-			"Ensure"+t.r.Name+"Indexes(sess *mgo.Session) error")
-	} else {
-		f.Def("Ensure"+t.r.Name+"Indexes",
-			"Ensure"+t.r.Name+"Indexes() error")
-	}
+	f.Def("Ensure"+t.r.Name+"Indexes",
+		"Ensure"+t.r.Name+"Indexes("+t.params(nil)+") error")
+	t.checkContext(f, []string{"error"})
 	f.P(0, "_sess, col := ", operName, ".GetCol(", colParam, ")")
 	f.P(0, "defer _sess.Close()")
 	for _, idx := range t.r.Indexes {
@@ -199,24 +207,41 @@ skipIndex:
 	f.Def("Walk", "(q *", t.r.Name, "Query) Walk(walkFunc func(o *", t.r.Name, ") error) error")
 	f.P(0, "iter := q.Iter()")
 	f.P(0, "var o *", t.r.Name)
-	f.P(0, "for iter.Next(&o) {")
+	if t.context() {
+		f.P(0, "for q.Err() == nil && iter.Next(&o) {")
+	} else {
+		f.P(0, "for iter.Next(&o) {")
+	}
 	f.P(1, "err := walkFunc(o)")
 	f.P(1, "if err != nil {")
 	f.P(2, "return err")
 	f.P(1, "}")
 	f.P(0, "}")
+	if t.context() {
+		f.P(0, "if err := q.Err(); err != nil {")
+		f.P(1, "iter.Close()")
+		f.P(1, "return err")
+		f.P(0, "}")
+	}
 	f.P(0, "return iter.Err()")
 
 	f = c.Add()
 	t.funcDef(true, f, "GetCol", nil, []string{"*mgo.Session", "*mgo.Collection"})
 	f.P(0, "_sess := ", sessUse, ".Clone()")
+	if t.context() {
+		f.P(0, "mgoapi.SetContext(ctx, _sess)")
+	}
 	f.P(0, "return _sess, _sess.DB(", t.r.Name, "Database).C(", t.r.Name, "Collection)")
 
 	f = c.Add()
 	t.funcDef(true, f, "Find", []string{"query interface{}"}, []string{"*" + t.r.Name + "Query"})
 	f.P(0, "_sess, col := oper.GetCol(", colParam, ")")
 	f.P(0, "mq := col.Find(query)")
-	f.P(0, "return &", t.r.Name, "Query{Query: mgoapi.NewQuery(mq, _sess)}")
+	if t.context() {
+		f.P(0, "return &", t.r.Name, "Query{Query: mgoapi.NewQueryContext(ctx, mq, _sess)}")
+	} else {
+		f.P(0, "return &", t.r.Name, "Query{Query: mgoapi.NewQuery(mq, _sess)}")
+	}
 
 	f = c.Add()
 	t.funcDef(true, f, "FindById", []string{"id string"}, []string{"*" + t.r.Name, "error"})
@@ -237,11 +262,7 @@ skipIndex:
 
 	f = c.Add()
 	t.funcDef(true, f, "Count", []string{"query interface{}"}, []string{"int"})
-	if sessUse == "sess" {
-		f.P(0, "cnt, _ := oper.CountE(sess, query)")
-	} else {
-		f.P(0, "cnt, _ := oper.CountE(query)")
-	}
+	f.P(0, "cnt, _ := oper.CountE(", argPrefix, "query)")
 	f.P(0, "return cnt")
 
 	f = c.Add()
@@ -273,11 +294,7 @@ skipIndex:
 		pName := coder.UnExport(field.GoName)
 		t.funcDef(true, f, "FindManyBy"+field.GoName, []string{
 			fmt.Sprintf("%s %s", pName, field.GoType)}, []string{"[]*" + t.r.Name, "error"})
-		if sessUse == "sess" {
-			f.P(0, "q := oper.Find(sess, bson.M{", t.r.Name, "Field", field.GoName, ": ", pName, "})")
-		} else {
-			f.P(0, "q := oper.Find(bson.M{", t.r.Name, "Field", field.GoName, ": ", pName, "})")
-		}
+		f.P(0, "q := oper.Find(", argPrefix, "bson.M{", t.r.Name, "Field", field.GoName, ": ", pName, "})")
 		f.P(0, "return q.All()")
 	}
 
@@ -295,32 +312,19 @@ skipIndex:
 		pName := coder.UnExport(field.GoName)
 		t.funcDef(true, f, "FindOneBy"+field.GoName, []string{
 			fmt.Sprintf("%s %s", pName, field.GoType)}, []string{"*" + t.r.Name, "error"})
-		if sessUse == "sess" {
-			f.P(0, "q := oper.Find(sess, bson.M{", t.r.Name, "Field", field.GoName, ": ", pName, "})")
-		} else {
-			f.P(0, "q := oper.Find(bson.M{", t.r.Name, "Field", field.GoName, ": ", pName, "})")
-		}
+		f.P(0, "q := oper.Find(", argPrefix, "bson.M{", t.r.Name, "Field", field.GoName, ": ", pName, "})")
 		f.P(0, "return q.One()")
 	}
 }
 
 func (t *target) funcDef(isOper bool, f *coder.Function, name string, params []string, rets []string) {
-	sessUse := t.conf["sess_use"]
 	var def string
 	if isOper {
 		def = fmt.Sprintf("(oper *%s) %s(", "_"+t.r.Name+"Oper", name)
 	} else {
 		def = fmt.Sprintf("(o *%s) %s(", t.r.Name, name)
 	}
-	if sessUse == "sess" {
-		def += "sess *mgo.Session"
-	}
-	if len(params) > 0 {
-		if sessUse == "sess" {
-			def += ", "
-		}
-		def += strings.Join(params, ", ")
-	}
+	def += t.params(params)
 	def += ") "
 	switch len(rets) {
 	case 1:
@@ -330,4 +334,44 @@ func (t *target) funcDef(isOper bool, f *coder.Function, name string, params []s
 		def += "(" + strings.Join(rets, ", ") + ")"
 	}
 	f.Def(name, def)
+	t.checkContext(f, rets)
+}
+
+func (t *target) context() bool {
+	return t.conf["context"] == "true"
+}
+
+// params returns the parameter list, prefixed by the context
+// and session.
+func (t *target) params(params []string) string {
+	var head []string
+	if t.context() {
+		head = append(head, "ctx context.Context")
+	}
+	if t.conf["sess_use"] == "sess" {
+		head = append(head, "sess *mgo.Session")
+	}
+	return strings.Join(append(head, params...), ", ")
+}
+
+// checkContext returns the error of the context at the
+// beginning of the function, since mgo does not support
+// context.
+func (t *target) checkContext(f *coder.Function, rets []string) {
+	if !t.context() || len(rets) == 0 || rets[len(rets)-1] != "error" {
+		return
+	}
+	vals := make([]string, len(rets))
+	for idx, ret := range rets[:len(rets)-1] {
+		switch {
+		case strings.HasPrefix(ret, "*"), strings.HasPrefix(ret, "[]"):
+			vals[idx] = "nil"
+		default:
+			vals[idx] = "0"
+		}
+	}
+	vals[len(vals)-1] = "err"
+	f.P(0, "if err := ctx.Err(); err != nil {")
+	f.P(1, "return ", strings.Join(vals, ", "))
+	f.P(0, "}")
 }
@@ -38,36 +38,7 @@ func createTable(c *coder.Coder, r *orm.Result) {
 	}
 	for _, f := range r.Fields {
 		_, isId := idMap[f.GoName]
-		sb := new(stringBuilder)
-		sb.Append("`" + f.DbName + "`")
-		sb.Append(strings.ToLower(f.DbType))
-		if f.NotNull || isId {
-			sb.Append("NOT NULL")
-		}
-		var fieldDefault string
-		if f.Default != "" {
-			fieldDefault = f.Default
-		} else {
-			if f.NotNull {
-				if f.GoType == "string" {
-					fieldDefault = "''"
-				} else {
-					fieldDefault = "0"
-				}
-			} else {
-				fieldDefault = "NULL"
-			}
-		}
-		if !isId && !f.AutoIncr {
-			sb.Append("DEFAULT " + fieldDefault)
-		}
-		if f.AutoIncr {
-			sb.Append("AUTO_INCREMENT")
-		}
-		if f.Comment != "" {
-			sb.Append("COMMENT '" + f.Comment + "'")
-		}
-		c.P(0, "  ", sb.Get(), ",")
+		c.P(0, "  ", columnDef(f, isId), ",")
 	}
 	c.Empty()
 
@@ -84,14 +55,11 @@ func createTable(c *coder.Coder, r *orm.Result) {
 
 	if len(r.UniqueKeys) > 0 {
 		for i, key := range r.UniqueKeys {
-			names := make([]string, len(key.Fields))
 			vals := make([]string, len(key.Fields))
 			for idx, f := range key.Fields {
-				names[idx] = f.GoName
 				vals[idx] = fmt.Sprintf("`%s`", f.DbName)
 			}
-			idx := fmt.Sprintf("`unique_%s_%s`(%s),",
-				r.Table, strings.Join(names, "_"),
+			idx := fmt.Sprintf("`%s`(%s),", keyName(r, key, true),
 				strings.Join(vals, ","))
 			if i == len(r.UniqueKeys)-1 && len(r.Indexes) == 0 {
 				idx = idx[:len(idx)-1]
@@ -102,14 +70,11 @@ func createTable(c *coder.Coder, r *orm.Result) {
 	}
 	if len(r.Indexes) > 0 {
 		for i, key := range r.Indexes {
-			names := make([]string, len(key.Fields))
 			vals := make([]string, len(key.Fields))
 			for idx, f := range key.Fields {
-				names[idx] = f.GoName
 				vals[idx] = fmt.Sprintf("`%s`", f.DbName)
 			}
-			idx := fmt.Sprintf("`index_%s_%s`(%s),",
-				r.Table, strings.Join(names, "_"),
+			idx := fmt.Sprintf("`%s`(%s),", keyName(r, key, false),
 				strings.Join(vals, ","))
 			if i == len(r.Indexes)-1 {
 				idx = idx[:len(idx)-1]
@@ -118,7 +83,63 @@ func createTable(c *coder.Coder, r *orm.Result) {
 		}
 		c.Empty()
 	}
-	c.P(0, ") ENGINE=InnoDB COMMENT '", r.Comment, "';")
+	c.P(0, ") ENGINE=InnoDB COMMENT ", quoteComment(r.Comment), ";")
+}
+
+// quoteComment returns the comment as a MySQL string
+// literal, the quotes and backslashes are escaped.
+func quoteComment(comment string) string {
+	comment = strings.ReplaceAll(comment, `\`, `\\`)
+	comment = strings.ReplaceAll(comment, "'", "''")
+	return "'" + comment + "'"
+}
+
+// columnDef returns the definition of the field in
+// "CREATE TABLE" or "ALTER TABLE".
+func columnDef(f *orm.Field, isId bool) string {
+	sb := new(stringBuilder)
+	sb.Append("`" + f.DbName + "`")
+	sb.Append(strings.ToLower(f.DbType))
+	if f.NotNull || isId {
+		sb.Append("NOT NULL")
+	}
+	if !isId && !f.AutoIncr {
+		sb.Append("DEFAULT " + fieldDefault(f))
+	}
+	if f.AutoIncr {
+		sb.Append("AUTO_INCREMENT")
+	}
+	if f.Comment != "" {
+		sb.Append("COMMENT " + quoteComment(f.Comment))
+	}
+	return sb.Get()
+}
+
+func fieldDefault(f *orm.Field) string {
+	if f.Default != "" {
+		return f.Default
+	}
+	if f.NotNull {
+		if f.GoType == "string" {
+			return "''"
+		}
+		return "0"
+	}
+	return "NULL"
+}
+
+// keyName returns the name of the unique key or index.
+func keyName(r *orm.Result, key *orm.Index, unique bool) string {
+	names := make([]string, len(key.Fields))
+	for idx, f := range key.Fields {
+		names[idx] = f.GoName
+	}
+	prefix := "index"
+	if unique {
+		prefix = "unique"
+	}
+	return fmt.Sprintf("%s_%s_%s", prefix, r.Table,
+		strings.Join(names, "_"))
 }
 
 type stringBuilder struct {
@@ -3,11 +3,13 @@ package orm_sql
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/fioncat/go-gendb/coder"
 	"github.com/fioncat/go-gendb/compile/golang"
 	"github.com/fioncat/go-gendb/compile/orm"
+	"github.com/fioncat/go-gendb/database/rdb"
 	"github.com/fioncat/go-gendb/misc/log"
 )
 
@@ -16,17 +18,26 @@ const (
 	runName = "run_name"
 	dbUse   = "db_use"
 	sqlPath = "sql_path"
+
+	maxPlaceholders = "max_placeholders"
+
+	useContext = "context"
 )
 
 type Linker struct{}
 
 func (*Linker) DefaultConf() map[string]string {
 	return map[string]string{
-		runPath: "github.com/fioncat/go-gendb/api/sql/run",
-		runName: "run",
-		dbUse:   "db",
-		sqlPath: "",
-		"db":    "",
+		runPath:    "github.com/fioncat/go-gendb/api/sql/run",
+		runName:    "run",
+		dbUse:      "db",
+		sqlPath:    "",
+		useContext: "false",
+		"db":       "",
+
+		// The limit of MySQL, InsertBatch is executed in
+		// chunks to keep the placeholders under it.
+		maxPlaceholders: "65535",
 	}
 }
 
@@ -34,6 +45,11 @@ func (*Linker) Do(gfile *golang.File, conf map[string]string) (
 	[]coder.Target, error,
 ) {
 	start := time.Now()
+	maxPh, err := strconv.Atoi(conf[maxPlaceholders])
+	if err != nil || maxPh <= 0 {
+		return nil, fmt.Errorf(`%s "%s" is not a `+
+			`positive integer`, maxPlaceholders, conf[maxPlaceholders])
+	}
 	rs, err := orm.Parse(gfile, false)
 	if err != nil {
 		return nil, err
@@ -56,6 +72,8 @@ func (*Linker) Do(gfile *golang.File, conf map[string]string) (
 		t.path = gfile.Path
 		t.r = r
 		t.conf = conf
+		t.maxPh = maxPh
+		t.dbType = rdb.ConnType(r.Conn)
 		t.operName = fmt.Sprintf("%sOper", r.Name)
 		t.operType = fmt.Sprintf("_%s", t.operName)
 
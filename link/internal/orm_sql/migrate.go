@@ -0,0 +1,337 @@
+package orm_sql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fioncat/go-gendb/coder"
+	"github.com/fioncat/go-gendb/compile/orm"
+	"github.com/fioncat/go-gendb/database/rdb"
+	"github.com/fioncat/go-gendb/misc/errors"
+)
+
+// Migration is the change from the database schema to
+// the orm declarations.
+type Migration struct {
+	// Up are the statements to apply the change, and
+	// Down are the statements to revert it, in order.
+	Up   []string
+	Down []string
+
+	// Destructive describes the changes that may lose
+	// data or constraints, such as dropping columns and
+	// indexes.
+	Destructive []string
+}
+
+// Migrate compares the orm declarations with the tables
+// described by the current rdb session. The tables that
+// are not declared are ignored.
+func Migrate(rs []*orm.Result) (*Migration, error) {
+	// The tables of each session, see the "conn" option
+	// of the struct.
+	sessTables := make(map[*rdb.Session]map[string]bool)
+	m := new(Migration)
+	for _, r := range rs {
+		sess, err := r.Session()
+		if err != nil {
+			return nil, errors.Trace(r.Name, err)
+		}
+		if dbType := sess.DbType(); dbType != "mysql" {
+			return nil, fmt.Errorf(`migrate does not `+
+				`support "%s", only "mysql" is supported`, dbType)
+		}
+		exists := sessTables[sess]
+		if exists == nil {
+			names, err := sess.Tables()
+			if err != nil {
+				return nil, errors.Trace("list tables", err)
+			}
+			exists = make(map[string]bool, len(names))
+			for _, name := range names {
+				exists[name] = true
+			}
+			sessTables[sess] = exists
+		}
+
+		if !exists[r.Table] {
+			c := new(coder.Coder)
+			createTable(c, r)
+			m.add(c.String(), fmt.Sprintf("DROP TABLE `%s`;", r.Table))
+			continue
+		}
+		table, err := sess.Desc(r.Table)
+		if err != nil {
+			return nil, errors.Trace(r.Table, err)
+		}
+		mt := &migrateTable{m: m, r: r, table: table}
+		mt.fields()
+		mt.primaryKey()
+		mt.indexes()
+		mt.comment()
+	}
+
+	// The down statements are reverted in the opposite
+	// order of the up statements.
+	for i, j := 0, len(m.Down)-1; i < j; i, j = i+1, j-1 {
+		m.Down[i], m.Down[j] = m.Down[j], m.Down[i]
+	}
+	return m, nil
+}
+
+func (m *Migration) add(up, down string) {
+	m.Up = append(m.Up, up)
+	m.Down = append(m.Down, down)
+}
+
+type migrateTable struct {
+	m *Migration
+	r *orm.Result
+
+	table rdb.Table
+}
+
+func (mt *migrateTable) alter(up, down string) {
+	mt.m.add(fmt.Sprintf("ALTER TABLE `%s` %s;", mt.r.Table, up),
+		fmt.Sprintf("ALTER TABLE `%s` %s;", mt.r.Table, down))
+}
+
+func (mt *migrateTable) destructive(format string, a ...interface{}) {
+	desc := fmt.Sprintf(format, a...)
+	desc = fmt.Sprintf(`table "%s": %s`, mt.r.Table, desc)
+	mt.m.Destructive = append(mt.m.Destructive, desc)
+}
+
+// position returns the "AFTER" clause of the column at
+// idx, to keep the column order the same as the names.
+func position(names []string, idx int) string {
+	if idx == 0 {
+		return "FIRST"
+	}
+	return fmt.Sprintf("AFTER `%s`", names[idx-1])
+}
+
+func (mt *migrateTable) fields() {
+	isId := make(map[*orm.Field]bool, len(mt.r.PrimaryKey.Fields))
+	for _, f := range mt.r.PrimaryKey.Fields {
+		isId[f] = true
+	}
+	ormNames := make([]string, len(mt.r.Fields))
+	declared := make(map[string]bool, len(mt.r.Fields))
+	for idx, f := range mt.r.Fields {
+		ormNames[idx] = f.DbName
+		declared[f.DbName] = true
+	}
+
+	for idx, f := range mt.r.Fields {
+		def := columnDef(f, isId[f])
+		dbField := mt.table.Field(f.DbName)
+		if dbField == nil {
+			mt.alter("ADD COLUMN "+def+" "+position(ormNames, idx),
+				fmt.Sprintf("DROP COLUMN `%s`", f.DbName))
+			continue
+		}
+		if sameColumn(f, isId[f], dbField) {
+			continue
+		}
+		if !sameType(f.DbType, dbField.GetType()) {
+			mt.destructive(`field "%s" type %s -> %s`, f.DbName,
+				dbField.GetType(), f.DbType)
+		}
+		mt.alter("MODIFY COLUMN "+def, "MODIFY COLUMN "+dbColumnDef(dbField))
+	}
+
+	dbNames := mt.table.FieldNames()
+	for idx, name := range dbNames {
+		if declared[name] {
+			continue
+		}
+		mt.destructive(`drop field "%s"`, name)
+		dbField := mt.table.Field(name)
+		mt.alter(fmt.Sprintf("DROP COLUMN `%s`", name),
+			"ADD COLUMN "+dbColumnDef(dbField)+" "+position(dbNames, idx))
+	}
+}
+
+func (mt *migrateTable) primaryKey() {
+	var dbPks []string
+	for _, name := range mt.table.FieldNames() {
+		if mt.table.Field(name).IsPrimaryKey() {
+			dbPks = append(dbPks, name)
+		}
+	}
+	ormPks := keyFields(mt.r.PrimaryKey)
+	if sameNames(dbPks, ormPks) {
+		return
+	}
+	mt.destructive("primary key %v -> %v", dbPks, ormPks)
+	up := "DROP PRIMARY KEY, ADD PRIMARY KEY (" + quoteNames(ormPks) + ")"
+	down := "DROP PRIMARY KEY, ADD PRIMARY KEY (" + quoteNames(dbPks) + ")"
+	if len(dbPks) == 0 {
+		up = "ADD PRIMARY KEY (" + quoteNames(ormPks) + ")"
+		down = "DROP PRIMARY KEY"
+	}
+	mt.alter(up, down)
+}
+
+func (mt *migrateTable) indexes() {
+	dbIdxes := mt.table.GetIndexes()
+	matched := make(map[*rdb.Index]bool, len(dbIdxes))
+	// The matched index is consumed, the duplicate keys of
+	// the declarations do not share one index.
+	find := func(unique bool, names []string) *rdb.Index {
+		for _, idx := range dbIdxes {
+			if matched[idx] {
+				continue
+			}
+			if idx.Unique == unique && sameNames(idx.Fields, names) {
+				return idx
+			}
+		}
+		return nil
+	}
+
+	add := func(keys []*orm.Index, unique bool) {
+		kind := "INDEX"
+		if unique {
+			kind = "UNIQUE INDEX"
+		}
+		for _, key := range keys {
+			names := keyFields(key)
+			if idx := find(unique, names); idx != nil {
+				matched[idx] = true
+				continue
+			}
+			name := keyName(mt.r, key, unique)
+			mt.alter(fmt.Sprintf("ADD %s `%s`(%s)", kind, name,
+				quoteNames(names)), fmt.Sprintf("DROP INDEX `%s`", name))
+		}
+	}
+	add(mt.r.UniqueKeys, true)
+	add(mt.r.Indexes, false)
+
+	for _, idx := range dbIdxes {
+		if matched[idx] {
+			continue
+		}
+		kind := "INDEX"
+		if idx.Unique {
+			kind = "UNIQUE INDEX"
+		}
+		mt.destructive(`drop %s "%s"`, strings.ToLower(kind), idx.Name)
+		mt.alter(fmt.Sprintf("DROP INDEX `%s`", idx.Name),
+			fmt.Sprintf("ADD %s `%s`(%s)", kind, idx.Name,
+				quoteNames(idx.Fields)))
+	}
+}
+
+func (mt *migrateTable) comment() {
+	dbComment := mt.table.GetComment()
+	if mt.r.Comment == dbComment {
+		return
+	}
+	mt.alter("COMMENT "+quoteComment(mt.r.Comment),
+		"COMMENT "+quoteComment(dbComment))
+}
+
+// dbColumnDef returns the definition of the field
+// described from the database.
+func dbColumnDef(f rdb.Field) string {
+	sb := new(stringBuilder)
+	sb.Append("`" + f.GetName() + "`")
+	sb.Append(strings.ToLower(f.GetType()))
+	if !f.IsNullable() {
+		sb.Append("NOT NULL")
+	}
+	if def := f.GetDefault(); def != "" {
+		sb.Append("DEFAULT " + def)
+	}
+	if f.IsAutoIncr() {
+		sb.Append("AUTO_INCREMENT")
+	}
+	if comment := f.GetComment(); comment != "" {
+		sb.Append("COMMENT " + quoteComment(comment))
+	}
+	return sb.Get()
+}
+
+func sameColumn(f *orm.Field, isId bool, dbField rdb.Field) bool {
+	if !sameType(f.DbType, dbField.GetType()) {
+		return false
+	}
+	if (f.NotNull || isId) == dbField.IsNullable() {
+		return false
+	}
+	if f.AutoIncr != dbField.IsAutoIncr() {
+		return false
+	}
+	if f.Comment != dbField.GetComment() {
+		return false
+	}
+	// Only the explicit default is compared, the implicit
+	// one is decided by the database.
+	if f.Default != "" && !sameDefault(f.Default, dbField.GetDefault()) {
+		return false
+	}
+	return true
+}
+
+// The display width of integer types is ignored, since
+// it is removed since MySQL 8.0.
+var intWidthRe = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|integer|bigint)\(\d+\)`)
+
+func normColumnType(t string) string {
+	t = strings.ToLower(strings.Join(strings.Fields(t), " "))
+	t = intWidthRe.ReplaceAllString(t, "$1")
+	t = strings.Replace(t, "integer", "int", 1)
+	switch t {
+	case "bool", "boolean":
+		return "tinyint"
+	}
+	return t
+}
+
+func sameType(a, b string) bool {
+	return normColumnType(a) == normColumnType(b)
+}
+
+func sameDefault(a, b string) bool {
+	a = strings.Trim(a, "'")
+	b = strings.Trim(b, "'")
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && fa == fb
+}
+
+func keyFields(key *orm.Index) []string {
+	names := make([]string, len(key.Fields))
+	for idx, f := range key.Fields {
+		names[idx] = f.DbName
+	}
+	return names
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for idx, name := range names {
+		quoted[idx] = "`" + name + "`"
+	}
+	return strings.Join(quoted, ",")
+}
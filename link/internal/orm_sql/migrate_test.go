@@ -0,0 +1,206 @@
+package orm_sql
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fioncat/go-gendb/compile/golang"
+	"github.com/fioncat/go-gendb/compile/orm"
+	"github.com/fioncat/go-gendb/database/rdb"
+)
+
+// doMigrate describes the tables from the DDL, and migrates
+// them to the orm declarations of the Go source.
+func doMigrate(t *testing.T, ddl, src string) *Migration {
+	dir, err := ioutil.TempDir("", "gendb-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "create.sql")
+	err = ioutil.WriteFile(path, []byte(ddl), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rdb.InitSchema(path, "mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src = "// +gen:orm-sql v=0.3\npackage user\n" + src
+	file, err := golang.ReadLines("user.go", strings.Split(src, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := orm.Parse(file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Migrate(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func expectList(t *testing.T, name string, got, expect []string) {
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("%s:\n got:\n%s\nwant:\n%s", name,
+			strings.Join(got, "\n"), strings.Join(expect, "\n"))
+	}
+}
+
+func TestMigrateNoChange(t *testing.T) {
+	m := doMigrate(t, "CREATE TABLE `user` (\n"+
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(64) NOT NULL DEFAULT '',\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_name` (`name`)\n"+
+		");", `
+// +gen:orm table=user
+type User struct {
+	// +gen:orm flags=[auto-incr,primary]
+	Id int64
+
+	// +gen:orm type=varchar(64) flags=[notnull,index]
+	// +gen:orm default="''"
+	Name string
+}
+`)
+	expectList(t, "up", m.Up, nil)
+	expectList(t, "down", m.Down, nil)
+	expectList(t, "destructive", m.Destructive, nil)
+}
+
+func TestMigrateCreate(t *testing.T) {
+	m := doMigrate(t, "CREATE TABLE `other` (`id` bigint);", `
+// +gen:orm table=user
+type User struct {
+	// +gen:orm flags=[auto-incr,primary]
+	Id int64
+}
+`)
+	if len(m.Up) != 1 || !strings.HasPrefix(m.Up[0], "CREATE TABLE") {
+		t.Fatalf("unexpected up: %v", m.Up)
+	}
+	expectList(t, "down", m.Down, []string{"DROP TABLE `user`;"})
+	expectList(t, "destructive", m.Destructive, nil)
+}
+
+func TestMigrateColumns(t *testing.T) {
+	m := doMigrate(t, "CREATE TABLE `user` (\n"+
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(32) NOT NULL,\n"+
+		"  `age` int,\n"+
+		"  `phone` varchar(11),\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		");", `
+// +gen:orm table=user
+type User struct {
+	// +gen:orm flags=[auto-incr,primary]
+	Id int64
+
+	// +gen:orm type=varchar(64) flags=[notnull]
+	Name string
+
+	Email string
+
+	// +gen:orm type=bigint
+	Age int64
+}
+`)
+	expectList(t, "up", m.Up, []string{
+		"ALTER TABLE `user` MODIFY COLUMN `name` varchar(64) NOT NULL DEFAULT '';",
+		"ALTER TABLE `user` ADD COLUMN `email` varchar(256) DEFAULT NULL AFTER `name`;",
+		"ALTER TABLE `user` MODIFY COLUMN `age` bigint DEFAULT NULL;",
+		"ALTER TABLE `user` DROP COLUMN `phone`;",
+	})
+	expectList(t, "down", m.Down, []string{
+		"ALTER TABLE `user` ADD COLUMN `phone` varchar(11) AFTER `age`;",
+		"ALTER TABLE `user` MODIFY COLUMN `age` int;",
+		"ALTER TABLE `user` DROP COLUMN `email`;",
+		"ALTER TABLE `user` MODIFY COLUMN `name` varchar(32) NOT NULL;",
+	})
+	expectList(t, "destructive", m.Destructive, []string{
+		`table "user": field "name" type varchar(32) -> varchar(64)`,
+		`table "user": field "age" type int -> bigint`,
+		`table "user": drop field "phone"`,
+	})
+}
+
+func TestMigratePrimaryKey(t *testing.T) {
+	m := doMigrate(t, "CREATE TABLE `user` (\n"+
+		"  `id` bigint NOT NULL,\n"+
+		"  `code` varchar(32) NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		");", `
+// +gen:orm table=user primary=[Id,Code]
+type User struct {
+	// +gen:orm flags=[notnull]
+	Id int64
+
+	// +gen:orm type=varchar(32) flags=[notnull]
+	Code string
+}
+`)
+	expectList(t, "up", m.Up, []string{
+		"ALTER TABLE `user` DROP PRIMARY KEY, ADD PRIMARY KEY (`id`,`code`);",
+	})
+	expectList(t, "down", m.Down, []string{
+		"ALTER TABLE `user` DROP PRIMARY KEY, ADD PRIMARY KEY (`id`);",
+	})
+	expectList(t, "destructive", m.Destructive, []string{
+		`table "user": primary key [id] -> [id code]`,
+	})
+}
+
+func TestMigrateIndexes(t *testing.T) {
+	m := doMigrate(t, "CREATE TABLE `user` (\n"+
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(64) NOT NULL,\n"+
+		"  `phone` varchar(11) NOT NULL,\n"+
+		"  `code` varchar(32) NOT NULL,\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_name` (`name`),\n"+
+		"  KEY `idx_phone` (`phone`),\n"+
+		"  UNIQUE KEY `uk_phone` (`phone`)\n"+
+		");", `
+// +gen:orm table=user index=[Name,Phone],[Name]
+type User struct {
+	// +gen:orm flags=[auto-incr,primary]
+	Id int64
+
+	// +gen:orm type=varchar(64) flags=[notnull,index]
+	Name string
+
+	// +gen:orm type=varchar(11) flags=[notnull]
+	Phone string
+
+	// +gen:orm type=varchar(32) flags=[notnull,unique]
+	Code string
+}
+`)
+	// The index of "name" is declared twice, only one of
+	// them matches "idx_name", the other is added.
+	expectList(t, "up", m.Up, []string{
+		"ALTER TABLE `user` ADD UNIQUE INDEX `unique_user_Code`(`code`);",
+		"ALTER TABLE `user` ADD INDEX `index_user_Name_Phone`(`name`,`phone`);",
+		"ALTER TABLE `user` ADD INDEX `index_user_Name`(`name`);",
+		"ALTER TABLE `user` DROP INDEX `idx_phone`;",
+		"ALTER TABLE `user` DROP INDEX `uk_phone`;",
+	})
+	expectList(t, "down", m.Down, []string{
+		"ALTER TABLE `user` ADD UNIQUE INDEX `uk_phone`(`phone`);",
+		"ALTER TABLE `user` ADD INDEX `idx_phone`(`phone`);",
+		"ALTER TABLE `user` DROP INDEX `index_user_Name`;",
+		"ALTER TABLE `user` DROP INDEX `index_user_Name_Phone`;",
+		"ALTER TABLE `user` DROP INDEX `unique_user_Code`;",
+	})
+	expectList(t, "destructive", m.Destructive, []string{
+		`table "user": drop index "idx_phone"`,
+		`table "user": drop unique index "uk_phone"`,
+	})
+}
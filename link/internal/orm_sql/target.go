@@ -6,8 +6,35 @@ import (
 
 	"github.com/fioncat/go-gendb/coder"
 	"github.com/fioncat/go-gendb/compile/orm"
+	"github.com/fioncat/go-gendb/database/rdb"
 )
 
+// findLock is a locking read of FindById. The locks are
+// only held in transaction (see run.WithTx).
+type findLock struct {
+	suffix string
+	sql    string
+}
+
+// findLocks returns the FindById and its locking reads
+// supported by the database type. sqlite has no locking
+// read.
+func findLocks(dbType string) []findLock {
+	locks := []findLock{{"", ""}}
+	switch dbType {
+	case "mysql":
+		locks = append(locks,
+			findLock{"ForUpdate", " FOR UPDATE"},
+			findLock{"LockInShareMode", " LOCK IN SHARE MODE"})
+
+	case "postgres":
+		locks = append(locks,
+			findLock{"ForUpdate", " FOR UPDATE"},
+			findLock{"ForShare", " FOR SHARE"})
+	}
+	return locks
+}
+
 type target struct {
 	coder.NoStructNum
 	coder.NoFuncNum
@@ -18,6 +45,12 @@ type target struct {
 
 	conf map[string]string
 
+	// the max number of placeholders in one statement.
+	maxPh int
+
+	// the database type of the struct, see rdb.ConnType.
+	dbType string
+
 	operName string
 	operType string
 }
@@ -35,6 +68,14 @@ func (t *target) Imports(ic *coder.Import) {
 	ic.Add(t.conf[runName], t.conf[runPath])
 	ic.Add("", "strings")
 	ic.Add("", "fmt")
+	if t.context() {
+		ic.Add("", "context")
+	}
+	for _, f := range t.r.Fields {
+		if name, path := rdb.TypeImport(f.GoType); path != "" {
+			ic.Add(name, path)
+		}
+	}
 }
 
 func (t *target) Vars(c *coder.Var, ic *coder.Import) {
@@ -94,9 +135,11 @@ func (t *target) Consts(c *coder.Var, ic *coder.Import) {
 		idMap[f.DbName] = struct{}{}
 	}
 	idCond := strings.Join(ids, " AND ")
-	name = fmt.Sprintf("_%s_FindById", t.r.Name)
-	sql = fmt.Sprintf("%s WHERE %s", selectSql, idCond)
-	gp.Add(name, coder.Quote(sql))
+	for _, lock := range findLocks(t.dbType) {
+		name = fmt.Sprintf("_%s_FindById%s", t.r.Name, lock.suffix)
+		sql = fmt.Sprintf("%s WHERE %s%s", selectSql, idCond, lock.sql)
+		gp.Add(name, coder.Quote(sql))
+	}
 
 	name = fmt.Sprintf("_%s_DeleteById", t.r.Name)
 	sql = fmt.Sprintf("%s WHERE %s", deleteSql, idCond)
@@ -169,9 +212,6 @@ func (t *target) Structs(sg *coder.StructGroup) {
 }
 
 func (t *target) Funcs(fg *coder.FunctionGroup) {
-	dbUse := t.conf[dbUse]
-	runUse := t.conf[runName]
-
 	idParams := make([]string, len(t.r.PrimaryKey.Fields))
 	idNames := make([]string, len(t.r.PrimaryKey.Fields))
 	idUpdate := make([]string, len(t.r.PrimaryKey.Fields))
@@ -204,41 +244,67 @@ func (t *target) Funcs(fg *coder.FunctionGroup) {
 	f := fg.Add()
 	t.funcDef(f, "Insert", []string{"o *" + t.r.Name}, "sql.Result")
 	sqlName := fmt.Sprintf("_%s_InsertOne", t.r.Name)
-	f.P(0, "return run.Exec(", dbUse, ", ", sqlName,
+	f.P(0, "return ", t.call("Exec", "Insert"), sqlName,
 		", nil, []interface{}{", strings.Join(insertParams, ", "), "})")
 
 	// InsertBatch
 	f = fg.Add()
 	t.funcDef(f, "InsertBatch", []string{"os []*" + t.r.Name}, "sql.Result")
 	sqlName = fmt.Sprintf("_%s_InsertBatch", t.r.Name)
-	f.P(0, "vs := make([]interface{}, 0, ", len(t.r.Fields), "*len(os))")
-	f.P(0, "valStrs := make([]string, len(os))")
-	f.P(0, "for idx, o := range os {")
-	f.P(1, "valStrs[idx] = _", t.r.Name, "_InsertValues")
-	f.P(1, "vs = append(vs, ", strings.Join(insertParams, ", "), ")")
+	// Split the rows so that the placeholders of each
+	// statement do not exceed the limit.
+	batch := 1
+	if len(insertParams) > 0 {
+		batch = t.maxPh / len(insertParams)
+	}
+	if batch <= 0 {
+		batch = 1
+	}
+	f.P(0, "var rs ", t.conf[runName], ".Results")
+	f.P(0, "err := ", t.conf[runName], ".Chunk(len(os), ", batch,
+		", func(start, end int) error {")
+	f.P(1, "chunk := os[start:end]")
+	f.P(1, "vs := make([]interface{}, 0, ", len(t.r.Fields), "*len(chunk))")
+	f.P(1, "valStrs := make([]string, len(chunk))")
+	f.P(1, "for idx, o := range chunk {")
+	f.P(2, "valStrs[idx] = _", t.r.Name, "_InsertValues")
+	f.P(2, "vs = append(vs, ", strings.Join(insertParams, ", "), ")")
+	f.P(1, "}")
+	f.P(1, "valStr := strings.Join(valStrs, ", coder.Quote(", "), ")")
+	f.P(1, "_sql := fmt.Sprintf(", sqlName, ", valStr)")
+	f.P(1, "r, err := ", t.call("Exec", "InsertBatch"), "_sql, nil, vs)")
+	f.P(1, "if err != nil {")
+	f.P(2, "return err")
+	f.P(1, "}")
+	f.P(1, "rs = append(rs, r)")
+	f.P(1, "return nil")
+	f.P(0, "})")
+	f.P(0, "if err != nil {")
+	f.P(1, "return nil, err")
 	f.P(0, "}")
-	f.P(0, "valStr := strings.Join(valStrs, ", coder.Quote(", "), ")")
-	f.P(0, "_sql := fmt.Sprintf(", sqlName, ", valStr)")
-	f.P(0, "return run.Exec(", dbUse, ", _sql, nil, vs)")
+	f.P(0, "return rs, nil")
 
-	// FindById
-	f = fg.Add()
-	t.funcDef(f, "FindById", idParams, "*"+t.r.Name)
-	sqlName = fmt.Sprintf("_%s_FindById", t.r.Name)
-	f.P(0, "var o *", t.r.Name)
-	f.P(0, "err := ", runUse, ".QueryOne(", dbUse, ", ", sqlName,
-		", nil, []interface{}{", strings.Join(idNames, ", "),
-		"}, func(rows *sql.Rows) error {")
-	f.P(1, "o = new(", t.r.Name, ")")
-	f.P(1, "return rows.Scan(", strings.Join(selectFields, ", "), ")")
-	f.P(0, "})")
-	f.P(0, "return o, err")
+	// FindById and the locking reads, see findLocks
+	for _, lock := range findLocks(t.dbType) {
+		name := "FindById" + lock.suffix
+		f = fg.Add()
+		t.funcDef(f, name, idParams, "*"+t.r.Name)
+		sqlName = fmt.Sprintf("_%s_%s", t.r.Name, name)
+		f.P(0, "var o *", t.r.Name)
+		f.P(0, "err := ", t.call("QueryOne", name), sqlName,
+			", nil, []interface{}{", strings.Join(idNames, ", "),
+			"}, func(rows *sql.Rows) error {")
+		f.P(1, "o = new(", t.r.Name, ")")
+		f.P(1, "return rows.Scan(", strings.Join(selectFields, ", "), ")")
+		f.P(0, "})")
+		f.P(0, "return o, err")
+	}
 
 	// DeleteById
 	f = fg.Add()
 	t.funcDef(f, "DeleteById", idParams, "sql.Result")
 	sqlName = fmt.Sprintf("_%s_DeleteById", t.r.Name)
-	f.P(0, "return run.Exec(", dbUse, ", ", sqlName,
+	f.P(0, "return ", t.call("Exec", "DeleteById"), sqlName,
 		", nil, []interface{}{", strings.Join(idNames, ", "), "})")
 
 	// UpdateById
@@ -246,7 +312,7 @@ func (t *target) Funcs(fg *coder.FunctionGroup) {
 	t.funcDef(f, "UpdateById", []string{"o *" + t.r.Name}, "sql.Result")
 	params := append(updateParams, idUpdate...)
 	sqlName = fmt.Sprintf("_%s_UpdateById", t.r.Name)
-	f.P(0, "return run.Exec(", dbUse, ", ", sqlName,
+	f.P(0, "return ", t.call("Exec", "UpdateById"), sqlName,
 		", nil, []interface{}{", strings.Join(params, ", "), "})")
 
 	// Count
@@ -254,7 +320,7 @@ func (t *target) Funcs(fg *coder.FunctionGroup) {
 	t.funcDef(f, "Count", []string{}, "int64")
 	sqlName = fmt.Sprintf("_%s_Count", t.r.Name)
 	f.P(0, "var cnt int64")
-	f.P(0, "err := run.QueryOne(", dbUse, ", ", sqlName, ", nil, nil, func(rows *sql.Rows) error {")
+	f.P(0, "err := ", t.call("QueryOne", "Count"), sqlName, ", nil, nil, func(rows *sql.Rows) error {")
 	f.P(1, "return rows.Scan(&cnt)")
 	f.P(0, "})")
 	f.P(0, "return cnt, err")
@@ -274,7 +340,7 @@ func (t *target) Funcs(fg *coder.FunctionGroup) {
 		f = fg.Add()
 		t.funcDef(f, name, []string{param}, "*"+t.r.Name)
 		f.P(0, "var o *", t.r.Name)
-		f.P(0, "err := run.QueryOne(", dbUse, ", ", sqlName,
+		f.P(0, "err := ", t.call("QueryOne", name), sqlName,
 			", nil, []interface{}{", paramName, "}, func(rows *sql.Rows) error {")
 		f.P(1, "o = new(", t.r.Name, ")")
 		f.P(1, "return rows.Scan(", strings.Join(selectFields, ", "), ")")
@@ -303,7 +369,7 @@ func (t *target) Funcs(fg *coder.FunctionGroup) {
 		f = fg.Add()
 		t.funcDef(f, name, []string{param}, "[]*"+t.r.Name)
 		f.P(0, "var os []*", t.r.Name)
-		f.P(0, "err := run.QueryMany(", dbUse, ", ", sqlName,
+		f.P(0, "err := ", t.call("QueryMany", name), sqlName,
 			", nil, []interface{}{", paramName, "}, func(rows *sql.Rows) error {")
 		f.P(1, "o := new(", t.r.Name, ")")
 		f.P(1, "err := rows.Scan(", strings.Join(selectFields, ", "), ")")
@@ -320,17 +386,38 @@ func (t *target) Funcs(fg *coder.FunctionGroup) {
 
 func (t *target) funcDef(f *coder.Function, name string, params []string, ret string) {
 	dbUse := t.conf[dbUse]
-	def := fmt.Sprintf("(*%s) %s(", t.operType, name)
-	if dbUse == "db" {
-		def += "db run.IDB"
+	var head []string
+	if t.context() {
+		head = append(head, "ctx context.Context")
 	}
-	if len(params) > 0 {
-		if dbUse == "db" {
-			def += ", "
+	if dbUse == "db" {
+		if t.context() {
+			head = append(head, "db "+t.conf[runName]+".IDBContext")
+		} else {
+			head = append(head, "db "+t.conf[runName]+".IDB")
 		}
-		def += strings.Join(params, ", ")
 	}
+	params = append(head, params...)
+	def := fmt.Sprintf("(*%s) %s(", t.operType, name)
+	def += strings.Join(params, ", ")
 	def += ") "
 	def += fmt.Sprintf("(%s, error)", ret)
 	f.Def(name, def)
 }
+
+func (t *target) context() bool {
+	return t.conf[useContext] == "true"
+}
+
+// call returns the beginning of calling the "*Named" run
+// function, including the db and the query name
+// ("{Oper}.{Method}") arguments.
+func (t *target) call(name, method string) string {
+	queryName := coder.Quote(t.operName + "." + method)
+	if t.context() {
+		return fmt.Sprintf("%s.%sNamedContext(ctx, %s, %s, ", t.conf[runName],
+			name, t.conf[dbUse], queryName)
+	}
+	return fmt.Sprintf("%s.%sNamed(%s, %s, ", t.conf[runName], name,
+		t.conf[dbUse], queryName)
+}
@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"bufio"
+	"bytes"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/fioncat/go-gendb/misc/log"
+)
+
+// checkImporter imports the packages for the type check. The
+// packages are read from the export data in the build cache,
+// which is found by "go list -export", so the dependencies
+// are not type checked from source again. It is shared by
+// all the files in one run, each package is loaded once.
+//
+// If the export data of a package can not be found (such as
+// "go" is not in PATH, or the package fails to build), the
+// package is imported from source.
+type checkImporter struct {
+	fset *token.FileSet
+
+	// the export data files, the key is the import path.
+	exports map[string]string
+
+	// the directories listed.
+	dirs map[string]bool
+
+	gc  types.Importer
+	src types.Importer
+}
+
+var sharedImporter *checkImporter
+
+// getImporter returns the shared importer, the dependencies
+// of the package in dir are listed if it is not listed yet.
+func getImporter(dir string) *checkImporter {
+	imp := sharedImporter
+	if imp == nil {
+		imp = &checkImporter{
+			fset:    token.NewFileSet(),
+			exports: make(map[string]string),
+			dirs:    make(map[string]bool),
+		}
+		imp.gc = importer.ForCompiler(imp.fset, "gc", imp.lookup)
+		imp.src = importer.ForCompiler(imp.fset, "source", nil)
+		sharedImporter = imp
+	}
+	if !imp.dirs[dir] {
+		imp.dirs[dir] = true
+		imp.list(dir)
+	}
+	return imp
+}
+
+// list finds the export data of the dependencies of the
+// package in dir. The errors are ignored, the packages not
+// found are imported from source.
+func (imp *checkImporter) list(dir string) {
+	start := time.Now()
+	cmd := exec.Command("go", "list", "-e", "-export", "-deps",
+		"-f", "{{.ImportPath}}={{.Export}}", ".")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		log.Infof("[link] [sql] go list failed, import "+
+			"from source: %v", err)
+		return
+	}
+	var cnt int
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		idx := strings.LastIndex(line, "=")
+		if idx <= 0 || idx == len(line)-1 {
+			continue
+		}
+		imp.exports[line[:idx]] = line[idx+1:]
+		cnt++
+	}
+	log.Infof("[link] [sql] [%v] list %s, %d export(s)",
+		time.Since(start), dir, cnt)
+}
+
+func (imp *checkImporter) lookup(path string) (io.ReadCloser, error) {
+	return os.Open(imp.exports[path])
+}
+
+func (imp *checkImporter) Import(path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if imp.exports[path] != "" {
+		pkg, err := imp.gc.Import(path)
+		if err == nil {
+			return pkg, nil
+		}
+	}
+	return imp.src.Import(path)
+}
@@ -0,0 +1,151 @@
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fioncat/go-gendb/coder"
+	"github.com/fioncat/go-gendb/compile/golang"
+	"github.com/fioncat/go-gendb/compile/sql"
+)
+
+// inPredRe matches the IN predicate before the placeholder
+// of the slice, such as "id IN", "`u`.`id` NOT IN".
+var inPredRe = regexp.MustCompile("(?i)([\\w.`\"]+\\s+(NOT\\s+)?IN)\\s*$")
+
+// inList is the prepare placeholder of the slice parameter,
+// it is expanded to "(?,?,?)" when executing, see run.In.
+type inList struct {
+	name string
+
+	// the predicate before the list, such as "id IN", it is
+	// replaced by empty if the slice is empty. If the
+	// predicate is not found, it is empty, and the list is
+	// replaced by "(NULL)".
+	pred  string
+	empty string
+}
+
+// inSplit is the sql split by the in lists, the in lists
+// are between the sqls.
+type inSplit struct {
+	sqls []string
+	ins  []*inList
+
+	// the const names of the sqls, empty for the empty sql.
+	names []string
+}
+
+// listParams returns the names of the slice parameters, the
+// prepare placeholders of them are expanded. []byte is not
+// included, which is a single value.
+func listParams(goMethod *golang.Method) map[string]bool {
+	lists := make(map[string]bool)
+	for _, param := range goMethod.Params {
+		switch {
+		case param.Type == "[]byte", param.Type == "[]uint8":
+
+		case strings.HasPrefix(param.Type, "[]"),
+			strings.HasPrefix(param.Type, "..."):
+			lists[param.Name] = true
+		}
+	}
+	return lists
+}
+
+// splitIn splits the sql by the prepare placeholders of the
+// slice parameters, returns nil if there is none. The parens
+// around the placeholder ("IN (${ids})") are removed, since
+// run.In adds them.
+func splitIn(state *sql.Statement, lists map[string]bool, empty string) *inSplit {
+	split := new(inSplit)
+	last := 0
+	for idx, name := range state.Prepares {
+		if !lists[name] {
+			continue
+		}
+		pos := state.PreparePos[idx]
+		before := state.Sql[last:pos]
+		next := pos + 1
+
+		trimmed := strings.TrimRightFunc(before, isSpace)
+		rest := state.Sql[next:]
+		restTrimmed := strings.TrimLeftFunc(rest, isSpace)
+		if strings.HasSuffix(trimmed, "(") &&
+			strings.HasPrefix(restTrimmed, ")") {
+			before = trimmed[:len(trimmed)-1]
+			next += len(rest) - len(restTrimmed) + 1
+		}
+
+		in := &inList{name: name, empty: "(NULL)"}
+		if loc := inPredRe.FindStringSubmatchIndex(before); loc != nil {
+			in.pred = before[loc[2]:loc[3]]
+			in.empty = empty
+			if loc[4] >= 0 {
+				in.empty = "NOT (" + empty + ")"
+			}
+			before = before[:loc[2]]
+		}
+		split.sqls = append(split.sqls, before)
+		split.ins = append(split.ins, in)
+		last = next
+	}
+	if len(split.ins) == 0 {
+		return nil
+	}
+	split.sqls = append(split.sqls, state.Sql[last:])
+	return split
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// expr returns the Go expression to build the sql.
+func (split *inSplit) expr(runName string) string {
+	var parts []string
+	for idx, name := range split.names {
+		if name != "" {
+			parts = append(parts, name)
+		}
+		if idx >= len(split.ins) {
+			break
+		}
+		in := split.ins[idx]
+		parts = append(parts, fmt.Sprintf("%s.In(%s, len(%s), %s)",
+			runName, strconv.Quote(in.pred), in.name,
+			strconv.Quote(in.empty)))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// appendVals generates the code appending the values of the
+// statement to "pvs" and "rvs", the elements of the slice
+// parameters are appended one by one.
+func appendVals(nTab int, c *coder.Function, state *sql.Statement,
+	lists map[string]bool) {
+	var vals []string
+	flush := func() {
+		if len(vals) > 0 {
+			c.P(nTab, "pvs = append(pvs, ", strings.Join(vals, ", "), ")")
+			vals = nil
+		}
+	}
+	for _, name := range state.Prepares {
+		if !lists[name] {
+			vals = append(vals, name)
+			continue
+		}
+		flush()
+		c.P(nTab, "for _, v := range ", name, " {")
+		c.P(nTab+1, "pvs = append(pvs, v)")
+		c.P(nTab, "}")
+	}
+	flush()
+	if len(state.Replaces) > 0 {
+		c.P(nTab, "rvs = append(rvs, ",
+			strings.Join(state.Replaces, ", "), ")")
+	}
+}
@@ -0,0 +1,133 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fioncat/go-gendb/compile/sql"
+)
+
+// newState creates the statement, the "?" in the sql are the
+// prepare placeholders of the names in order.
+func newState(s string, names ...string) *sql.Statement {
+	state := &sql.Statement{Sql: s, Prepares: names}
+	for idx, r := range s {
+		if r == '?' {
+			state.PreparePos = append(state.PreparePos, idx)
+		}
+	}
+	return state
+}
+
+// format shows the in lists of the split as "{pred|empty}".
+func (split *inSplit) format() string {
+	var sb strings.Builder
+	for idx, s := range split.sqls {
+		sb.WriteString(s)
+		if idx < len(split.ins) {
+			in := split.ins[idx]
+			fmt.Fprintf(&sb, "{%s|%s}", in.pred, in.empty)
+		}
+	}
+	return sb.String()
+}
+
+func TestSplitIn(t *testing.T) {
+	lists := map[string]bool{"ids": true, "names": true}
+	cases := []struct {
+		state  *sql.Statement
+		expect string
+	}{
+		{
+			newState("SELECT * FROM user WHERE id IN ?", "ids"),
+			"SELECT * FROM user WHERE {id IN|1=0}",
+		},
+		{
+			newState("SELECT * FROM user WHERE id IN (?) AND age=?", "ids", "age"),
+			"SELECT * FROM user WHERE {id IN|1=0} AND age=?",
+		},
+		{
+			newState("DELETE FROM user WHERE id NOT IN ( ? )", "ids"),
+			"DELETE FROM user WHERE {id NOT IN|NOT (1=0)}",
+		},
+		{
+			newState("SELECT * FROM user WHERE id not\nin\t?", "ids"),
+			"SELECT * FROM user WHERE {id not\nin|NOT (1=0)}",
+		},
+		{
+			newState("SELECT * FROM user u WHERE u.id IN (?)", "ids"),
+			"SELECT * FROM user u WHERE {u.id IN|1=0}",
+		},
+		{
+			newState("SELECT * FROM `user` u WHERE `u`.`id` IN (?)", "ids"),
+			"SELECT * FROM `user` u WHERE {`u`.`id` IN|1=0}",
+		},
+		{
+			newState(`SELECT * FROM "user" u WHERE "u"."id" IN ?`, "ids"),
+			`SELECT * FROM "user" u WHERE {"u"."id" IN|1=0}`,
+		},
+		{
+			newState("SELECT * FROM user WHERE id IN (?) OR name IN (?)", "ids", "names"),
+			"SELECT * FROM user WHERE {id IN|1=0} OR {name IN|1=0}",
+		},
+		{
+			// No predicate, the list is replaced by "(NULL)"
+			// if it is empty.
+			newState("SELECT * FROM user ORDER BY FIELD(id, ?)", "ids"),
+			"SELECT * FROM user ORDER BY FIELD(id, {|(NULL)})",
+		},
+		{
+			newState("SELECT * FROM user WHERE id = ANY(?)", "ids"),
+			"SELECT * FROM user WHERE id = ANY{|(NULL)}",
+		},
+	}
+	for _, c := range cases {
+		split := splitIn(c.state, lists, "1=0")
+		if split == nil {
+			t.Fatalf("%q: no in list", c.state.Sql)
+		}
+		got := split.format()
+		fmt.Printf("%q\n  -> %q\n", c.state.Sql, got)
+		if got != c.expect {
+			t.Fatalf("got %q, expect %q", got, c.expect)
+		}
+	}
+
+	state := newState("SELECT * FROM user WHERE id=? AND data=?", "id", "data")
+	if split := splitIn(state, lists, "1=0"); split != nil {
+		t.Fatalf("%q: unexpected in list %q", state.Sql, split.format())
+	}
+}
+
+func TestInPredRe(t *testing.T) {
+	cases := []struct {
+		sql  string
+		pred string
+		not  bool
+	}{
+		{"WHERE id IN", "id IN", false},
+		{"WHERE id in ", "id in", false},
+		{"WHERE id NOT IN", "id NOT IN", true},
+		{"WHERE id Not  In\n", "id Not  In", true},
+		{"WHERE u.id IN", "u.id IN", false},
+		{"WHERE `u`.`id` NOT IN", "`u`.`id` NOT IN", true},
+		{`WHERE "u"."id" IN`, `"u"."id" IN`, false},
+		{"WHERE id=", "", false},
+		{"SELECT * FROM user JOIN", "", false},
+		{"WHERE id IN (1) AND", "", false},
+	}
+	for _, c := range cases {
+		var pred string
+		var not bool
+		if loc := inPredRe.FindStringSubmatchIndex(c.sql); loc != nil {
+			pred = c.sql[loc[2]:loc[3]]
+			not = loc[4] >= 0
+		}
+		fmt.Printf("%q -> %q %v\n", c.sql, pred, not)
+		if pred != c.pred || not != c.not {
+			t.Fatalf("%q: got %q %v, expect %q %v", c.sql,
+				pred, not, c.pred, c.not)
+		}
+	}
+}
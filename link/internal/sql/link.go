@@ -3,6 +3,7 @@ package sql
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -17,16 +18,22 @@ import (
 type Linker struct{}
 
 const (
-	dbUse   = "db_use"
-	runPath = "run_path"
-	runName = "run_name"
+	dbUse     = "db_use"
+	runPath   = "run_path"
+	runName   = "run_name"
+	null      = "null"
+	typeCheck = "type_check"
+	emptyIn   = "empty_in"
 )
 
 func (*Linker) DefaultConf() map[string]string {
 	return map[string]string{
-		dbUse:   "db",
-		runPath: "github.com/fioncat/go-gendb/api/sql/run",
-		runName: "run",
+		dbUse:     "db",
+		runPath:   "github.com/fioncat/go-gendb/api/sql/run",
+		runName:   "run",
+		null:      rdb.NullPointer,
+		typeCheck: "true",
+		emptyIn:   "1=0",
 	}
 }
 
@@ -34,19 +41,29 @@ func (*Linker) Do(file *golang.File, conf map[string]string) (
 	[]coder.Target, error,
 ) {
 	start := time.Now()
+	err := rdb.CheckNull(conf[null])
+	if err != nil {
+		return nil, err
+	}
 	// Each tagged interface generate one target.
 	ts := make([]coder.Target, 0, len(file.Interfaces))
+	tc := newTypeChecker(file)
 	for _, inter := range file.Interfaces {
 		if inter.Tag.Name != "sql" {
 			continue
 		}
-		t, err := createTarget(file, inter)
+		t, err := createTarget(file, inter, conf, tc)
 		if err != nil {
 			return nil, err
 		}
-		t.conf = conf
 		ts = append(ts, t)
 	}
+	if conf[typeCheck] != "false" {
+		err = tc.check(file.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	log.Infof("[link] [sql] [%v] %s, %d target(s)",
 		time.Since(start), file.Path, len(ts))
@@ -54,15 +71,20 @@ func (*Linker) Do(file *golang.File, conf map[string]string) (
 	return ts, nil
 }
 
-func createTarget(file *golang.File, inter *golang.Interface) (
-	*target, error,
-) {
+func createTarget(file *golang.File, inter *golang.Interface,
+	conf map[string]string, tc *typeChecker) (*target, error) {
 	// import sql method(s)
 	sqlm0 := make(map[string]*sql.Method)
 	sqlm1 := make(map[string]*sql.Method)
+	// the sql file of each method, to report errors.
+	sqlFiles := make(map[*sql.Method]*sql.File)
 
 	var sqlPaths []string
 	var name string
+	// The session to describe tables for auto-ret, nil
+	// means the global one.
+	var sess *rdb.Session
+	var connOpt string
 	for _, opt := range inter.Tag.Options {
 		if opt.Value == "" {
 			continue
@@ -73,6 +95,14 @@ func createTarget(file *golang.File, inter *golang.Interface) (
 
 		case "file":
 			sqlPaths = append(sqlPaths, opt.Value)
+
+		case "conn":
+			connOpt = opt.Value
+			var err error
+			sess, err = rdb.Use(opt.Value)
+			if err != nil {
+				return nil, opt.Trace(err)
+			}
 		}
 	}
 	if name == "" {
@@ -90,6 +120,7 @@ func createTarget(file *golang.File, inter *golang.Interface) (
 		}
 		file := v.(*sql.File)
 		for _, m := range file.Methods {
+			sqlFiles[m] = file
 			if m.Inter == name {
 				sqlm0[m.Name] = m
 				continue
@@ -100,6 +131,8 @@ func createTarget(file *golang.File, inter *golang.Interface) (
 	t := new(target)
 	t.file = file
 	t.name = name
+	t.conf = conf
+	t.dbType = rdb.ConnType(connOpt)
 
 	t.importMap = make(map[string]*golang.Import)
 	for _, imp := range file.Imports {
@@ -122,9 +155,17 @@ func createTarget(file *golang.File, inter *golang.Interface) (
 			return nil, goMethod.FmtError(`can not `+
 				`find method "%s" in sql file`, goMethod.Name)
 		}
+		tc.add(goMethod, sqlFiles[sqlMethod], sqlMethod)
+
 		m := new(method)
 		m.sql = sqlMethod
 		m.base = goMethod
+		ctx, err := contextParam(t, goMethod)
+		if err != nil {
+			return nil, err
+		}
+		m.ctx = ctx
+		m.lists = listParams(goMethod)
 		if sqlMethod.Exec {
 			err := setExecMethodType(goMethod, m)
 			if err != nil {
@@ -138,6 +179,14 @@ func createTarget(file *golang.File, inter *golang.Interface) (
 			}
 		}
 
+		if sqlMethod.Chunk != "" {
+			err = checkChunk(goMethod, m)
+			if err != nil {
+				return nil, err
+			}
+			t.chunks = append(t.chunks, m)
+		}
+
 		isAutoRet := false
 		for _, tag := range goMethod.Tags {
 			if tag.Name == "auto-ret" {
@@ -146,7 +195,7 @@ func createTarget(file *golang.File, inter *golang.Interface) (
 			}
 		}
 		if isAutoRet {
-			ret, err := autoRet(goMethod, sqlMethod)
+			ret, err := autoRet(goMethod, sqlMethod, sess, conf[null])
 			if err != nil {
 				return nil, err
 			}
@@ -161,6 +210,94 @@ func createTarget(file *golang.File, inter *golang.Interface) (
 	return t, nil
 }
 
+// contextParam returns the name of the first parameter if
+// it is context.Context, the generated method passes it to
+// the database.
+func contextParam(t *target, goMethod *golang.Method) (string, error) {
+	if len(goMethod.Params) == 0 {
+		return "", nil
+	}
+	param := goMethod.Params[0]
+	idx := strings.Index(param.Type, ".")
+	if idx < 0 || param.Type[idx+1:] != "Context" {
+		return "", nil
+	}
+	imp := t.importMap[param.Type[:idx]]
+	if imp == nil || imp.Path != "context" {
+		return "", nil
+	}
+	if param.Name == "" || param.Name == "_" {
+		return "", goMethod.FmtError(`the context parameter `+
+			`of "%s" must be named`, goMethod.Name)
+	}
+	return param.Name, nil
+}
+
+// chunkUnsafeRe matches the clauses whose results change when
+// the statement is executed in chunks: the order, limit and
+// aggregation apply to each chunk rather than all the rows.
+var chunkUnsafeRe = regexp.MustCompile(`(?i)\b(ORDER\s+BY|LIMIT|OFFSET|` +
+	`GROUP\s+BY|HAVING|DISTINCT|UNION|` +
+	`(COUNT|SUM|AVG|MIN|MAX|GROUP_CONCAT|STRING_AGG)\s*\()`)
+
+// chunkQuoteRe matches the quoted strings and identifiers,
+// which are ignored by chunkUnsafeRe.
+var chunkQuoteRe = regexp.MustCompile("'[^']*'|\"[^\"]*\"|`[^`]*`")
+
+// chunkUnsafe returns the first clause of the sql of the
+// method that can not be executed in chunks, empty if none.
+func chunkUnsafe(m *sql.Method) string {
+	var sqls []string
+	if m.State != nil {
+		sqls = append(sqls, m.State.Sql)
+	}
+	sql.WalkDynamic(m.Dps, func(dp *sql.DynamicPart) {
+		if dp.State != nil {
+			sqls = append(sqls, dp.State.Sql)
+		}
+	})
+	for _, s := range sqls {
+		s = chunkQuoteRe.ReplaceAllString(s, "''")
+		if loc := chunkUnsafeRe.FindStringSubmatchIndex(s); loc != nil {
+			clause := strings.Join(strings.Fields(s[loc[2]:loc[3]]), " ")
+			return strings.ToUpper(strings.TrimRight(clause, "( "))
+		}
+	}
+	return ""
+}
+
+// checkChunk checks the "chunk" option of the method, the
+// chunk parameter must be a slice, and the results of the
+// chunks must be able to merge.
+func checkChunk(goMethod *golang.Method, m *method) error {
+	name := m.sql.Chunk
+	if !m.lists[name] {
+		return goMethod.FmtError(`chunk parameter "%s" `+
+			`is not a slice parameter of "%s"`, name, goMethod.Name)
+	}
+	for _, param := range goMethod.Params {
+		if param.Name == "" || param.Name == "_" {
+			return goMethod.FmtError(`the parameters of `+
+				`chunk method "%s" must be named`, goMethod.Name)
+		}
+	}
+	switch m.Type {
+	case queryOne:
+		return goMethod.FmtError(`chunk method "%s" `+
+			`must return slice`, goMethod.Name)
+
+	case execLastId:
+		return goMethod.FmtError(`chunk method "%s" `+
+			`do not support lastid`, goMethod.Name)
+	}
+	if clause := chunkUnsafe(m.sql); clause != "" {
+		return goMethod.FmtError(`chunk method "%s" can `+
+			`not be split, the result of "%s" is changed by `+
+			`chunks`, goMethod.Name, clause)
+	}
+	return nil
+}
+
 func setExecMethodType(goMethod *golang.Method, m *method) error {
 	var execType int
 	switch goMethod.RetType {
@@ -191,12 +328,14 @@ func setExecMethodType(goMethod *golang.Method, m *method) error {
 	return nil
 }
 
-func autoRet(goMethod *golang.Method, sqlMethod *sql.Method) (
-	*ret, error,
-) {
-	if err := rdb.MustInit(); err != nil {
-		return nil, goMethod.FmtError(`auto-ret ` +
-			`must set database connection`)
+func autoRet(goMethod *golang.Method, sqlMethod *sql.Method,
+	sess *rdb.Session, nullStrategy string) (*ret, error) {
+	if sess == nil {
+		if err := rdb.MustInit(); err != nil {
+			return nil, goMethod.FmtError(`auto-ret ` +
+				`must set database connection`)
+		}
+		sess = rdb.Get()
 	}
 	if sqlMethod.Exec {
 		return nil, goMethod.FmtError(`exec sql ` +
@@ -218,7 +357,7 @@ func autoRet(goMethod *golang.Method, sqlMethod *sql.Method) (
 	r.name = retName
 	r.fields = make([]*retField, len(sqlMethod.Fields))
 	for idx, queryField := range sqlMethod.Fields {
-		table, err := rdb.Get().Desc(queryField.Table)
+		table, err := sess.Desc(queryField.Table)
 		if err != nil {
 			return nil, goMethod.FmtError("desc "+
 				"table failed: %v", err)
@@ -238,7 +377,7 @@ func autoRet(goMethod *golang.Method, sqlMethod *sql.Method) (
 		if retField.name == "" {
 			retField.name = coder.GoName(queryField.Name)
 		}
-		fType := rdb.Get().GoType(dbField.GetType())
+		fType := sess.FieldType(dbField, nullStrategy)
 		retField._type = fType
 		retField.table = queryField.Table
 		retField.field = queryField.Name
@@ -0,0 +1,48 @@
+package sql
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fioncat/go-gendb/compile/sql"
+)
+
+func TestChunkUnsafe(t *testing.T) {
+	cases := []struct {
+		sql    string
+		expect string
+	}{
+		{"SELECT * FROM user WHERE id IN ?", ""},
+		{"UPDATE user SET is_delete=1 WHERE id IN ?", ""},
+		{"SELECT * FROM user WHERE id IN ? ORDER BY id", "ORDER BY"},
+		{"SELECT * FROM user WHERE id IN ? order\n  by id", "ORDER BY"},
+		{"SELECT * FROM user WHERE id IN ? LIMIT 10", "LIMIT"},
+		{"DELETE FROM user WHERE id IN ? LIMIT 10", "LIMIT"},
+		{"SELECT COUNT(1) FROM user WHERE id IN ?", "COUNT"},
+		{"SELECT max (age) FROM user WHERE id IN ?", "MAX"},
+		{"SELECT DISTINCT name FROM user WHERE id IN ?", "DISTINCT"},
+		{"SELECT name FROM user WHERE id IN ? GROUP BY name", "GROUP BY"},
+		{"SELECT id, max_age FROM user WHERE id IN ?", ""},
+		{"SELECT `limit`, \"order\" FROM user WHERE id IN ? AND name != 'a limit'", ""},
+	}
+	for _, c := range cases {
+		m := &sql.Method{State: &sql.Statement{Sql: c.sql}}
+		got := chunkUnsafe(m)
+		fmt.Printf("%q -> %q\n", c.sql, got)
+		if got != c.expect {
+			t.Fatalf("got %q, expect %q", got, c.expect)
+		}
+	}
+
+	m := &sql.Method{Dyn: true, Dps: []*sql.DynamicPart{
+		{Type: sql.DynamicTypeConst, State: &sql.Statement{
+			Sql: "SELECT * FROM user WHERE id IN ?"}},
+		{Type: sql.DynamicTypeIf, Children: []*sql.DynamicPart{
+			{Type: sql.DynamicTypeConst, State: &sql.Statement{
+				Sql: "ORDER BY id"}},
+		}},
+	}}
+	if got := chunkUnsafe(m); got != "ORDER BY" {
+		t.Fatalf("dynamic: got %q", got)
+	}
+}
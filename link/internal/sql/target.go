@@ -5,9 +5,11 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/fioncat/go-gendb/api/sql/run"
 	"github.com/fioncat/go-gendb/coder"
 	"github.com/fioncat/go-gendb/compile/golang"
 	"github.com/fioncat/go-gendb/compile/sql"
+	"github.com/fioncat/go-gendb/database/rdb"
 )
 
 type target struct {
@@ -22,8 +24,16 @@ type target struct {
 
 	name string
 
+	// the database type of the connection, the sql is
+	// rebound to "$n" for postgres.
+	dbType string
+
 	methods []*method
 
+	// the methods with "chunk" option, each of them has
+	// an extra function to split the slice parameter.
+	chunks []*method
+
 	rets []*ret
 }
 
@@ -57,7 +67,32 @@ type method struct {
 	sql  *sql.Method
 	base *golang.Method
 
+	// the name of the context parameter, empty if the
+	// method has no context.
+	ctx string
+
 	constName string
+
+	// the slice parameters, see listParams.
+	lists map[string]bool
+
+	// the sql split by the in lists, nil if there is none.
+	in *inSplit
+
+	// the const names of the const parts of the dynamic sql.
+	dpNames map[*sql.DynamicPart]string
+
+	// the const parts of the dynamic sql split by the in
+	// lists.
+	dpIns map[*sql.DynamicPart]*inSplit
+
+	// the number of the "where" and "set" blocks generated,
+	// to name the start index variables.
+	nBlock int
+
+	// the number of the "for" with join generated, to name
+	// the index variables.
+	nFor int
 }
 
 func (t *target) Name() string {
@@ -83,17 +118,68 @@ func (t *target) Consts(c *coder.Var, ic *coder.Import) {
 			t.name, m.base.Name)
 		m.constName = constName
 		if !m.sql.Dyn {
-			group.Add(constName,
-				coder.Quote(m.sql.State.Sql))
+			m.in = splitIn(m.sql.State, m.lists, t.conf[emptyIn])
+			if m.in == nil {
+				group.Add(constName,
+					coder.Quote(t.bind(m.sql.State.Sql)))
+				continue
+			}
+			var cnt int
+			t.inConsts(group, constName, m.in, &cnt)
 			continue
 		}
-		for idx, dp := range m.sql.Dps {
-			if dp.ForJoin != "" {
-				dp.State.Sql += dp.ForJoin
+		m.dpNames = make(map[*sql.DynamicPart]string)
+		m.dpIns = make(map[*sql.DynamicPart]*inSplit)
+		var cnt int
+		sql.WalkDynamic(m.sql.Dps, func(dp *sql.DynamicPart) {
+			if dp.Type != sql.DynamicTypeConst {
+				return
 			}
-			group.Add(constName+strconv.Itoa(idx),
-				coder.Quote(dp.State.Sql))
+			split := splitIn(dp.State, m.lists, t.conf[emptyIn])
+			if split != nil {
+				m.dpIns[dp] = split
+				t.inConsts(group, constName, split, &cnt)
+				return
+			}
+			name := constName + strconv.Itoa(cnt)
+			cnt++
+			m.dpNames[dp] = name
+			group.Add(name, coder.Quote(dp.State.Sql))
+		})
+	}
+}
+
+// bind converts the bind variables of the static sql for
+// postgres. The sql built when executing (the dynamic sql and
+// the sql with in lists) is converted by rebind.
+func (t *target) bind(sql string) string {
+	if t.dbType == "postgres" {
+		return run.BindDollar(sql)
+	}
+	return sql
+}
+
+// rebind generates the code to convert the bind variables of
+// "_sql" after it is built, the "?" are numbered in order.
+func (t *target) rebind(c *coder.Function) {
+	if t.dbType == "postgres" {
+		c.P(0, "_sql = ", t.conf[runName], ".BindDollar(_sql)")
+	}
+}
+
+// inConsts adds the consts of the sqls split by the in
+// lists, the empty sql has no const.
+func (t *target) inConsts(group *coder.VarGroup, constName string,
+	split *inSplit, cnt *int) {
+	split.names = make([]string, len(split.sqls))
+	for idx, sql := range split.sqls {
+		if strings.TrimSpace(sql) == "" {
+			continue
 		}
+		name := constName + strconv.Itoa(*cnt)
+		*cnt++
+		split.names[idx] = name
+		group.Add(name, coder.Quote(sql))
 	}
 }
 
@@ -111,6 +197,9 @@ func (t *target) Struct(idx int, c *coder.Struct, ic *coder.Import) {
 		ret.methodName)
 	c.SetName(ret.name)
 	for _, retField := range ret.fields {
+		if name, path := rdb.TypeImport(retField._type); path != "" {
+			ic.Add(name, path)
+		}
 		f := c.AddField()
 		f.Set(retField.name, retField._type)
 		f.AddTag("table", retField.table)
@@ -119,12 +208,16 @@ func (t *target) Struct(idx int, c *coder.Struct, ic *coder.Import) {
 }
 
 func (t *target) FuncNum() int {
-	return len(t.methods)
+	return len(t.methods) + len(t.chunks)
 }
 
 func (t *target) Func(idx int, c *coder.Function, ic *coder.Import) {
 	ic.Add(t.conf[runName], t.conf[runPath])
 
+	if idx >= len(t.methods) {
+		t.chunk(c, ic, t.chunks[idx-len(t.methods)])
+		return
+	}
 	m := t.methods[idx]
 	for _, impName := range m.base.Imports {
 		imp := t.importMap[impName]
@@ -147,20 +240,30 @@ func (t *target) Func(idx int, c *coder.Function, ic *coder.Import) {
 
 	if m.sql.Dyn {
 		constName = "_sql"
-		for _, dp := range m.sql.Dps {
+		sql.WalkDynamic(m.sql.Dps, func(dp *sql.DynamicPart) {
+			if dp.State == nil {
+				return
+			}
 			if len(dp.State.Prepares) > 0 {
 				hasPre = true
 			}
 			if len(dp.State.Replaces) > 0 {
 				hasRep = true
 			}
-		}
+		})
 		if hasPre {
 			pre = "pvs"
 		}
 		if hasRep {
 			rep = "rvs"
 		}
+	} else if m.in != nil {
+		constName = "_sql"
+		pre = "pvs"
+		if len(m.sql.State.Replaces) > 0 {
+			rep = strings.Join(m.sql.State.Replaces, ", ")
+			rep = fmt.Sprintf("[]interface{}{%s}", rep)
+		}
 	} else {
 		constName = fmt.Sprintf("_%s_%s", t.name, m.base.Name)
 		if len(m.sql.State.Replaces) > 0 {
@@ -173,15 +276,110 @@ func (t *target) Func(idx int, c *coder.Function, ic *coder.Import) {
 		}
 	}
 
-	c.Def(m.base.Name, "(*_", t.name, ") ", m.base.Def)
+	if m.sql.Chunk != "" {
+		// The function executes one chunk, see chunk.
+		c.Def("_"+m.base.Name, "(*_", t.name, ") _", m.base.Def)
+	} else {
+		c.Def(m.base.Name, "(*_", t.name, ") ", m.base.Def)
+	}
 	if m.sql.Dyn {
 		t.dyn(c, m, hasPre, hasRep)
 		ic.Add("", "strings")
+	} else if m.in != nil {
+		t.in(c, m)
 	}
 	t.body(c, m, pre, rep, constName)
 
 }
 
+// chunk generates the method with "chunk" option, it calls
+// the function of one chunk ("_{Method}") for each chunk of
+// the slice parameter, and merges the results: the rows are
+// appended, the affected rows are summed.
+func (t *target) chunk(c *coder.Function, ic *coder.Import, m *method) {
+	for _, impName := range m.base.Imports {
+		imp := t.importMap[impName]
+		if imp == nil {
+			continue
+		}
+		ic.Add(imp.Name, imp.Path)
+	}
+	name := m.sql.Chunk
+	args := make([]string, len(m.base.Params))
+	for idx, param := range m.base.Params {
+		arg := param.Name
+		if arg == name {
+			arg += "[start:end]"
+		}
+		if strings.HasPrefix(param.Type, "...") {
+			arg += "..."
+		}
+		args[idx] = arg
+	}
+	call := fmt.Sprintf("%s._%s(%s)", t.name, m.base.Name,
+		strings.Join(args, ", "))
+	start := fmt.Sprintf("err := %s.Chunk(len(%s), %d, "+
+		"func(start, end int) error {", t.conf[runName], name,
+		m.sql.ChunkSize)
+
+	c.Def(m.base.Name, "(*_", t.name, ") ", m.base.Def)
+	switch m.Type {
+	case queryMulti:
+		c.P(0, "var os ", retType(m))
+		c.P(0, start)
+		c.P(1, "chunk, err := ", call)
+		c.P(1, "os = append(os, chunk...)")
+		c.P(1, "return err")
+		c.P(0, "})")
+		c.P(0, "return os, err")
+
+	case execAffect:
+		c.P(0, "var affected int64")
+		c.P(0, start)
+		c.P(1, "n, err := ", call)
+		c.P(1, "affected += n")
+		c.P(1, "return err")
+		c.P(0, "})")
+		c.P(0, "return affected, err")
+
+	case execResult:
+		c.P(0, "var rs ", t.conf[runName], ".Results")
+		c.P(0, start)
+		c.P(1, "r, err := ", call)
+		c.P(1, "if err != nil {")
+		c.P(2, "return err")
+		c.P(1, "}")
+		c.P(1, "rs = append(rs, r)")
+		c.P(1, "return nil")
+		c.P(0, "})")
+		c.P(0, "if err != nil {")
+		c.P(1, "return nil, err")
+		c.P(0, "}")
+		c.P(0, "return rs, nil")
+	}
+}
+
+// in generates the code to expand the in lists of the
+// static sql.
+func (t *target) in(c *coder.Function, m *method) {
+	c.P(0, "// [in] start.")
+	state := m.sql.State
+	var cnt int
+	var slices []string
+	for _, name := range state.Prepares {
+		if m.lists[name] {
+			slices = append(slices, fmt.Sprintf("len(%s)", name))
+			continue
+		}
+		cnt++
+	}
+	c.P(0, "pvs := make([]interface{}, 0, ", dynCalcCap(cnt, slices), ")")
+	appendVals(0, c, state, m.lists)
+	c.P(0, "_sql := ", m.in.expr(t.conf[runName]))
+	t.rebind(c)
+	c.P(0, "// [in] done")
+}
+
 func (t *target) dyn(c *coder.Function, m *method, hasPre, hasRep bool) {
 	c.P(0, "// [dynamic] start.")
 	preCap, repCap := dynCalcValsCap(m.sql)
@@ -192,146 +390,159 @@ func (t *target) dyn(c *coder.Function, m *method, hasPre, hasRep bool) {
 		c.P(0, "rvs := make([]interface{}, 0, ", repCap, ")")
 	}
 	c.P(0, "slice := make([]string, 0, ", dynCalcSqlsCap(m.sql), ")")
-	initLastidx := false
-	for idx, dp := range m.sql.Dps {
-		name := fmt.Sprintf("_%s_%s%d",
-			t.name, m.base.Name, idx)
-		c.P(0, "// [dynamic] part ", idx)
+	t.dynParts(c, 0, m, m.sql.Dps)
+	c.P(0, "// [dynamic] joins")
+	c.P(0, "_sql := strings.Join(slice, ", "\" \")")
+	t.rebind(c)
+	c.P(0, "// [dynamic] done")
+}
 
+// dynParts generates the code of the parts, the children
+// are generated recursively. The "where" and "set" blocks
+// complete the parts appended by their children with the
+// run functions.
+func (t *target) dynParts(c *coder.Function, nTab int, m *method,
+	dps []*sql.DynamicPart) {
+	for _, dp := range dps {
 		switch dp.Type {
 		case sql.DynamicTypeConst:
-			c.P(0, "slice = append(slice, ", name, ")")
-			dynAppendVals(0, c, dp)
+			if split := m.dpIns[dp]; split != nil {
+				c.P(nTab, "slice = append(slice, ",
+					split.expr(t.conf[runName]), ")")
+			} else {
+				c.P(nTab, "slice = append(slice, ", m.dpNames[dp], ")")
+			}
+			appendVals(nTab, c, dp.State, m.lists)
 
 		case sql.DynamicTypeIf:
-			c.P(0, "if ", dp.IfCond, " {")
-			c.P(1, "slice = append(slice, ", name, ")")
-			dynAppendVals(1, c, dp)
-			c.P(0, "}")
+			c.P(nTab, "if ", dp.IfCond, " {")
+			t.dynParts(c, nTab+1, m, dp.Children)
+			for br := dp.Else; br != nil; br = br.Else {
+				if br.Type == sql.DynamicTypeElse {
+					c.P(nTab, "} else {")
+				} else {
+					c.P(nTab, "} else if ", br.IfCond, " {")
+				}
+				t.dynParts(c, nTab+1, m, br.Children)
+			}
+			c.P(nTab, "}")
 
 		case sql.DynamicTypeFor:
-			if dp.ForJoin != "" {
-				if initLastidx {
-					c.P(0, "lastidx = len(", name, ") - ",
-						len(dp.ForJoin))
+			if dp.ForJoin == "" {
+				dynFor(c, nTab, dp)
+				t.dynParts(c, nTab+1, m, dp.Children)
+				c.P(nTab, "}")
+				break
+			}
+			// The iteration might append nothing (such as the
+			// "if" in it is false), so the join is added only
+			// if both the previous iterations and the current
+			// one appended parts.
+			join := fmt.Sprintf("join%d", m.nFor)
+			start := fmt.Sprintf("start%d", m.nFor)
+			m.nFor++
+			c.P(nTab, join, " := len(slice)")
+			dynFor(c, nTab, dp)
+			c.P(nTab+1, start, " := len(slice)")
+			t.dynParts(c, nTab+1, m, dp.Children)
+			c.P(nTab+1, "if ", start, " > ", join, " && len(slice) > ", start, " {")
+			c.P(nTab+2, "slice[", start, "-1] += ", coder.Quote(dp.ForJoin))
+			c.P(nTab+1, "}")
+			c.P(nTab, "}")
+
+		case sql.DynamicTypeSwitch:
+			c.P(nTab, "switch ", dp.SwitchExpr, " {")
+			for _, cs := range dp.Children {
+				if cs.Type == sql.DynamicTypeDefault {
+					c.P(nTab, "default:")
 				} else {
-					c.P(0, "lastidx := len(", name, ") - ",
-						len(dp.ForJoin))
-					initLastidx = true
+					c.P(nTab, "case ", cs.CaseExpr, ":")
 				}
+				t.dynParts(c, nTab+1, m, cs.Children)
 			}
-			dynFor(c, dp)
-			dynAppendVals(1, c, dp)
-			if dp.ForJoin != "" {
-				c.P(1, "if i == len(", dp.ForSlice, ") - 1 {")
-				c.P(2, "slice = append(slice, ", name, "[:lastidx])")
-				c.P(1, "} else {")
-				c.P(2, "slice = append(slice, ", name, ")")
-				c.P(1, "}")
-			} else {
-				c.P(1, "slice = append(slice, ", name, ")")
+			c.P(nTab, "}")
+
+		case sql.DynamicTypeWhere, sql.DynamicTypeSet:
+			fn := "Where"
+			if dp.Type == sql.DynamicTypeSet {
+				fn = "Set"
 			}
-			c.P(0, "}")
+			start := fmt.Sprintf("%s%d", strings.ToLower(fn), m.nBlock)
+			m.nBlock++
+			c.P(nTab, start, " := len(slice)")
+			t.dynParts(c, nTab, m, dp.Children)
+			c.P(nTab, t.conf[runName], ".", fn, "(slice[", start, ":])")
 		}
 	}
-	c.P(0, "// [dynamic] joins")
-	c.P(0, "_sql := strings.Join(slice, ", "\" \")")
-	c.P(0, "// [dynamic] done")
-}
-
-func dynFor(c *coder.Function, dp *sql.DynamicPart) {
-	hasIdx := dp.ForJoin != ""
-	hasEle := dp.ForEle != ""
-
-	switch {
-	case hasIdx, hasEle:
-		c.P(0, "for i, ", dp.ForEle,
-			" := range ", dp.ForSlice, " {")
-
-	case hasIdx, !hasEle:
-		c.P(0, "for i := range ", dp.ForSlice, " {")
-
-	case !hasIdx, hasEle:
-		c.P(0, "for _, ", dp.ForEle,
-			" := range ", dp.ForSlice, " {")
-
-	default:
-		c.P(0, "for range ", dp.ForSlice, " {")
-	}
 }
 
-func dynAppendVals(nTab int, c *coder.Function, dp *sql.DynamicPart) {
-	if len(dp.State.Prepares) > 0 {
-		c.P(nTab, "pvs = append(pvs, ",
-			strings.Join(dp.State.Prepares, ", "), ")")
-	}
-	if len(dp.State.Replaces) > 0 {
-		c.P(nTab, "rvs = append(rvs, ",
-			strings.Join(dp.State.Replaces, ", "), ")")
+func dynFor(c *coder.Function, nTab int, dp *sql.DynamicPart) {
+	if dp.ForEle != "" && dp.ForEle != "_" {
+		c.P(nTab, "for _, ", dp.ForEle, " := range ", dp.ForSlice, " {")
+		return
 	}
+	c.P(nTab, "for range ", dp.ForSlice, " {")
 }
 
 func dynCalcValsCap(m *sql.Method) (string, string) {
-	var (
-		preCnt = 0
-		repCnt = 0
-
-		preSlice []string
-		repSlice []string
-	)
-	for _, dp := range m.Dps {
-		switch dp.Type {
-		case sql.DynamicTypeConst:
-			fallthrough
-		case sql.DynamicTypeIf:
-			preCnt += len(dp.State.Prepares)
-			repCnt += len(dp.State.Replaces)
-
-		case sql.DynamicTypeFor:
-
-			var sliceCap string
-			if len(dp.State.Prepares) > 0 {
-				if len(dp.State.Prepares) > 1 {
-					sliceCap = fmt.Sprintf("%d*len(%s)",
-						len(dp.State.Prepares), dp.ForSlice)
-				} else {
-					sliceCap = fmt.Sprintf("len(%s)",
-						dp.ForSlice)
-				}
-				preSlice = append(preSlice, sliceCap)
-			}
-
-			if len(dp.State.Replaces) > 0 {
-				if len(dp.State.Replaces) > 1 {
-					sliceCap = fmt.Sprintf("%d*len(%s)",
-						len(dp.State.Replaces), dp.ForSlice)
-				} else {
-					sliceCap = fmt.Sprintf("len(%s)",
-						dp.ForSlice)
-				}
-				repSlice = append(repSlice, sliceCap)
-			}
-		}
-	}
+	preCnt, preSlice := dynCount(m.Dps, func(state *sql.Statement) int {
+		return len(state.Prepares)
+	})
+	repCnt, repSlice := dynCount(m.Dps, func(state *sql.Statement) int {
+		return len(state.Replaces)
+	})
 	return dynCalcCap(preCnt, preSlice),
 		dynCalcCap(repCnt, repSlice)
 }
 
 func dynCalcSqlsCap(m *sql.Method) string {
-	cap := 0
+	return dynCalcCap(dynCount(m.Dps, func(*sql.Statement) int {
+		return 1
+	}))
+}
+
+// dynCount counts the const parts by cnt, the parts in the
+// "for" are counted as "n*len(slice)". All the branches of
+// "if" and "switch" are counted, and the nested "for" is ignored, since
+// its slice might be the loop variable. The result is only
+// used as the capacity.
+func dynCount(dps []*sql.DynamicPart, cnt func(*sql.Statement) int) (
+	int, []string,
+) {
+	var n int
 	var slices []string
-	for _, dp := range m.Dps {
+	for _, dp := range dps {
 		switch dp.Type {
-		case sql.DynamicTypeIf:
-			fallthrough
 		case sql.DynamicTypeConst:
-			cap += 1
+			n += cnt(dp.State)
+
+		case sql.DynamicTypeIf, sql.DynamicTypeWhere, sql.DynamicTypeSet,
+			sql.DynamicTypeCase, sql.DynamicTypeDefault:
+			for br := dp; br != nil; br = br.Else {
+				brN, brSlices := dynCount(br.Children, cnt)
+				n += brN
+				slices = append(slices, brSlices...)
+			}
+
+		case sql.DynamicTypeSwitch:
+			csN, csSlices := dynCount(dp.Children, cnt)
+			n += csN
+			slices = append(slices, csSlices...)
+
 		case sql.DynamicTypeFor:
-			slicecap := fmt.Sprintf("len(%s)", dp.ForSlice)
-			slices = append(slices, slicecap)
+			loopN, _ := dynCount(dp.Children, cnt)
+			switch {
+			case loopN == 1:
+				slices = append(slices,
+					fmt.Sprintf("len(%s)", dp.ForSlice))
+
+			case loopN > 1:
+				slices = append(slices, fmt.Sprintf("%d*len(%s)",
+					loopN, dp.ForSlice))
+			}
 		}
 	}
-	return dynCalcCap(cap, slices)
+	return n, slices
 }
 
 func dynCalcCap(cap int, extracts []string) string {
@@ -360,18 +571,11 @@ func (t *target) body(c *coder.Function, m *method, pre, rep, sqlName string) {
 		case execResult:
 			call = "Exec"
 		}
-		c.P(0, "return ", t.conf[runName], ".", call, "(",
-			t.conf[runName], ", ", sqlName, ", ", rep, ", ", pre, ")")
+		c.P(0, "return ", t.call(m, call), sqlName, ", ", rep, ", ", pre, ")")
 		return
 	}
 
-	retTypeFull := m.base.RetType
-	if m.base.RetPointer {
-		retTypeFull = "*" + retTypeFull
-	}
-	if m.base.RetSlice {
-		retTypeFull = "[]" + retTypeFull
-	}
+	retTypeFull := retType(m)
 
 	fstrs := make([]string, len(m.sql.Fields))
 	for idx, f := range m.sql.Fields {
@@ -386,8 +590,7 @@ func (t *target) body(c *coder.Function, m *method, pre, rep, sqlName string) {
 
 	if m.Type == queryOne {
 		c.P(0, "var o ", retTypeFull)
-		c.P(0, "err := ", t.conf[runName], ".QueryOne(", t.conf[dbUse],
-			", ", sqlName, ", ", rep, ", ", pre,
+		c.P(0, "err := ", t.call(m, "QueryOne"), sqlName, ", ", rep, ", ", pre,
 			", func(rows *sql.Rows) error {")
 		if m.base.RetPointer {
 			c.P(1, "o = new(", m.base.RetType, ")")
@@ -403,8 +606,7 @@ func (t *target) body(c *coder.Function, m *method, pre, rep, sqlName string) {
 	}
 
 	c.P(0, "var os ", retTypeFull)
-	c.P(0, "err := ", t.conf[runName], ".QueryMany(", t.conf[dbUse],
-		", ", sqlName, ", ", rep, ", ", pre,
+	c.P(0, "err := ", t.call(m, "QueryMany"), sqlName, ", ", rep, ", ", pre,
 		", func(rows *sql.Rows) error {")
 	if m.base.RetPointer {
 		c.P(1, "o := new(", m.base.RetType, ")")
@@ -426,6 +628,33 @@ func (t *target) body(c *coder.Function, m *method, pre, rep, sqlName string) {
 	c.P(0, "return os, err")
 }
 
+// retType returns the full return type of the query
+// method, such as "[]*User".
+func retType(m *method) string {
+	full := m.base.RetType
+	if m.base.RetPointer {
+		full = "*" + full
+	}
+	if m.base.RetSlice {
+		full = "[]" + full
+	}
+	return full
+}
+
+// call returns the beginning of calling the "*Named" run
+// function, including the db and the query name
+// ("{Oper}.{Method}") arguments. If the method has context,
+// the context variant is called.
+func (t *target) call(m *method, name string) string {
+	queryName := coder.Quote(t.name + "." + m.base.Name)
+	if m.ctx == "" {
+		return fmt.Sprintf("%s.%sNamed(%s, %s, ", t.conf[runName], name,
+			t.conf[dbUse], queryName)
+	}
+	return fmt.Sprintf("%s.%sNamedContext(%s, %s, %s, ", t.conf[runName], name,
+		m.ctx, t.conf[dbUse], queryName)
+}
+
 func assign(rets []string) string {
 	ss := make([]string, len(rets))
 	for i, ret := range rets {
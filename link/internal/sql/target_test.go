@@ -0,0 +1,193 @@
+package sql
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fioncat/go-gendb/coder"
+	"github.com/fioncat/go-gendb/compile/golang"
+	"github.com/fioncat/go-gendb/database/rdb"
+)
+
+// genCode links the Go source with the sql file, and returns
+// the generated consts and functions.
+func genCode(t *testing.T, src, sqlSrc string) string {
+	dir, err := ioutil.TempDir("", "gendb-sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "user.go")
+	err = ioutil.WriteFile(path, []byte(src), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "user.sql"), []byte(sqlSrc), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := golang.ReadLines(path, strings.Split(src, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	linker := new(Linker)
+	conf := linker.DefaultConf()
+	conf[typeCheck] = "false"
+	ts, err := linker.Do(file, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := new(coder.Coder)
+	ic := new(coder.Import)
+	for _, tg := range ts {
+		vc := &coder.Var{Const: true}
+		tg.Consts(vc, ic)
+		c.AddSub(vc)
+		for idx := 0; idx < tg.FuncNum(); idx++ {
+			fc := new(coder.Function)
+			tg.Func(idx, fc, ic)
+			c.AddSub(fc)
+		}
+	}
+	c.Body()
+	return c.String()
+}
+
+// useSchema sets the database type of the global session.
+func useSchema(t *testing.T, dbType string) {
+	dir, err := ioutil.TempDir("", "gendb-schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "create.sql")
+	err = ioutil.WriteFile(path, []byte("CREATE TABLE user(id BIGINT);"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rdb.InitSchema(path, dbType)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectCode(t *testing.T, code string, lines ...string) {
+	for _, line := range lines {
+		if !strings.Contains(code, line) {
+			t.Fatalf("missing %q in the generated code:\n%s", line, code)
+		}
+	}
+}
+
+const bindGo = `// +gen:sql v=0.3
+package user
+
+import "github.com/fioncat/go-gendb/api/sql/run"
+
+// +gen:sql name=UserOper file=user.sql
+type IUserOper interface {
+	Count(db run.IDB, id int64, name string) (int64, error)
+	Delete(db run.IDB, ids []int64, age int) (int64, error)
+	Update(db run.IDB, id int64, names []string) (int64, error)
+}
+`
+
+const bindSql = `-- +gen:sql v=0.3
+
+-- +gen:method Count
+SELECT COUNT(1) FROM user WHERE id=${id} AND name=${name} AND note != '?'
+-- +gen:end
+
+-- +gen:method Delete
+DELETE FROM user WHERE id IN ${ids} AND age > ${age}
+-- +gen:end
+
+-- +gen:method Update dyn=true
+UPDATE user SET is_delete=1
+%{where}
+  %{if id > 0}AND id=${id}%{endif}
+  %{if len(names) > 0}AND name IN ${names}%{endif}
+%{endwhere}
+-- +gen:end
+`
+
+func TestBindPostgres(t *testing.T) {
+	useSchema(t, "postgres")
+	defer useSchema(t, "mysql")
+	code := genCode(t, bindGo, bindSql)
+	expectCode(t, code,
+		`"SELECT COUNT(1) FROM user WHERE id=$1 AND name=$2 AND note != '?'"`,
+		`" AND age > ?"`,
+		"_sql := _UserOper_Delete0 + run.In(\"id IN\", len(ids), \"1=0\") + _UserOper_Delete1\n\t_sql = run.BindDollar(_sql)",
+		"_sql := strings.Join(slice, \" \")\n\t_sql = run.BindDollar(_sql)",
+	)
+}
+
+func TestBindMysql(t *testing.T) {
+	useSchema(t, "mysql")
+	code := genCode(t, bindGo, bindSql)
+	expectCode(t, code,
+		`"SELECT COUNT(1) FROM user WHERE id=? AND name=? AND note != '?'"`)
+	if strings.Contains(code, "BindDollar") {
+		t.Fatalf("unexpected BindDollar for mysql:\n%s", code)
+	}
+}
+
+const forGo = `// +gen:sql v=0.3
+package user
+
+import "github.com/fioncat/go-gendb/api/sql/run"
+
+type User struct {
+	Id    int64
+	Admin bool
+}
+
+// +gen:sql name=UserOper file=user.sql
+type IUserOper interface {
+	Delete(db run.IDB, us []*User) (int64, error)
+}
+`
+
+const forSql = `-- +gen:sql v=0.3
+
+-- +gen:method Delete dyn=true
+DELETE FROM user WHERE is_delete=0 AND (
+%{for u in us join ' OR '}
+  %{if u.Admin}admin_id=${u.Id}%{endif}
+%{endfor}
+%{for u in us join ','}
+  ${u.Id}
+%{endfor}
+)
+-- +gen:end
+`
+
+func TestForJoin(t *testing.T) {
+	useSchema(t, "mysql")
+	code := genCode(t, forGo, forSql)
+	expectCode(t, code,
+		"\tjoin0 := len(slice)\n"+
+			"\tfor _, u := range us {\n"+
+			"\t\tstart0 := len(slice)\n"+
+			"\t\tif u.Admin {\n"+
+			"\t\t\tslice = append(slice, _UserOper_Delete1)\n"+
+			"\t\t\tpvs = append(pvs, u.Id)\n"+
+			"\t\t}\n"+
+			"\t\tif start0 > join0 && len(slice) > start0 {\n"+
+			"\t\t\tslice[start0-1] += \" OR \"\n"+
+			"\t\t}\n"+
+			"\t}\n",
+		"\tjoin1 := len(slice)\n"+
+			"\tfor _, u := range us {\n"+
+			"\t\tstart1 := len(slice)\n",
+		"\t\t\tslice[start1-1] += \",\"\n",
+	)
+	if strings.Contains(code, "i > 0") {
+		t.Fatalf("the join is added by the index:\n%s", code)
+	}
+}
@@ -0,0 +1,261 @@
+package sql
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fioncat/go-gendb/compile/golang"
+	"github.com/fioncat/go-gendb/compile/sql"
+	"github.com/fioncat/go-gendb/misc/errors"
+	"github.com/fioncat/go-gendb/misc/log"
+)
+
+// the name of the file generated to type check, it is not
+// written to disk.
+const checkFilename = "_gendb_type_check.go"
+
+// checkExpr is an expression of the sql method written to
+// the check file.
+type checkExpr struct {
+	file *sql.File
+	m    *sql.Method
+
+	// the placeholder or the condition in sql, such as
+	// "${u.Name}", "%{if len(ids) > 0}".
+	text string
+
+	// the text to search the line in sql file.
+	search string
+}
+
+// typeChecker type checks the placeholders and conditions
+// of the sql methods against the Go method signatures. It
+// writes a function for each method, whose parameters are
+// the same as the Go method, and the body uses the
+// expressions of the sql. Then the function is checked with
+// the package by go/types.
+type typeChecker struct {
+	buf  bytes.Buffer
+	line int
+	cnt  int
+
+	exprs map[int]*checkExpr
+}
+
+func newTypeChecker(file *golang.File) *typeChecker {
+	tc := &typeChecker{exprs: make(map[int]*checkExpr)}
+	tc.p("package ", file.Package)
+	tc.p()
+	for _, imp := range file.Imports {
+		tc.p("import ", imp.Name, " ", fmt.Sprintf("%q", imp.Path))
+	}
+	return tc
+}
+
+func (tc *typeChecker) p(a ...interface{}) {
+	tc.line++
+	tc.buf.WriteString(fmt.Sprint(a...))
+	tc.buf.WriteString("\n")
+}
+
+func (tc *typeChecker) expr(ce *checkExpr, format string, a ...interface{}) {
+	tc.p(fmt.Sprintf(format, a...))
+	tc.exprs[tc.line] = ce
+}
+
+func (tc *typeChecker) add(goMethod *golang.Method,
+	file *sql.File, m *sql.Method) {
+	start := strings.Index(goMethod.Def, "(")
+	if start < 0 {
+		return
+	}
+	tc.cnt++
+	tc.p()
+	tc.p("func _gendb_check_", tc.cnt, goMethod.Def[start:], " {")
+	states := func(state *sql.Statement) {
+		for _, name := range state.Prepares {
+			text := "${" + name + "}"
+			ce := &checkExpr{file: file, m: m, text: text, search: text}
+			tc.expr(ce, "_ = %s", name)
+		}
+		for _, name := range state.Replaces {
+			text := "#{" + name + "}"
+			ce := &checkExpr{file: file, m: m, text: text, search: text}
+			tc.expr(ce, "_ = %s", name)
+		}
+	}
+	if !m.Dyn {
+		states(m.State)
+	}
+	tc.parts(file, m, m.Dps, states)
+	tc.p("panic(nil)")
+	tc.p("}")
+}
+
+// parts writes the dynamic parts, "if" and "for" are
+// written as Go statements, so the loop variables and the
+// conditions are checked in scope.
+func (tc *typeChecker) parts(file *sql.File, m *sql.Method,
+	dps []*sql.DynamicPart, states func(*sql.Statement)) {
+	for _, dp := range dps {
+		switch dp.Type {
+		case sql.DynamicTypeConst:
+			states(dp.State)
+
+		case sql.DynamicTypeIf:
+			ce := &checkExpr{file: file, m: m,
+				text:   "%{if " + dp.IfCond + "}",
+				search: dp.IfCond}
+			tc.expr(ce, "if %s {", dp.IfCond)
+			tc.parts(file, m, dp.Children, states)
+			for br := dp.Else; br != nil; br = br.Else {
+				if br.Type == sql.DynamicTypeElse {
+					tc.p("} else {")
+				} else {
+					ce := &checkExpr{file: file, m: m,
+						text:   "%{elif " + br.IfCond + "}",
+						search: br.IfCond}
+					tc.expr(ce, "} else if %s {", br.IfCond)
+				}
+				tc.parts(file, m, br.Children, states)
+			}
+			tc.p("}")
+
+		case sql.DynamicTypeFor:
+			ele := dp.ForEle
+			if ele == "" {
+				ele = "_"
+			}
+			ce := &checkExpr{file: file, m: m,
+				text:   "%{for " + dp.ForEle + " in " + dp.ForSlice + "}",
+				search: " in " + dp.ForSlice}
+			tc.expr(ce, "for _, %s := range %s {", ele, dp.ForSlice)
+			if ele != "_" {
+				tc.p("_ = ", ele)
+			}
+			tc.parts(file, m, dp.Children, states)
+			tc.p("}")
+
+		case sql.DynamicTypeSwitch:
+			ce := &checkExpr{file: file, m: m,
+				text:   "%{switch " + dp.SwitchExpr + "}",
+				search: dp.SwitchExpr}
+			tc.expr(ce, "switch %s {", dp.SwitchExpr)
+			for _, cs := range dp.Children {
+				if cs.Type == sql.DynamicTypeDefault {
+					tc.p("default:")
+				} else {
+					ce := &checkExpr{file: file, m: m,
+						text:   "%{case " + cs.CaseExpr + "}",
+						search: cs.CaseExpr}
+					tc.expr(ce, "case %s:", cs.CaseExpr)
+				}
+				tc.parts(file, m, cs.Children, states)
+			}
+			tc.p("}")
+
+		case sql.DynamicTypeWhere, sql.DynamicTypeSet:
+			tc.parts(file, m, dp.Children, states)
+		}
+	}
+}
+
+// check type checks the functions with the package of the
+// Go file. The errors outside the expressions are ignored,
+// since the package might be incomplete before generating.
+func (tc *typeChecker) check(path string) error {
+	if len(tc.exprs) == 0 {
+		return nil
+	}
+	start := time.Now()
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	imp := getImporter(dir)
+	fset := imp.fset
+	checkPath := filepath.Join(dir, checkFilename)
+	checkFile, err := parser.ParseFile(fset, checkPath, tc.buf.Bytes(), 0)
+	if err != nil {
+		// The definition of the Go method might be invalid,
+		// which is reported by go build.
+		log.Infof("[link] [sql] skip type check: %v", err)
+		return nil
+	}
+	files := []*ast.File{checkFile}
+
+	pkgPath := filepath.Base(dir)
+	pkg, err := build.ImportDir(dir, 0)
+	if err == nil {
+		if pkg.ImportPath != "" && pkg.ImportPath != "." {
+			pkgPath = pkg.ImportPath
+		}
+		for _, name := range pkg.GoFiles {
+			file, err := parser.ParseFile(fset,
+				filepath.Join(dir, name), nil, 0)
+			if err != nil {
+				continue
+			}
+			files = append(files, file)
+		}
+	}
+
+	var first error
+	conf := types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			if first != nil {
+				return
+			}
+			terr, ok := err.(types.Error)
+			if !ok {
+				return
+			}
+			pos := terr.Fset.Position(terr.Pos)
+			if pos.Filename != checkPath {
+				return
+			}
+			ce := tc.exprs[pos.Line]
+			if ce == nil {
+				return
+			}
+			first = ce.error(terr.Msg)
+		},
+	}
+	_, _ = conf.Check(pkgPath, fset, files, nil)
+	log.Infof("[link] [sql] [%v] type check %s, %d expr(s)",
+		time.Since(start), dir, len(tc.exprs))
+	return first
+}
+
+// error returns the compile error at the line of the
+// expression in sql file.
+func (ce *checkExpr) error(msg string) error {
+	err := fmt.Errorf(`%s: %s: %s`, ce.m.Name, ce.text, msg)
+	err = errors.Trace(ce.line(), err)
+	err = errors.Trace(ce.file.Path, err)
+	return errors.OnCompile(ce.file.Path, ce.file.Lines, err)
+}
+
+// line finds the line of the expression in the method,
+// if not found, returns the line of the method.
+func (ce *checkExpr) line() int {
+	lines := ce.file.Lines
+	for idx := ce.m.LineIdx() + 1; idx < len(lines); idx++ {
+		line := lines[idx]
+		if strings.Contains(line, "+gen:end") {
+			break
+		}
+		if strings.Contains(line, ce.search) {
+			return idx + 1
+		}
+	}
+	return ce.m.LineIdx() + 1
+}
@@ -2,17 +2,21 @@ package link
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/fioncat/go-gendb/coder"
 	"github.com/fioncat/go-gendb/compile/base"
 	"github.com/fioncat/go-gendb/compile/golang"
+	"github.com/fioncat/go-gendb/compile/orm"
+	"github.com/fioncat/go-gendb/config"
 	"github.com/fioncat/go-gendb/database/rdb"
 	"github.com/fioncat/go-gendb/link/internal/deepcopy"
 	"github.com/fioncat/go-gendb/link/internal/orm_mgo"
 	"github.com/fioncat/go-gendb/link/internal/orm_sql"
 	"github.com/fioncat/go-gendb/link/internal/sql"
 	"github.com/fioncat/go-gendb/misc/errors"
+	"github.com/fioncat/go-gendb/misc/log"
 )
 
 type linker interface {
@@ -58,6 +62,11 @@ func Do(file *golang.File) (*Result, error) {
 		return nil, fmt.Errorf(`can not find `+
 			`linker "%s"`, file.Type)
 	}
+	err := Prepare(file)
+	if err != nil {
+		return nil, err
+	}
+
 	conf := linker.DefaultConf()
 	if conf == nil {
 		conf = make(map[string]string)
@@ -71,26 +80,8 @@ func Do(file *golang.File) (*Result, error) {
 			imp.Path = opt.Value
 			res.Imports = append(res.Imports, imp)
 
-		case "conn":
-			var connName string
-			var connType = "mysql"
-			tmp := strings.Split(opt.Value, ",")
-			switch len(tmp) {
-			case 1:
-				connName = opt.Value
-
-			case 2:
-				connType = tmp[0]
-				connName = tmp[1]
-
-			default:
-				return nil, fmt.Errorf(`conn `+
-					`config "%s" is bad format`, opt.Value)
-			}
-			err := rdb.Init(connName, connType)
-			if err != nil {
-				return nil, errors.Trace("connect database", err)
-			}
+		case "conn", "schema":
+			// Handled by Prepare.
 
 		case "package":
 			res.Package = opt.Value
@@ -129,3 +120,85 @@ func Do(file *golang.File) (*Result, error) {
 
 	return res, nil
 }
+
+// Prepare reads the config file and initializes the
+// database session according to the file options ("conn"
+// and "schema"), it is called by Do before linking.
+func Prepare(file *golang.File) error {
+	cfg, err := config.Find(file.Path)
+	if err != nil {
+		return errors.Trace("read config", err)
+	}
+	if cfg != nil {
+		log.Infof("[link] use config %s", cfg.Path)
+		rdb.SetTypes(cfg.Types)
+	} else {
+		rdb.SetTypes(nil)
+	}
+
+	for _, opt := range file.Options {
+		switch opt.Key {
+		case "conn":
+			// conn=[type,]key, the global connection, it can
+			// be overwritten by the "conn" option of the
+			// interface or struct.
+			connName, connType, err := rdb.ParseConn(opt.Value)
+			if err != nil {
+				return err
+			}
+			err = rdb.Init(connName, connType)
+			if err != nil {
+				return errors.Trace("connect database", err)
+			}
+
+		case "schema":
+			// schema=[type,]path, describe tables from the
+			// DDL files or the snapshot rather than the
+			// database.
+			schemaPath := opt.Value
+			var schemaType string
+			tmp := strings.Split(opt.Value, ",")
+			switch len(tmp) {
+			case 1:
+
+			case 2:
+				schemaType = tmp[0]
+				schemaPath = tmp[1]
+
+			default:
+				return fmt.Errorf(`schema `+
+					`config "%s" is bad format`, opt.Value)
+			}
+			if !filepath.IsAbs(schemaPath) {
+				dir := filepath.Dir(file.Path)
+				schemaPath = filepath.Join(dir, schemaPath)
+			}
+			// The DDL error has its own compile trace, so
+			// do not wrap it.
+			err := rdb.InitSchema(schemaPath, schemaType)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Migrate compares the orm-sql declarations in the file
+// with the database schema (see Prepare), and returns the
+// migration from the schema to the declarations.
+func Migrate(file *golang.File) (*orm_sql.Migration, error) {
+	if file.Type != "orm-sql" {
+		return nil, fmt.Errorf(`migrate only supports `+
+			`"orm-sql" file, found "%s"`, file.Type)
+	}
+	err := Prepare(file)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := orm.Parse(file, false)
+	if err != nil {
+		return nil, err
+	}
+	return orm_sql.Migrate(rs)
+}
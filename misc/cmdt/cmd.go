@@ -37,7 +37,8 @@ type ActionFunc func(p interface{}) error
 // command line; the field tagged by "arg" represents "<value>"
 // parameter. In addition, the type of "flag" can only be string,
 // int, bool (bool has no "<value>"), and "arg" can only be of
-// string type.
+// string type, except the last "arg", which can be []string to
+// take all the remaining (maybe zero) args.
 type Command struct {
 	// Name is the unique identifier of the command,
 	// and the user needs to call the specified
@@ -110,7 +111,7 @@ func (cmd *Command) parsePv() {
 
 		_field := _field{
 			goName: field.Name,
-			goType: field.Type.Name(),
+			goType: field.Type.String(),
 		}
 
 		// get "flag" tag
@@ -122,8 +123,16 @@ func (cmd *Command) parsePv() {
 				panic("field " + field.Name +
 					" is neighter flag nor tag")
 			}
-			// arg's go type must be string
-			if _field.goType != "string" {
+			// arg's go type must be string, or []string
+			// for the last one.
+			switch _field.goType {
+			case "string":
+			case "[]string":
+				if i != nFields-1 {
+					panic("arg " + field.Name +
+						"'s type is []string, but it is not the last one")
+				}
+			default:
 				panic("arg " + field.Name +
 					"'s type is not a string")
 			}
@@ -197,6 +206,13 @@ func (cmd *Command) parseArgs(osargs []string) (map[string]interface{}, error) {
 			}
 
 			arg := cmd.args[argIdx]
+			if arg.goType == "[]string" {
+				// The remaining args are all collected
+				// into the last one.
+				vals, _ := values[arg.goName].([]string)
+				values[arg.goName] = append(vals, osArg)
+				continue
+			}
 			values[arg.goName] = osArg
 			argIdx += 1
 
@@ -252,7 +268,7 @@ func (cmd *Command) parseArgs(osargs []string) (map[string]interface{}, error) {
 		}
 	}
 
-	if argIdx != len(cmd.args) {
+	if argIdx != len(cmd.args) && cmd.args[argIdx].goType != "[]string" {
 		return missingArgErr(cmd.args[argIdx].name)
 	}
 
@@ -319,6 +335,8 @@ func (cmd *Command) parseP(args []string) (interface{}, error) {
 			field.SetString(value.(string))
 		case bool:
 			field.SetBool(value.(bool))
+		case []string:
+			field.Set(reflect.ValueOf(value))
 		}
 	}
 
@@ -14,4 +14,6 @@ type _oper interface {
 	FindByCond(conds map[string]interface{}, offset, limit int32) ([]*User, error)
 
 	Adds(us []*User) (int64, error)
+
+	UpdateById(id int64, sets map[string]interface{}) (int64, error)
 }
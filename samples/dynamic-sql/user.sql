@@ -7,19 +7,20 @@ SELECT
 	is_admin, is_delete
 FROM
 	user
-WHERE 1=1
-%{if conds["id"] != nil}
-	AND id=${conds["id"]}
-%{endif}
-%{if conds["name"] != nil}
-	AND name=${conds["name"]}
-%{endif}
-%{if conds["email"] != nil}
-	AND email=${conds["email"]}
-%{endif}
-%{if conds["phone"] != nil}
-	AND phone=${conds["phone"]}
-%{endif}
+%{where}
+	%{if conds["id"] != nil}
+		AND id=${conds["id"]}
+	%{endif}
+	%{if conds["name"] != nil}
+		AND name=${conds["name"]}
+	%{endif}
+	%{if conds["email"] != nil}
+		AND email=${conds["email"]}
+	%{endif}
+	%{if conds["phone"] != nil}
+		AND phone=${conds["phone"]}
+	%{endif}
+%{endwhere}
 LIMIT
 	${offset}, ${limit}
 -- +gen:end
@@ -34,3 +35,19 @@ VALUES
 
 -- +gen:end
 
+
+-- +gen:method UpdateById dyn=true
+UPDATE `user`
+%{set}
+	%{if sets["name"] != nil}
+		`name`=${sets["name"]},
+	%{endif}
+	%{if sets["email"] != nil}
+		`email`=${sets["email"]},
+	%{endif}
+	%{if sets["phone"] != nil}
+		`phone`=${sets["phone"]},
+	%{endif}
+%{endset}
+WHERE id=${id}
+-- +gen:end
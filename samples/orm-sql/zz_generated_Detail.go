@@ -19,13 +19,15 @@ const (
 )
 
 const (
-	_Detail_InsertOne    = "INSERT INTO `user_detail`(`user_id`,`text`,`balance`,`score`) VALUES (?,?,?,?)"
-	_Detail_InsertBatch  = "INSERT INTO `user_detail`(`user_id`,`text`,`balance`,`score`) VALUES %s"
-	_Detail_InsertValues = "(?,?,?,?)"
-	_Detail_FindById     = "SELECT `id`,`user_id`,`text`,`balance`,`score` FROM `user_detail` WHERE `id`=?"
-	_Detail_DeleteById   = "DELETE FROM `user_detail` WHERE `id`=?"
-	_Detail_UpdateById   = "UPDATE `user_detail` SET `user_id`=?,`text`=?,`balance`=?,`score`=? WHERE `id`=?"
-	_Detail_Count        = "SELECT COUNT(1) FROM `user_detail`"
+	_Detail_InsertOne               = "INSERT INTO `user_detail`(`user_id`,`text`,`balance`,`score`) VALUES (?,?,?,?)"
+	_Detail_InsertBatch             = "INSERT INTO `user_detail`(`user_id`,`text`,`balance`,`score`) VALUES %s"
+	_Detail_InsertValues            = "(?,?,?,?)"
+	_Detail_FindById                = "SELECT `id`,`user_id`,`text`,`balance`,`score` FROM `user_detail` WHERE `id`=?"
+	_Detail_FindByIdForUpdate       = "SELECT `id`,`user_id`,`text`,`balance`,`score` FROM `user_detail` WHERE `id`=? FOR UPDATE"
+	_Detail_FindByIdLockInShareMode = "SELECT `id`,`user_id`,`text`,`balance`,`score` FROM `user_detail` WHERE `id`=? LOCK IN SHARE MODE"
+	_Detail_DeleteById              = "DELETE FROM `user_detail` WHERE `id`=?"
+	_Detail_UpdateById              = "UPDATE `user_detail` SET `user_id`=?,`text`=?,`balance`=?,`score`=? WHERE `id`=?"
+	_Detail_Count                   = "SELECT COUNT(1) FROM `user_detail`"
 )
 
 const _Detail_FindOneByUserId = "SELECT `id`,`user_id`,`text`,`balance`,`score` FROM `user_detail` WHERE `user_id`=?"
@@ -44,24 +46,55 @@ type Detail struct {
 }
 
 func (*_DetailOper) Insert(db run.IDB, o *Detail) (sql.Result, error) {
-	return run.Exec(db, _Detail_InsertOne, nil, []interface{}{o.UserId, o.Text, o.Balance, o.Score})
+	return run.ExecNamed(db, "DetailOper.Insert", _Detail_InsertOne, nil, []interface{}{o.UserId, o.Text, o.Balance, o.Score})
 }
 
 func (*_DetailOper) InsertBatch(db run.IDB, os []*Detail) (sql.Result, error) {
-	vs := make([]interface{}, 0, 5*len(os))
-	valStrs := make([]string, len(os))
-	for idx, o := range os {
-		valStrs[idx] = _Detail_InsertValues
-		vs = append(vs, o.UserId, o.Text, o.Balance, o.Score)
+	var rs run.Results
+	err := run.Chunk(len(os), 16383, func(start, end int) error {
+		chunk := os[start:end]
+		vs := make([]interface{}, 0, 5*len(chunk))
+		valStrs := make([]string, len(chunk))
+		for idx, o := range chunk {
+			valStrs[idx] = _Detail_InsertValues
+			vs = append(vs, o.UserId, o.Text, o.Balance, o.Score)
+		}
+		valStr := strings.Join(valStrs, ", ")
+		_sql := fmt.Sprintf(_Detail_InsertBatch, valStr)
+		r, err := run.ExecNamed(db, "DetailOper.InsertBatch", _sql, nil, vs)
+		if err != nil {
+			return err
+		}
+		rs = append(rs, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	valStr := strings.Join(valStrs, ", ")
-	_sql := fmt.Sprintf(_Detail_InsertBatch, valStr)
-	return run.Exec(db, _sql, nil, vs)
+	return rs, nil
 }
 
 func (*_DetailOper) FindById(db run.IDB, id int64) (*Detail, error) {
 	var o *Detail
-	err := run.QueryOne(db, _Detail_FindById, nil, []interface{}{id}, func(rows *sql.Rows) error {
+	err := run.QueryOneNamed(db, "DetailOper.FindById", _Detail_FindById, nil, []interface{}{id}, func(rows *sql.Rows) error {
+		o = new(Detail)
+		return rows.Scan(&o.Id, &o.UserId, &o.Text, &o.Balance, &o.Score)
+	})
+	return o, err
+}
+
+func (*_DetailOper) FindByIdForUpdate(db run.IDB, id int64) (*Detail, error) {
+	var o *Detail
+	err := run.QueryOneNamed(db, "DetailOper.FindByIdForUpdate", _Detail_FindByIdForUpdate, nil, []interface{}{id}, func(rows *sql.Rows) error {
+		o = new(Detail)
+		return rows.Scan(&o.Id, &o.UserId, &o.Text, &o.Balance, &o.Score)
+	})
+	return o, err
+}
+
+func (*_DetailOper) FindByIdLockInShareMode(db run.IDB, id int64) (*Detail, error) {
+	var o *Detail
+	err := run.QueryOneNamed(db, "DetailOper.FindByIdLockInShareMode", _Detail_FindByIdLockInShareMode, nil, []interface{}{id}, func(rows *sql.Rows) error {
 		o = new(Detail)
 		return rows.Scan(&o.Id, &o.UserId, &o.Text, &o.Balance, &o.Score)
 	})
@@ -69,16 +102,16 @@ func (*_DetailOper) FindById(db run.IDB, id int64) (*Detail, error) {
 }
 
 func (*_DetailOper) DeleteById(db run.IDB, id int64) (sql.Result, error) {
-	return run.Exec(db, _Detail_DeleteById, nil, []interface{}{id})
+	return run.ExecNamed(db, "DetailOper.DeleteById", _Detail_DeleteById, nil, []interface{}{id})
 }
 
 func (*_DetailOper) UpdateById(db run.IDB, o *Detail) (sql.Result, error) {
-	return run.Exec(db, _Detail_UpdateById, nil, []interface{}{o.UserId, o.Text, o.Balance, o.Score, o.Id})
+	return run.ExecNamed(db, "DetailOper.UpdateById", _Detail_UpdateById, nil, []interface{}{o.UserId, o.Text, o.Balance, o.Score, o.Id})
 }
 
 func (*_DetailOper) Count(db run.IDB) (int64, error) {
 	var cnt int64
-	err := run.QueryOne(db, _Detail_Count, nil, nil, func(rows *sql.Rows) error {
+	err := run.QueryOneNamed(db, "DetailOper.Count", _Detail_Count, nil, nil, func(rows *sql.Rows) error {
 		return rows.Scan(&cnt)
 	})
 	return cnt, err
@@ -86,7 +119,7 @@ func (*_DetailOper) Count(db run.IDB) (int64, error) {
 
 func (*_DetailOper) FindOneByUserId(db run.IDB, userId int64) (*Detail, error) {
 	var o *Detail
-	err := run.QueryOne(db, _Detail_FindOneByUserId, nil, []interface{}{userId}, func(rows *sql.Rows) error {
+	err := run.QueryOneNamed(db, "DetailOper.FindOneByUserId", _Detail_FindOneByUserId, nil, []interface{}{userId}, func(rows *sql.Rows) error {
 		o = new(Detail)
 		return rows.Scan(&o.Id, &o.UserId, &o.Text, &o.Balance, &o.Score)
 	})
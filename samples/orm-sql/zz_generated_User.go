@@ -20,13 +20,15 @@ const (
 )
 
 const (
-	_User_InsertOne    = "INSERT INTO `user`(`name`,`phone`,`code`,`is_removed`,`create_date`) VALUES (?,?,?,?,?)"
-	_User_InsertBatch  = "INSERT INTO `user`(`name`,`phone`,`code`,`is_removed`,`create_date`) VALUES %s"
-	_User_InsertValues = "(?,?,?,?,?)"
-	_User_FindById     = "SELECT `id`,`name`,`phone`,`code`,`is_removed`,`create_date` FROM `user` WHERE `id`=?"
-	_User_DeleteById   = "DELETE FROM `user` WHERE `id`=?"
-	_User_UpdateById   = "UPDATE `user` SET `name`=?,`phone`=?,`code`=?,`is_removed`=?,`create_date`=? WHERE `id`=?"
-	_User_Count        = "SELECT COUNT(1) FROM `user`"
+	_User_InsertOne               = "INSERT INTO `user`(`name`,`phone`,`code`,`is_removed`,`create_date`) VALUES (?,?,?,?,?)"
+	_User_InsertBatch             = "INSERT INTO `user`(`name`,`phone`,`code`,`is_removed`,`create_date`) VALUES %s"
+	_User_InsertValues            = "(?,?,?,?,?)"
+	_User_FindById                = "SELECT `id`,`name`,`phone`,`code`,`is_removed`,`create_date` FROM `user` WHERE `id`=?"
+	_User_FindByIdForUpdate       = "SELECT `id`,`name`,`phone`,`code`,`is_removed`,`create_date` FROM `user` WHERE `id`=? FOR UPDATE"
+	_User_FindByIdLockInShareMode = "SELECT `id`,`name`,`phone`,`code`,`is_removed`,`create_date` FROM `user` WHERE `id`=? LOCK IN SHARE MODE"
+	_User_DeleteById              = "DELETE FROM `user` WHERE `id`=?"
+	_User_UpdateById              = "UPDATE `user` SET `name`=?,`phone`=?,`code`=?,`is_removed`=?,`create_date`=? WHERE `id`=?"
+	_User_Count                   = "SELECT COUNT(1) FROM `user`"
 )
 
 const _User_FindOneByCode = "SELECT `id`,`name`,`phone`,`code`,`is_removed`,`create_date` FROM `user` WHERE `code`=?"
@@ -51,24 +53,55 @@ type User struct {
 }
 
 func (*_UserOper) Insert(db run.IDB, o *User) (sql.Result, error) {
-	return run.Exec(db, _User_InsertOne, nil, []interface{}{o.Name, o.Phone, o.Code, o.IsDelete, o.CreateDate})
+	return run.ExecNamed(db, "UserOper.Insert", _User_InsertOne, nil, []interface{}{o.Name, o.Phone, o.Code, o.IsDelete, o.CreateDate})
 }
 
 func (*_UserOper) InsertBatch(db run.IDB, os []*User) (sql.Result, error) {
-	vs := make([]interface{}, 0, 6*len(os))
-	valStrs := make([]string, len(os))
-	for idx, o := range os {
-		valStrs[idx] = _User_InsertValues
-		vs = append(vs, o.Name, o.Phone, o.Code, o.IsDelete, o.CreateDate)
+	var rs run.Results
+	err := run.Chunk(len(os), 13107, func(start, end int) error {
+		chunk := os[start:end]
+		vs := make([]interface{}, 0, 6*len(chunk))
+		valStrs := make([]string, len(chunk))
+		for idx, o := range chunk {
+			valStrs[idx] = _User_InsertValues
+			vs = append(vs, o.Name, o.Phone, o.Code, o.IsDelete, o.CreateDate)
+		}
+		valStr := strings.Join(valStrs, ", ")
+		_sql := fmt.Sprintf(_User_InsertBatch, valStr)
+		r, err := run.ExecNamed(db, "UserOper.InsertBatch", _sql, nil, vs)
+		if err != nil {
+			return err
+		}
+		rs = append(rs, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	valStr := strings.Join(valStrs, ", ")
-	_sql := fmt.Sprintf(_User_InsertBatch, valStr)
-	return run.Exec(db, _sql, nil, vs)
+	return rs, nil
 }
 
 func (*_UserOper) FindById(db run.IDB, id int64) (*User, error) {
 	var o *User
-	err := run.QueryOne(db, _User_FindById, nil, []interface{}{id}, func(rows *sql.Rows) error {
+	err := run.QueryOneNamed(db, "UserOper.FindById", _User_FindById, nil, []interface{}{id}, func(rows *sql.Rows) error {
+		o = new(User)
+		return rows.Scan(&o.Id, &o.Name, &o.Phone, &o.Code, &o.IsDelete, &o.CreateDate)
+	})
+	return o, err
+}
+
+func (*_UserOper) FindByIdForUpdate(db run.IDB, id int64) (*User, error) {
+	var o *User
+	err := run.QueryOneNamed(db, "UserOper.FindByIdForUpdate", _User_FindByIdForUpdate, nil, []interface{}{id}, func(rows *sql.Rows) error {
+		o = new(User)
+		return rows.Scan(&o.Id, &o.Name, &o.Phone, &o.Code, &o.IsDelete, &o.CreateDate)
+	})
+	return o, err
+}
+
+func (*_UserOper) FindByIdLockInShareMode(db run.IDB, id int64) (*User, error) {
+	var o *User
+	err := run.QueryOneNamed(db, "UserOper.FindByIdLockInShareMode", _User_FindByIdLockInShareMode, nil, []interface{}{id}, func(rows *sql.Rows) error {
 		o = new(User)
 		return rows.Scan(&o.Id, &o.Name, &o.Phone, &o.Code, &o.IsDelete, &o.CreateDate)
 	})
@@ -76,16 +109,16 @@ func (*_UserOper) FindById(db run.IDB, id int64) (*User, error) {
 }
 
 func (*_UserOper) DeleteById(db run.IDB, id int64) (sql.Result, error) {
-	return run.Exec(db, _User_DeleteById, nil, []interface{}{id})
+	return run.ExecNamed(db, "UserOper.DeleteById", _User_DeleteById, nil, []interface{}{id})
 }
 
 func (*_UserOper) UpdateById(db run.IDB, o *User) (sql.Result, error) {
-	return run.Exec(db, _User_UpdateById, nil, []interface{}{o.Name, o.Phone, o.Code, o.IsDelete, o.CreateDate, o.Id})
+	return run.ExecNamed(db, "UserOper.UpdateById", _User_UpdateById, nil, []interface{}{o.Name, o.Phone, o.Code, o.IsDelete, o.CreateDate, o.Id})
 }
 
 func (*_UserOper) Count(db run.IDB) (int64, error) {
 	var cnt int64
-	err := run.QueryOne(db, _User_Count, nil, nil, func(rows *sql.Rows) error {
+	err := run.QueryOneNamed(db, "UserOper.Count", _User_Count, nil, nil, func(rows *sql.Rows) error {
 		return rows.Scan(&cnt)
 	})
 	return cnt, err
@@ -93,7 +126,7 @@ func (*_UserOper) Count(db run.IDB) (int64, error) {
 
 func (*_UserOper) FindOneByCode(db run.IDB, code string) (*User, error) {
 	var o *User
-	err := run.QueryOne(db, _User_FindOneByCode, nil, []interface{}{code}, func(rows *sql.Rows) error {
+	err := run.QueryOneNamed(db, "UserOper.FindOneByCode", _User_FindOneByCode, nil, []interface{}{code}, func(rows *sql.Rows) error {
 		o = new(User)
 		return rows.Scan(&o.Id, &o.Name, &o.Phone, &o.Code, &o.IsDelete, &o.CreateDate)
 	})
@@ -102,7 +135,7 @@ func (*_UserOper) FindOneByCode(db run.IDB, code string) (*User, error) {
 
 func (*_UserOper) FindManyByName(db run.IDB, name string) ([]*User, error) {
 	var os []*User
-	err := run.QueryMany(db, _User_FindManyByName, nil, []interface{}{name}, func(rows *sql.Rows) error {
+	err := run.QueryManyNamed(db, "UserOper.FindManyByName", _User_FindManyByName, nil, []interface{}{name}, func(rows *sql.Rows) error {
 		o := new(User)
 		err := rows.Scan(&o.Id, &o.Name, &o.Phone, &o.Code, &o.IsDelete, &o.CreateDate)
 		if err != nil {
@@ -116,7 +149,7 @@ func (*_UserOper) FindManyByName(db run.IDB, name string) ([]*User, error) {
 
 func (*_UserOper) FindManyByPhone(db run.IDB, phone string) ([]*User, error) {
 	var os []*User
-	err := run.QueryMany(db, _User_FindManyByPhone, nil, []interface{}{phone}, func(rows *sql.Rows) error {
+	err := run.QueryManyNamed(db, "UserOper.FindManyByPhone", _User_FindManyByPhone, nil, []interface{}{phone}, func(rows *sql.Rows) error {
 		o := new(User)
 		err := rows.Scan(&o.Id, &o.Name, &o.Phone, &o.Code, &o.IsDelete, &o.CreateDate)
 		if err != nil {
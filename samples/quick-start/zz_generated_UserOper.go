@@ -27,7 +27,7 @@ type _UserOper struct {}
 
 func (*_UserOper) FindById(db *sql.DB, id int64) (*User, error) {
 	var o *User
-	err := run.QueryOne(db, _UserOper_FindById, nil, []interface{}{id}, func(rows *sql.Rows) error {
+	err := run.QueryOneNamed(db, "UserOper.FindById", _UserOper_FindById, nil, []interface{}{id}, func(rows *sql.Rows) error {
 		o = new(User)
 		return rows.Scan(&o.Id, &o.Name, &o.Age)
 	})
@@ -35,5 +35,5 @@ func (*_UserOper) FindById(db *sql.DB, id int64) (*User, error) {
 }
 
 func (*_UserOper) Add(db *sql.DB, u *User) (sql.Result, error) {
-	return run.Exec(db, _UserOper_Add, nil, []interface{}{u.Id, u.Name, u.Age})
+	return run.ExecNamed(db, "UserOper.Add", _UserOper_Add, nil, []interface{}{u.Id, u.Name, u.Age})
 }
\ No newline at end of file
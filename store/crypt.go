@@ -0,0 +1,132 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/fioncat/go-gendb/misc/errors"
+)
+
+// KeyfileEnv is the environment variable to specify the
+// keyfile path. The default keyfile is "keyfile" under the
+// home directory of the store.
+const KeyfileEnv = "GENDB_KEYFILE"
+
+// the prefix of the encrypted payload.
+var cryptMagic = []byte("GENDB-AES1")
+
+// KeyfilePath returns the path of the keyfile.
+func KeyfilePath() string {
+	if path := os.Getenv(KeyfileEnv); path != "" {
+		return path
+	}
+	return filepath.Join(baseHome(), "keyfile")
+}
+
+// GenKey generates a random keyfile if it does not exist.
+// After that, all the objects will be encrypted by the key
+// when saving. Returns the path of the keyfile.
+//
+// The objects saved before are not encrypted by GenKey, they
+// are still readable as plaintext, and are encrypted when
+// they are saved again. Re-save the objects to encrypt them,
+// such as "conn --encrypt" does for the connections.
+func GenKey() (string, error) {
+	path := KeyfilePath()
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	key := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return "", errors.Trace("generate key", err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return "", errors.Trace("mkdir for keyfile", err)
+	}
+	err = ioutil.WriteFile(path, key, 0600)
+	if err != nil {
+		return "", errors.Trace("write keyfile", err)
+	}
+	return path, nil
+}
+
+// loadKey reads the keyfile and derives the AES-256 key
+// from it. If there is no keyfile, returns nil.
+func loadKey() ([]byte, error) {
+	path := KeyfilePath()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Trace("read keyfile", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("keyfile %s is empty", path)
+	}
+	key := sha256.Sum256(data)
+	return key[:], nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt the payload with AES-GCM, the format is:
+// magic + nonce + ciphertext.
+func encrypt(key, payload []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, len(cryptMagic)+len(nonce)+
+		len(payload)+gcm.Overhead())
+	data = append(data, cryptMagic...)
+	data = append(data, nonce...)
+	return gcm.Seal(data, nonce, payload, nil), nil
+}
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, cryptMagic)
+}
+
+func decrypt(key, data []byte) ([]byte, error) {
+	if key == nil {
+		return nil, fmt.Errorf("data is encrypted, but "+
+			"keyfile %s does not exist", KeyfilePath())
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(cryptMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted data is too short")
+	}
+	nonce := data[:gcm.NonceSize()]
+	payload, err := gcm.Open(nil, nonce, data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.Trace("decrypt, the keyfile "+
+			"might be changed", err)
+	}
+	return payload, nil
+}
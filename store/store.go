@@ -101,6 +101,9 @@ func decodePayload(payload []byte) ([]byte, bool) {
 // name is used to retrieve the object from the disk. If the
 // object corresponding to name already exists when Save is
 // called, it will be aligned and overwritten.
+//
+// If the keyfile exists (see GenKey), the payload will be
+// encrypted by the key.
 func Save(name string, v interface{}, ttl time.Duration) error {
 	data, err := json.Marshal(v)
 	if err != nil {
@@ -113,13 +116,23 @@ func Save(name string, v interface{}, ttl time.Duration) error {
 	}
 
 	payload := encodePayload(data, expire)
+	key, err := loadKey()
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		payload, err = encrypt(key, payload)
+		if err != nil {
+			return errors.Trace("encrypt", err)
+		}
+	}
 
 	name = strings.ReplaceAll(name, KEY_SEP, PATH_SEP)
 	path := filepath.Join(baseHome(), name)
 
 	_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
 
-	return ioutil.WriteFile(path, payload, 0644)
+	return ioutil.WriteFile(path, payload, 0600)
 }
 
 // Load will load the data of the specified name from the
@@ -147,6 +160,16 @@ func Load(name string, v interface{}) (bool, error) {
 		return false, errors.Trace(
 			"read file", err)
 	}
+	if isEncrypted(payload) {
+		key, err := loadKey()
+		if err != nil {
+			return false, err
+		}
+		payload, err = decrypt(key, payload)
+		if err != nil {
+			return false, errors.Trace(path, err)
+		}
+	}
 
 	data, ok := decodePayload(payload)
 	if !ok {
@@ -177,3 +200,29 @@ func Remove(name string) error {
 	path := filepath.Join(baseHome(), name)
 	return os.Remove(path)
 }
+
+// Names returns the names of the objects whose names
+// start with "prefix" (such as "conn.").
+func Names(prefix string) ([]string, error) {
+	dir := strings.ReplaceAll(prefix, KEY_SEP, PATH_SEP)
+	dir = filepath.Join(baseHome(), dir)
+	var names []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(baseHome(), path)
+		if err != nil {
+			return err
+		}
+		names = append(names, strings.ReplaceAll(rel, PATH_SEP, KEY_SEP))
+		return nil
+	})
+	return names, err
+}
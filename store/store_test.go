@@ -0,0 +1,245 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testObject struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+// useTempHome replaces the home directory of the store and
+// the keyfile with the temporary ones, returns the function
+// to restore them.
+func useTempHome(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "gendb-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseOnce.Do(func() {})
+	oriPath := basePath
+	basePath = dir
+	oriKeyfile, hasKeyfile := os.LookupEnv(KeyfileEnv)
+	os.Setenv(KeyfileEnv, filepath.Join(dir, "key", "keyfile"))
+	return func() {
+		basePath = oriPath
+		if hasKeyfile {
+			os.Setenv(KeyfileEnv, oriKeyfile)
+		} else {
+			os.Unsetenv(KeyfileEnv)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func readRaw(t *testing.T, name string) []byte {
+	name = strings.ReplaceAll(name, KEY_SEP, PATH_SEP)
+	data, err := ioutil.ReadFile(filepath.Join(baseHome(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func expectLoad(t *testing.T, name string, expect testObject) {
+	var obj testObject
+	ok, err := Load(name, &obj)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	if !ok {
+		t.Fatalf("load %s: not found", name)
+	}
+	if obj != expect {
+		t.Fatalf("load %s: got %+v, expect %+v", name, obj, expect)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	defer useTempHome(t)()
+	obj := testObject{Name: "a", Value: 1}
+	err := Save("test.plain", obj, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isEncrypted(readRaw(t, "test.plain")) {
+		t.Fatal("encrypted without keyfile")
+	}
+	expectLoad(t, "test.plain", obj)
+
+	var missing testObject
+	ok, err := Load("test.missing", &missing)
+	if ok || err != nil {
+		t.Fatalf("load missing: %v, %v", ok, err)
+	}
+
+	// The expired object is removed.
+	payload := encodePayload([]byte(`{"name":"a"}`), time.Now().Unix()-1)
+	path := filepath.Join(baseHome(), "test", "expired")
+	err = ioutil.WriteFile(path, payload, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err = Load("test.expired", &missing)
+	if ok || err != nil {
+		t.Fatalf("load expired: %v, %v", ok, err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expired object is not removed: %v", err)
+	}
+
+	err = Save("test.ttl", obj, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLoad(t, "test.ttl", obj)
+}
+
+func TestEncrypt(t *testing.T) {
+	defer useTempHome(t)()
+	legacy := testObject{Name: "legacy", Value: 1}
+	err := Save("test.legacy", legacy, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := GenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != KeyfilePath() {
+		t.Fatalf("keyfile %s, expect %s", path, KeyfilePath())
+	}
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// GenKey keeps the existing keyfile.
+	_, err = GenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) != string(again) {
+		t.Fatal("keyfile is regenerated")
+	}
+
+	obj := testObject{Name: "secret", Value: 2}
+	err = Save("test.secret", obj, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := readRaw(t, "test.secret")
+	if !isEncrypted(raw) || strings.Contains(string(raw), "secret") {
+		t.Fatalf("not encrypted: %q", raw)
+	}
+	expectLoad(t, "test.secret", obj)
+
+	// The plaintext saved before the keyfile is readable,
+	// and encrypted when saving again.
+	if isEncrypted(readRaw(t, "test.legacy")) {
+		t.Fatal("legacy object is encrypted by GenKey")
+	}
+	expectLoad(t, "test.legacy", legacy)
+	err = Save("test.legacy", legacy, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(readRaw(t, "test.legacy")) {
+		t.Fatal("legacy object is not encrypted after saving")
+	}
+	expectLoad(t, "test.legacy", legacy)
+}
+
+func TestDecryptError(t *testing.T) {
+	defer useTempHome(t)()
+	_, err := GenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Save("test.secret", testObject{Name: "a"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wrong key.
+	err = ioutil.WriteFile(KeyfilePath(), []byte("another key"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj testObject
+	_, err = Load("test.secret", &obj)
+	if err == nil || !strings.Contains(err.Error(), "the keyfile might be changed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// No keyfile.
+	err = os.Remove(KeyfilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load("test.secret", &obj)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Empty keyfile.
+	err = ioutil.WriteFile(KeyfilePath(), nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load("test.secret", &obj)
+	if err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGenKeyError(t *testing.T) {
+	defer useTempHome(t)()
+	// The parent of the keyfile is a file.
+	parent := filepath.Join(baseHome(), "file")
+	err := ioutil.WriteFile(parent, []byte("x"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(KeyfileEnv, filepath.Join(parent, "keyfile"))
+	_, err = GenKey()
+	if err == nil || !strings.Contains(err.Error(), "mkdir for keyfile") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	defer useTempHome(t)()
+	for _, name := range []string{"conn.a", "conn.b", "conn.sub.c", "other.d"} {
+		err := Save(name, testObject{Name: name}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := Names("conn.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	expect := []string{"conn.a", "conn.b", "conn.sub.c"}
+	if !reflect.DeepEqual(names, expect) {
+		t.Fatalf("names: %v, expect %v", names, expect)
+	}
+
+	names, err = Names("missing.")
+	if err != nil || len(names) != 0 {
+		t.Fatalf("names of missing: %v, %v", names, err)
+	}
+}